@@ -0,0 +1,18 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// adminPrefix is where operator-only endpoints are mounted
+const adminPrefix = "/admin"
+
+// adminRealm is advertised in the WWW-Authenticate challenge
+const adminRealm = "admin"
+
+// AdminAuth returns a middleware requiring HTTP Basic credentials user and
+// password. gin.BasicAuth compares the whole Authorization header in
+// constant time and answers failures with a WWW-Authenticate challenge.
+func AdminAuth(user, password string) gin.HandlerFunc {
+	return gin.BasicAuthForRealm(gin.Accounts{user: password}, adminRealm)
+}
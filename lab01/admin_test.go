@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminRouter mounts the admin routes and pprof under adminPrefix behind
+// AdminAuth for "ops"/"s3cret"
+func adminRouter() *gin.Engine {
+	r := gin.New()
+	admin := r.Group(adminPrefix, AdminAuth("ops", "s3cret"))
+	RegisterRoutes(admin, adminRoutes(nil))
+	registerPprofRoutes(admin.Group("/debug/pprof"))
+	return r
+}
+
+func TestAdminAuth(t *testing.T) {
+	tests := []struct {
+		name           string
+		user, password string
+		want           int
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized},
+		{"wrong password", "ops", "guess", http.StatusUnauthorized},
+		{"wrong user", "root", "s3cret", http.StatusUnauthorized},
+		{"correct", "ops", "s3cret", http.StatusOK},
+	}
+	for _, target := range []string{"/admin/metrics", "/admin/flags", "/admin/debug/pprof/cmdline"} {
+		for _, tt := range tests {
+			t.Run(target+"/"+tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, target, nil)
+				if tt.user != "" {
+					req.SetBasicAuth(tt.user, tt.password)
+				}
+				w := httptest.NewRecorder()
+				adminRouter().ServeHTTP(w, req)
+
+				if w.Code != tt.want {
+					t.Fatalf("status %d, want %d", w.Code, tt.want)
+				}
+				challenge := w.Header().Get("WWW-Authenticate")
+				if tt.want == http.StatusUnauthorized && challenge != `Basic realm="admin"` {
+					t.Fatalf("WWW-Authenticate is %q, want a Basic challenge for the admin realm", challenge)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader is the header machine clients send their API key in
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuth returns a middleware that authenticates requests by the
+// X-API-Key header. validKeys maps each key to the client name stored in the
+// context on success.
+func APIKeyAuth(validKeys map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := []byte(c.GetHeader(APIKeyHeader))
+
+		// Compare against every key so timing doesn't reveal which one matched
+		var client string
+		for key, name := range validKeys {
+			if subtle.ConstantTimeCompare(provided, []byte(key)) == 1 {
+				client = name
+			}
+		}
+
+		if len(provided) == 0 || client == "" {
+			RespondError(c, http.StatusUnauthorized, CodeUnauthorized, "invalid or missing API key")
+			return
+		}
+
+		SetAPIClient(c, client)
+		c.Next()
+	}
+}
+
+// machineSearchHandler is searchHandler for clients authenticated by
+// APIKeyAuth, each search counting towards their quota
+//
+//	@Summary	Search as an API key client
+//	@Tags		search
+//	@Produce	json,text/csv
+//	@Param		X-API-Key	header		string	true	"API key"
+//	@Param		q			query		string	true	"Search query"
+//	@Param		format		query		string	false	"Response format"											Enums(json, csv)	default(json)
+//	@Param		limit		query		int		false	"Maximum number of results (capped at SEARCH_MAX_LIMIT)"	default(10)			minimum(1)
+//	@Param		page		query		int		false	"Page number"												default(1)			minimum(1)
+//	@Param		cursor		query		string	false	"Opaque next_cursor from a previous response; takes precedence over page"
+//	@Success	200			{object}	SearchResponse
+//	@Header		200			{string}	X-Quota-Remaining	"Requests left this month, when the quota is limited"
+//	@Failure	400			{object}	APIError
+//	@Failure	401			{object}	APIError
+//	@Failure	429			{object}	APIError
+//	@Failure	500			{object}	APIError
+//	@Failure	503			{object}	APIError
+//	@Router		/v1/machine/search [get]
+func machineSearchHandler(searcher, termSearcher Searcher, maxLimit func() int) gin.HandlerFunc {
+	return searchHandler(searcher, termSearcher, maxLimit)
+}
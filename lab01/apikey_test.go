@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var testAPIKeys = map[string]string{
+	"s3cr3t-key-1": "billing-service",
+	"s3cr3t-key-2": "reporting-job",
+}
+
+// getWithKey performs a GET of target on h, sending key as X-API-Key unless
+// it's empty
+func getWithKey(h http.Handler, target, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if key != "" {
+		req.Header.Set(APIKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// v1Router registers the v1 routes under /v1 with the given dependencies
+// filled in, and a current config for the handlers that read it
+func v1Router(t *testing.T, deps routeDeps) *gin.Engine {
+	t.Helper()
+	prev := CurrentConfig()
+	SetConfig(&Config{SearchMaxLimit: 100})
+	t.Cleanup(func() { SetConfig(prev) })
+
+	if deps.Searcher == nil {
+		deps.Searcher = &fakeSearcher{}
+	}
+	deps.TermSearcher = deps.Searcher
+	if deps.Quota == nil {
+		deps.Quota = NewQuotaTracker(0, time.Hour)
+	}
+	if deps.Users == nil {
+		deps.Users = NewMemoryUserStore()
+	}
+	deps.Sessions = NewSessionStore(time.Hour)
+	deps.Idempotency = newIdempotencyStore(time.Hour)
+
+	r := gin.New()
+	RegisterRoutes(r.Group("/v1"), v1Routes(deps))
+	return r
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	r := gin.New()
+	r.GET("/ping", pingHandler)
+	machine := r.Group("/machine", APIKeyAuth(testAPIKeys))
+	machine.GET("/whoami", func(c *gin.Context) {
+		c.String(http.StatusOK, APIClientFromContext(c))
+	})
+
+	tests := []struct {
+		name       string
+		target     string
+		key        string
+		wantStatus int
+		wantClient string
+	}{
+		{"missing key", "/machine/whoami", "", http.StatusUnauthorized, ""},
+		{"wrong key", "/machine/whoami", "not-a-key", http.StatusUnauthorized, ""},
+		{"prefix of a key", "/machine/whoami", "s3cr3t-key", http.StatusUnauthorized, ""},
+		{"key with a suffix", "/machine/whoami", "s3cr3t-key-1x", http.StatusUnauthorized, ""},
+		{"first key", "/machine/whoami", "s3cr3t-key-1", http.StatusOK, "billing-service"},
+		{"second key", "/machine/whoami", "s3cr3t-key-2", http.StatusOK, "reporting-job"},
+		{"public route without a key", "/ping", "", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := getWithKey(r, tt.target, tt.key)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantClient != "" && w.Body.String() != tt.wantClient {
+				t.Fatalf("client %q, want %q", w.Body, tt.wantClient)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthWithoutKeysRejectsEverything(t *testing.T) {
+	r := gin.New()
+	r.GET("/", APIKeyAuth(nil), pingHandler)
+	if w := getWithKey(r, "/", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("got %d, want 401", w.Code)
+	}
+}
+
+func TestMachineSearchRequiresAPIKey(t *testing.T) {
+	searcher := &fakeSearcher{results: []Result{{ID: 1, Title: "Go"}}, total: 1}
+	r := v1Router(t, routeDeps{Searcher: searcher, APIKeys: testAPIKeys})
+
+	tests := []struct {
+		name   string
+		target string
+		key    string
+		want   int
+	}{
+		{"missing key", "/v1/machine/search?q=go", "", http.StatusUnauthorized},
+		{"wrong key", "/v1/machine/search?q=go", "not-a-key", http.StatusUnauthorized},
+		{"valid key", "/v1/machine/search?q=go", "s3cr3t-key-1", http.StatusOK},
+		{"public search stays open", "/v1/search?q=go", "", http.StatusOK},
+		{"usage without a key", "/v1/usage", "", http.StatusUnauthorized},
+		{"usage with a key", "/v1/usage", "s3cr3t-key-2", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := getWithKey(r, tt.target, tt.key); w.Code != tt.want {
+				t.Fatalf("got %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+	if searcher.calls != 2 {
+		t.Fatalf("searched %d times, want 2: unauthenticated requests reached the backend", searcher.calls)
+	}
+}
+
+func TestMachineRoutesNeedConfiguredKeys(t *testing.T) {
+	r := v1Router(t, routeDeps{})
+	if w := getWithKey(r, "/v1/machine/search?q=go", "s3cr3t-key-1"); w.Code != http.StatusNotFound {
+		t.Fatalf("got %d, want 404 without API_KEYS", w.Code)
+	}
+}
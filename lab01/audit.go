@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Audited actions
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+)
+
+// AuditEntry records one mutating operation
+type AuditEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Actor is the authenticated subject that made the change
+	Actor        string `json:"actor"`
+	Action       string `json:"action" enums:"create,update,delete,restore"`
+	ResourceType string `json:"resource_type"`
+	ResourceID   string `json:"resource_id"`
+	RequestID    string `json:"request_id"`
+}
+
+// AuditStore is an append-only sink for audit entries
+type AuditStore interface {
+	// Append stores entry after every entry already stored
+	Append(ctx context.Context, entry AuditEntry) error
+	// List returns a page of entries, newest first, and the total count
+	List(ctx context.Context, limit, offset int) ([]AuditEntry, int, error)
+}
+
+// newAuditStore returns a FileAuditStore when path is set, otherwise an
+// in-memory store that's lost on restart
+func newAuditStore(path string) (AuditStore, func(), error) {
+	if path == "" {
+		slog.Info("AUDIT_LOG_FILE not set, keeping the audit log in memory")
+		return &MemoryAuditStore{}, func() {}, nil
+	}
+
+	store, err := NewFileAuditStore(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	slog.Info("Writing audit log to file", "path", path)
+	return store, store.Close, nil
+}
+
+// MemoryAuditStore is an AuditStore backed by a slice
+type MemoryAuditStore struct {
+	mu      sync.RWMutex
+	entries []AuditEntry
+}
+
+func (s *MemoryAuditStore) Append(ctx context.Context, entry AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *MemoryAuditStore) List(ctx context.Context, limit, offset int) ([]AuditEntry, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return newestFirst(s.entries, limit, offset), len(s.entries), nil
+}
+
+// FileAuditStore is an AuditStore appending JSON lines to a file
+type FileAuditStore struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// NewFileAuditStore opens path for appending, creating it if needed
+func NewFileAuditStore(path string) (*FileAuditStore, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("open AUDIT_LOG_FILE: %w", err)
+	}
+	return &FileAuditStore{path: path, file: file}, nil
+}
+
+// Close closes the audit file
+func (s *FileAuditStore) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.file.Close()
+}
+
+func (s *FileAuditStore) Append(ctx context.Context, entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+// List reads the whole file, which is fine at lab scale
+func (s *FileAuditStore) List(ctx context.Context, limit, offset int) ([]AuditEntry, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, 0, fmt.Errorf("parse audit log: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return newestFirst(entries, limit, offset), len(entries), nil
+}
+
+// newestFirst returns a page of entries, which are stored oldest first
+func newestFirst(entries []AuditEntry, limit, offset int) []AuditEntry {
+	end := max(len(entries)-offset, 0)
+	start := max(end-limit, 0)
+	page := slices.Clone(entries[start:end])
+	slices.Reverse(page)
+	return page
+}
+
+// AuditLogger records who changed what. It's used after a change has
+// succeeded, so failures to record are logged rather than failing the request.
+type AuditLogger struct {
+	store AuditStore
+}
+
+// NewAuditLogger returns an AuditLogger writing to store
+func NewAuditLogger(store AuditStore) *AuditLogger {
+	return &AuditLogger{store: store}
+}
+
+// Record logs action on the resource, attributed to the subject set by
+// AuthRequired and tagged with the request ID
+func (a *AuditLogger) Record(c *gin.Context, action, resourceType, resourceID string) {
+	entry := AuditEntry{
+		Timestamp:    time.Now().UTC(),
+		Actor:        UserIDFromContext(c),
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		RequestID:    RequestIDFromContext(c),
+	}
+
+	// Record even if the client has gone away; the change already happened
+	ctx := context.WithoutCancel(c.Request.Context())
+	if err := a.store.Append(ctx, entry); err != nil {
+		slog.Error("Failed to write audit entry", "action", action, "resource_type", resourceType, "resource_id", resourceID, "error", err)
+	}
+}
+
+// AuditListResponse is a page of audit entries
+type AuditListResponse struct {
+	Pagination
+	Total   int          `json:"total"`
+	Entries []AuditEntry `json:"entries"`
+}
+
+// auditHandler lists audit entries, newest first
+//
+//	@Summary	List audit log entries
+//	@Tags		admin
+//	@Produce	json
+//	@Security	AdminAuth
+//	@Param		limit	query		int	false	"Page size"		default(10)
+//	@Param		page	query		int	false	"Page number"	default(1)
+//	@Success	200		{object}	AuditListResponse
+//	@Failure	400		{object}	APIError
+//	@Failure	401
+//	@Failure	403	{object}	APIError
+//	@Router		/admin/audit [get]
+func auditHandler(audit *AuditLogger, maxLimit func() int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pagination, err := parsePagination(c, maxLimit())
+		if err != nil {
+			RespondError(c, http.StatusBadRequest, CodeInvalidQuery, err.Error())
+			return
+		}
+
+		entries, total, err := audit.store.List(c.Request.Context(), pagination.Limit, pagination.Offset)
+		if err != nil {
+			RespondError(c, http.StatusInternalServerError, CodeInternal, "Failed to read audit log")
+			return
+		}
+		if entries == nil {
+			entries = []AuditEntry{}
+		}
+
+		c.JSON(http.StatusOK, AuditListResponse{
+			Pagination: pagination,
+			Total:      total,
+			Entries:    entries,
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// auditedRouter serves user creation and deletion as "alice", recording to
+// audit, with the audit trail at /audit
+func auditedRouter(t *testing.T, audit *AuditLogger) *gin.Engine {
+	jobs := NewJobQueue(1, 10)
+	t.Cleanup(func() { jobs.Shutdown(context.Background()) })
+	store := NewMemoryUserStore()
+
+	r := gin.New()
+	r.Use(RequestID(), func(c *gin.Context) { SetUserID(c, "alice") })
+	r.POST("/user", createUserHandler(store, nil, jobs, audit, bcrypt.MinCost))
+	r.DELETE("/user/:id", deleteUserHandler(store, audit))
+	r.GET("/audit", auditHandler(audit, func() int { return 100 }))
+	return r
+}
+
+// auditEntries fetches target from h as an AuditListResponse
+func auditEntries(t *testing.T, h http.Handler, target string) AuditListResponse {
+	t.Helper()
+	w := get(h, target)
+	var resp AuditListResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s: status %d: %v", target, w.Code, err)
+	}
+	return resp
+}
+
+func TestAuditRecordsUserCreation(t *testing.T) {
+	store, err := NewFileAuditStore(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	r := auditedRouter(t, NewAuditLogger(store))
+
+	before := time.Now().UTC()
+	created := post(r, "/user", `{"username":"ann","name":"Ann","email":"ann@example.com"}`)
+	if created.Code != http.StatusCreated {
+		t.Fatalf("create: got %d: %s", created.Code, created.Body)
+	}
+
+	entries, total, err := store.List(context.Background(), 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 {
+		t.Fatalf("got %d entries, want 1", total)
+	}
+	want := AuditEntry{Actor: "alice", Action: AuditCreate, ResourceType: "user", ResourceID: "1",
+		RequestID: created.Header().Get(RequestIDHeader)}
+	got := entries[0]
+	if got.Timestamp.Before(before) || got.Timestamp.After(time.Now()) {
+		t.Errorf("timestamp %s outside the request", got.Timestamp)
+	}
+	got.Timestamp = time.Time{}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestAuditHandlerListsNewestFirst(t *testing.T) {
+	r := auditedRouter(t, NewAuditLogger(&MemoryAuditStore{}))
+	for _, name := range []string{"ann", "bob", "cyd"} {
+		if w := post(r, "/user", `{"username":"`+name+`","name":"N","email":"`+name+`@example.com"}`); w.Code != http.StatusCreated {
+			t.Fatalf("create %s: got %d: %s", name, w.Code, w.Body)
+		}
+	}
+	del := httptest.NewRecorder()
+	r.ServeHTTP(del, httptest.NewRequest(http.MethodDelete, "/user/2", nil))
+	if del.Code != http.StatusNoContent {
+		t.Fatalf("delete: got %d", del.Code)
+	}
+
+	page := auditEntries(t, r, "/audit?limit=2&page=1")
+	if page.Total != 4 || len(page.Entries) != 2 {
+		t.Fatalf("got %d of %d entries, want 2 of 4", len(page.Entries), page.Total)
+	}
+	if first := page.Entries[0]; first.Action != AuditDelete || first.ResourceID != "2" {
+		t.Fatalf("newest entry is %+v, want the delete of user 2", first)
+	}
+
+	last := auditEntries(t, r, "/audit?limit=2&page=2")
+	var ids []string
+	for _, entry := range last.Entries {
+		ids = append(ids, entry.Action+" "+entry.ResourceID)
+	}
+	if len(ids) != 2 || ids[0] != AuditCreate+" 2" || ids[1] != AuditCreate+" 1" {
+		t.Fatalf("second page is %v, want the first two creates, newest first", ids)
+	}
+
+	if w := get(r, "/audit?limit=0"); w.Code != http.StatusBadRequest {
+		t.Fatalf("limit=0: got %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// tokenTTL is how long tokens issued by /login stay valid
+const tokenTTL = time.Hour
+
+// Roles that can be granted in a token
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Claims are the JWT claims issued by /login
+type Claims struct {
+	Role string `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// LoginRequest represents the request body for the login endpoint
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// LoginResponse represents the response structure for the login endpoint
+type LoginResponse struct {
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// AuthRequired returns a middleware that validates an HMAC-signed bearer token
+// and stores its subject and role claims in the context
+func AuthRequired(secret []byte) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		tokenString, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || tokenString == "" {
+			RespondError(c, http.StatusUnauthorized, CodeUnauthorized, "missing or malformed Authorization header")
+			return
+		}
+
+		claims := &Claims{}
+		_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (any, error) {
+			return secret, nil
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+		if err != nil {
+			message := "invalid token"
+			if errors.Is(err, jwt.ErrTokenExpired) {
+				message = "token expired"
+			}
+			RespondError(c, http.StatusUnauthorized, CodeUnauthorized, message)
+			return
+		}
+
+		SetUserID(c, claims.Subject)
+		SetRole(c, claims.Role)
+		c.Next()
+	}
+}
+
+// RequireRole returns a middleware that aborts with 403 unless the role set
+// by AuthRequired is role. It must run after AuthRequired.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if RoleFromContext(c) != role {
+			RespondError(c, http.StatusForbidden, CodeForbidden, "requires role "+role)
+			return
+		}
+		c.Next()
+	}
+}
+
+// issueToken signs a token for subject with role that expires after ttl
+func issueToken(secret []byte, subject, role string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+
+	signed, err := token.SignedString(secret)
+	return signed, expiresAt, err
+}
+
+// loginHandler checks the password of the stored user with the given
+// username and issues a signed token carrying the user's role. For browser
+// clients it also starts a session and sets its cookie; any session the
+// request already carried is ended first, so a session ID planted before
+// login is never the one that's signed in. Unknown usernames are checked
+// against a dummy hash of the same bcrypt cost as stored passwords.
+//
+//	@Summary	Log in with a username and password
+//	@Tags		auth
+//	@Accept		json
+//	@Produce	json
+//	@Param		body	body		LoginRequest	true	"Login request"
+//	@Success	200		{object}	LoginResponse
+//	@Header		200		{string}	Set-Cookie	"HttpOnly session cookie for browser clients"
+//	@Failure	400		{object}	APIError
+//	@Failure	401		{object}	APIError
+//	@Router		/v1/login [post]
+func loginHandler(users UserStore, secret []byte, sessions *SessionStore, sessionSecret []byte, cost int) gin.HandlerFunc {
+	// Hashed on first use, so building the router stays cheap
+	dummyHash := sync.OnceValue(func() string {
+		hash, _ := HashPassword("not the password of any user", cost)
+		return hash
+	})
+
+	return func(c *gin.Context) {
+		var req LoginRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+
+		user, err := users.GetByUsername(c.Request.Context(), req.Username)
+		if err != nil && !errors.Is(err, ErrUserNotFound) {
+			RespondError(c, http.StatusInternalServerError, CodeInternal, "User store unavailable")
+			return
+		}
+		// An unknown username, a user without a password and a wrong password
+		// get the same answer after the same bcrypt work, so neither the
+		// response nor its timing reveals which usernames exist
+		known := err == nil && user.PasswordHash != ""
+		if !known {
+			VerifyPassword(User{PasswordHash: dummyHash()}, req.Password)
+		}
+		if !known || !VerifyPassword(user, req.Password) {
+			RespondError(c, http.StatusUnauthorized, CodeUnauthorized, "invalid username or password")
+			return
+		}
+
+		token, expiresAt, err := issueToken(secret, user.Username, user.Role, tokenTTL)
+		if err != nil {
+			RespondError(c, http.StatusInternalServerError, CodeInternal, "Failed to issue token")
+			return
+		}
+
+		if id, ok := sessionIDFromCookie(c, sessionSecret); ok {
+			sessions.Delete(id)
+		}
+		setSessionCookie(c, sessionSecret, sessions.Create(user.Username, user.Role))
+
+		c.JSON(http.StatusOK, LoginResponse{
+			Token:     token,
+			ExpiresAt: expiresAt.UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// bootstrapAdmin creates an admin user with username and password unless a
+// live user with that username already exists, whatever its role
+func bootstrapAdmin(ctx context.Context, users UserStore, username, password string, cost int) error {
+	if _, err := users.GetByUsername(ctx, username); !errors.Is(err, ErrUserNotFound) {
+		return err
+	}
+
+	hash, err := HashPassword(password, cost)
+	if err != nil {
+		return err
+	}
+	if _, err := users.Create(ctx, User{Username: username, Name: username, Role: RoleAdmin, PasswordHash: hash}); err != nil {
+		return err
+	}
+	slog.Info("Created bootstrap admin user", "username", username)
+	return nil
+}
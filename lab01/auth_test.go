@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	testJWTSecret     = []byte("test-jwt-secret")
+	testSessionSecret = []byte("test-session-secret")
+)
+
+// loginRouter serves /login and /session backed by users
+func loginRouter(users UserStore, sessions *SessionStore) *gin.Engine {
+	r := gin.New()
+	r.POST("/login", loginHandler(users, testJWTSecret, sessions, testSessionSecret, bcrypt.MinCost))
+	r.GET("/session", SessionAuth(sessions, testSessionSecret), sessionHandler)
+	r.GET("/me", AuthRequired(testJWTSecret), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user": UserIDFromContext(c), "role": RoleFromContext(c)})
+	})
+	return r
+}
+
+// post sends body as JSON to target on h
+func post(h http.Handler, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// createUserWithPassword stores a user with a bcrypt hash of password
+func createUserWithPassword(t *testing.T, store UserStore, username, password, role string) User {
+	t.Helper()
+	hash, err := HashPassword(password, bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := store.Create(context.Background(), User{Username: username, Name: username, Role: role, PasswordHash: hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestLoginChecksPassword(t *testing.T) {
+	store := NewMemoryUserStore()
+	createUserWithPassword(t, store, "alice", "correct-horse", "")
+	if _, err := store.Create(context.Background(), User{Username: "nopass", Name: "No Password"}); err != nil {
+		t.Fatal(err)
+	}
+	r := loginRouter(store, NewSessionStore(time.Hour))
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"correct password", `{"username":"alice","password":"correct-horse"}`, http.StatusOK},
+		{"wrong password", `{"username":"alice","password":"wrong-horse"}`, http.StatusUnauthorized},
+		{"unknown user", `{"username":"bob","password":"correct-horse"}`, http.StatusUnauthorized},
+		{"user without a password", `{"username":"nopass","password":""}`, http.StatusBadRequest},
+		{"user without a password, any guess", `{"username":"nopass","password":"anything"}`, http.StatusUnauthorized},
+		{"missing password", `{"username":"alice"}`, http.StatusBadRequest},
+		{"missing username", `{"password":"correct-horse"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(r, "/login", tt.body)
+			if w.Code != tt.want {
+				t.Fatalf("got %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusOK && w.Header().Get("Set-Cookie") != "" {
+				t.Fatal("failed login set a session cookie")
+			}
+		})
+	}
+}
+
+func TestLoginUnknownUserAndWrongPasswordLookAlike(t *testing.T) {
+	store := NewMemoryUserStore()
+	createUserWithPassword(t, store, "alice", "correct-horse", "")
+	r := loginRouter(store, NewSessionStore(time.Hour))
+
+	wrong := post(r, "/login", `{"username":"alice","password":"wrong-horse"}`)
+	unknown := post(r, "/login", `{"username":"bob","password":"wrong-horse"}`)
+	if wrong.Body.String() != unknown.Body.String() {
+		t.Fatalf("responses differ: %s vs %s", wrong.Body, unknown.Body)
+	}
+}
+
+func TestLoginTakesRoleFromStoredUser(t *testing.T) {
+	store := NewMemoryUserStore()
+	createUserWithPassword(t, store, "alice", "correct-horse", "")
+	createUserWithPassword(t, store, "root", "root-password", RoleAdmin)
+	r := loginRouter(store, NewSessionStore(time.Hour))
+
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"username":"alice","password":"correct-horse"}`, RoleUser},
+		// A requested role is ignored
+		{`{"username":"alice","password":"correct-horse","role":"admin"}`, RoleUser},
+		{`{"username":"root","password":"root-password"}`, RoleAdmin},
+	}
+	for _, tt := range tests {
+		w := post(r, "/login", tt.body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got %d: %s", tt.body, w.Code, w.Body)
+		}
+		var resp LoginResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+resp.Token)
+		me := httptest.NewRecorder()
+		r.ServeHTTP(me, req)
+		var claims struct{ User, Role string }
+		if err := json.Unmarshal(me.Body.Bytes(), &claims); err != nil {
+			t.Fatal(err)
+		}
+		if me.Code != http.StatusOK || claims.Role != tt.want {
+			t.Fatalf("%s: token gave %d %+v, want role %s", tt.body, me.Code, claims, tt.want)
+		}
+
+		req = httptest.NewRequest(http.MethodGet, "/session", nil)
+		for _, cookie := range w.Result().Cookies() {
+			req.AddCookie(cookie)
+		}
+		session := httptest.NewRecorder()
+		r.ServeHTTP(session, req)
+		var sessionResp SessionResponse
+		if err := json.Unmarshal(session.Body.Bytes(), &sessionResp); err != nil {
+			t.Fatal(err)
+		}
+		if session.Code != http.StatusOK || sessionResp.Role != tt.want {
+			t.Fatalf("%s: session gave %d %+v, want role %s", tt.body, session.Code, sessionResp, tt.want)
+		}
+	}
+}
+
+func TestSessionCookieIsNotSignedWithJWTSecret(t *testing.T) {
+	sessions := NewSessionStore(time.Hour)
+	session := sessions.Create("alice", RoleUser)
+	r := loginRouter(NewMemoryUserStore(), sessions)
+
+	for _, tt := range []struct {
+		secret []byte
+		want   int
+	}{
+		{testSessionSecret, http.StatusOK},
+		{testJWTSecret, http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/session", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: signSessionID(tt.secret, session.ID)})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Fatalf("cookie signed with %q: got %d, want %d", tt.secret, w.Code, tt.want)
+		}
+	}
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	store := NewMemoryUserStore()
+	ctx := context.Background()
+
+	for range 2 {
+		if err := bootstrapAdmin(ctx, store, "root", "root-password", bcrypt.MinCost); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, total, _ := store.List(ctx, UserListOptions{Limit: 10})
+	if total != 1 {
+		t.Fatalf("got %d users after bootstrapping twice, want 1", total)
+	}
+	admin, err := store.GetByUsername(ctx, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if admin.Role != RoleAdmin || !VerifyPassword(admin, "root-password") {
+		t.Fatalf("bootstrap admin has role %q or the wrong password", admin.Role)
+	}
+
+	// An existing user with the name is left alone, not promoted
+	store = NewMemoryUserStore()
+	createUserWithPassword(t, store, "root", "user-password", "")
+	if err := bootstrapAdmin(ctx, store, "root", "root-password", bcrypt.MinCost); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := store.GetByUsername(ctx, "root"); user.Role != RoleUser || !VerifyPassword(user, "user-password") {
+		t.Fatalf("existing user changed: role %q", user.Role)
+	}
+}
+
+func TestMemoryUserStoreUsernamesAreUnique(t *testing.T) {
+	store := NewMemoryUserStore()
+	ctx := context.Background()
+	first := createUserWithPassword(t, store, "alice", "correct-horse", "")
+
+	if _, err := store.Create(ctx, User{Username: "alice", Name: "Other Alice"}); !errors.Is(err, ErrUsernameTaken) {
+		t.Fatalf("second alice: got %v, want ErrUsernameTaken", err)
+	}
+
+	// Deleting frees the username, and restoring fails while it's reused
+	if err := store.Delete(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetByUsername(ctx, "alice"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("deleted user found by username: %v", err)
+	}
+	second, err := store.Create(ctx, User{Username: "alice", Name: "Other Alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Restore(ctx, first.ID); !errors.Is(err, ErrUsernameTaken) {
+		t.Fatalf("restore over a reused username: got %v, want ErrUsernameTaken", err)
+	}
+	if found, _ := store.GetByUsername(ctx, "alice"); found.ID != second.ID {
+		t.Fatalf("GetByUsername returned user %d, want %d", found.ID, second.ID)
+	}
+}
+
+// getWithAuth sends a GET to target on h with the Authorization header set
+func getWithAuth(h http.Handler, target, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthRequired(t *testing.T) {
+	r := loginRouter(NewMemoryUserStore(), NewSessionStore(time.Hour))
+
+	valid, _, err := issueToken(testJWTSecret, "alice", RoleUser, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _, err := issueToken(testJWTSecret, "alice", RoleUser, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSecret, _, err := issueToken([]byte("other-secret"), "alice", RoleUser, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noExpiry, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "alice"},
+	}).SignedString(testJWTSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherAlg, err := jwt.NewWithClaims(jwt.SigningMethodHS512, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "alice", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	}).SignedString(testJWTSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantCode      int
+		wantMessage   string
+	}{
+		{"valid", "Bearer " + valid, http.StatusOK, ""},
+		{"missing header", "", http.StatusUnauthorized, "missing or malformed Authorization header"},
+		{"basic scheme", "Basic YWxpY2U6c2VjcmV0", http.StatusUnauthorized, "missing or malformed Authorization header"},
+		{"empty token", "Bearer ", http.StatusUnauthorized, "missing or malformed Authorization header"},
+		{"no space", "Bearer" + valid, http.StatusUnauthorized, "missing or malformed Authorization header"},
+		{"garbage token", "Bearer not.a.jwt", http.StatusUnauthorized, "invalid token"},
+		{"expired", "Bearer " + expired, http.StatusUnauthorized, "token expired"},
+		{"wrong secret", "Bearer " + otherSecret, http.StatusUnauthorized, "invalid token"},
+		{"no expiry", "Bearer " + noExpiry, http.StatusUnauthorized, "invalid token"},
+		{"other algorithm", "Bearer " + otherAlg, http.StatusUnauthorized, "invalid token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := getWithAuth(r, "/me", tt.authorization)
+			if w.Code != tt.wantCode {
+				t.Fatalf("got %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantCode != http.StatusOK {
+				if !strings.Contains(w.Body.String(), tt.wantMessage) {
+					t.Fatalf("body %s doesn't say %q", w.Body, tt.wantMessage)
+				}
+				return
+			}
+
+			var me struct{ User, Role string }
+			if err := json.Unmarshal(w.Body.Bytes(), &me); err != nil {
+				t.Fatal(err)
+			}
+			if me.User != "alice" || me.Role != RoleUser {
+				t.Fatalf("context has %+v", me)
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	r := gin.New()
+	r.GET("/admin", AuthRequired(testJWTSecret), RequireRole(RoleAdmin), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	for role, want := range map[string]int{RoleAdmin: http.StatusNoContent, RoleUser: http.StatusForbidden, "": http.StatusForbidden} {
+		token, _, err := issueToken(testJWTSecret, "alice", role, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w := getWithAuth(r, "/admin", "Bearer "+token); w.Code != want {
+			t.Errorf("role %q: got %d, want %d", role, w.Code, want)
+		}
+	}
+	if w := getWithAuth(r, "/admin", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("no token: got %d, want 401", w.Code)
+	}
+}
+
+func TestDeleteUserRequiresAdminRole(t *testing.T) {
+	store := NewMemoryUserStore()
+	seedUsers(t, store, "Ann")
+	r := v1Router(t, routeDeps{Users: store, JWTSecret: testJWTSecret, Audit: NewAuditLogger(&MemoryAuditStore{})})
+
+	deleteAs := func(role string) int {
+		token, _, err := issueToken(testJWTSecret, "alice", role, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodDelete, "/v1/user/1", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if got := deleteAs(RoleUser); got != http.StatusForbidden {
+		t.Fatalf("user role: got %d, want 403", got)
+	}
+	if user, err := store.Get(context.Background(), 1); err != nil || user.DeletedAt != nil {
+		t.Fatalf("user deleted despite the 403: %+v, %v", user, err)
+	}
+	if got := deleteAs(RoleAdmin); got != http.StatusNoContent {
+		t.Fatalf("admin role: got %d, want 204", got)
+	}
+	if user, err := store.Get(context.Background(), 1); err != nil || user.DeletedAt == nil {
+		t.Fatalf("user not deleted by the admin: %+v, %v", user, err)
+	}
+}
+
+func TestLoginTimingHidesUnknownUsernames(t *testing.T) {
+	const cost = 10
+	store := NewMemoryUserStore()
+	hash, err := HashPassword("correct-horse", cost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []User{{Username: "alice", Name: "Alice", PasswordHash: hash}, {Username: "nopass", Name: "No Password"}} {
+		if _, err := store.Create(context.Background(), user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := gin.New()
+	r.POST("/login", loginHandler(store, testJWTSecret, NewSessionStore(time.Hour), testSessionSecret, cost))
+
+	// fastest is the quickest of a few 401 logins as username
+	fastest := func(username string) time.Duration {
+		best := time.Duration(math.MaxInt64)
+		for range 3 {
+			start := time.Now()
+			if w := post(r, "/login", `{"username":"`+username+`","password":"wrong-password"}`); w.Code != http.StatusUnauthorized {
+				t.Fatalf("%s: got %d, want 401", username, w.Code)
+			}
+			best = min(best, time.Since(start))
+		}
+		return best
+	}
+
+	// Without the dummy comparison, unknown usernames answer in
+	// microseconds, orders of magnitude faster than a cost 10 bcrypt check
+	wrongPassword := fastest("alice")
+	for _, username := range []string{"nobody", "nopass"} {
+		if got := fastest(username); got < wrongPassword/4 {
+			t.Errorf("%s answered in %s, a wrong password in %s: timing reveals the username", username, got, wrongPassword)
+		}
+	}
+}
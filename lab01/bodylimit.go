@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultMaxBodyBytes is the request body limit when none is configured
+const defaultMaxBodyBytes = 1 << 20
+
+// BodyLimit returns a middleware capping request bodies at maxBytes. Routes
+// listed in overrides (keyed by route pattern, e.g. "/v1/upload") use their
+// own limit instead. Bodies declared larger than the limit are rejected
+// up front; others fail with *http.MaxBytesError once the limit is read.
+func BodyLimit(maxBytes int64, overrides map[string]int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := maxBytes
+		if override, ok := overrides[c.FullPath()]; ok {
+			limit = override
+		}
+
+		if c.Request.ContentLength > limit {
+			respondBodyTooLarge(c)
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// isBodyTooLarge reports whether err came from reading past the body limit
+func isBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// respondBodyTooLarge writes the 413 response for an oversized body
+func respondBodyTooLarge(c *gin.Context) {
+	RespondError(c, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, "request body too large")
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bodyLimitRouter caps bodies at limit, except /upload at uploadLimit, and
+// echoes the size of what it read. /json binds its body like the API's
+// handlers do.
+func bodyLimitRouter(limit, uploadLimit int64) *gin.Engine {
+	r := gin.New()
+	r.Use(BodyLimit(limit, map[string]int64{"/upload": uploadLimit}))
+	read := func(c *gin.Context) {
+		data, err := io.ReadAll(c.Request.Body)
+		if isBodyTooLarge(err) {
+			respondBodyTooLarge(c)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"read": len(data)})
+	}
+	r.POST("/echo", read)
+	r.POST("/upload", read)
+	r.POST("/json", func(c *gin.Context) {
+		var body map[string]string
+		if err := c.ShouldBindJSON(&body); err != nil {
+			respondBindError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"read": len(body)})
+	})
+	return r
+}
+
+// postBody sends size bytes to target on h. A chunked body has no declared
+// length, so only reading it can find it's too large.
+func postBody(h http.Handler, target string, size int, chunked bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(strings.Repeat("a", size)))
+	if chunked {
+		req.ContentLength = -1
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestBodyLimit(t *testing.T) {
+	r := bodyLimitRouter(100, 1000)
+	tests := []struct {
+		name    string
+		target  string
+		size    int
+		chunked bool
+		want    int
+	}{
+		{"under the limit", "/echo", 99, false, http.StatusOK},
+		{"at the limit", "/echo", 100, false, http.StatusOK},
+		{"declared over the limit", "/echo", 101, false, http.StatusRequestEntityTooLarge},
+		{"chunked under the limit", "/echo", 100, true, http.StatusOK},
+		{"chunked over the limit", "/echo", 101, true, http.StatusRequestEntityTooLarge},
+		{"over the default on an overridden route", "/upload", 500, false, http.StatusOK},
+		{"over the override", "/upload", 1001, true, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postBody(r, tt.target, tt.size, tt.chunked)
+			if w.Code != tt.want {
+				t.Fatalf("got %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusRequestEntityTooLarge {
+				return
+			}
+			var body APIError
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Message != "request body too large" || body.Code != CodePayloadTooLarge {
+				t.Fatalf("body is %+v", body)
+			}
+		})
+	}
+}
+
+func TestBodyLimitWhileBindingJSON(t *testing.T) {
+	r := bodyLimitRouter(100, 1000)
+
+	// Valid JSON up to the limit, so decoding fails on the limit alone
+	body := `{"name":"` + strings.Repeat("a", 100) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/json", strings.NewReader(body))
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("got %d, want 413: %s", w.Code, w.Body)
+	}
+}
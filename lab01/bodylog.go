@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redactedValue replaces the values of sensitive fields in logged bodies
+const redactedValue = "[REDACTED]"
+
+var (
+	// sensitiveJSONField matches a JSON member whose name mentions a
+	// password, token or secret, including a string value cut off by
+	// truncation
+	sensitiveJSONField = regexp.MustCompile(`(?i)("[^"]*(?:password|token|secret)[^"]*"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+
+	// sensitiveFormField matches the same names in a URL-encoded form
+	sensitiveFormField = regexp.MustCompile(`(?i)((?:^|&)[^=&]*(?:password|token|secret)[^=&]*=)[^&]*`)
+)
+
+// redactBody blanks out the values of sensitive fields, matched by name, in
+// a JSON or URL-encoded form body
+func redactBody(body []byte, contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/x-www-form-urlencoded" {
+		return sensitiveFormField.ReplaceAllString(string(body), "${1}"+redactedValue)
+	}
+	return sensitiveJSONField.ReplaceAllString(string(body), `${1}"`+redactedValue+`"`)
+}
+
+// loggableContent reports whether a body of contentType is text worth
+// logging; uploads and other binary bodies are logged by size only
+func loggableContent(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "", strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/x-www-form-urlencoded":
+		return true
+	default:
+		return strings.HasSuffix(mediaType, "json") || strings.HasSuffix(mediaType, "xml")
+	}
+}
+
+// bodyAttr describes a body of size bytes, of which the first maxBytes were
+// captured, followed by at least one more if it was truncated
+func bodyAttr(key string, captured []byte, size, maxBytes int, contentType string) slog.Attr {
+	attrs := []any{slog.Int("bytes", size)}
+	if len(captured) > 0 {
+		if loggableContent(contentType) {
+			attrs = append(attrs, slog.String("content", redactBody(captured[:min(len(captured), maxBytes)], contentType)))
+		} else {
+			attrs = append(attrs, slog.String("content_type", contentType))
+		}
+	}
+	if len(captured) > maxBytes {
+		attrs = append(attrs, slog.Bool("truncated", true))
+	}
+	return slog.Group(key, attrs...)
+}
+
+// replayBody reads back bytes read ahead from a body before the rest of it
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+// bodyCaptureWriter keeps the first limit bytes of the response body and
+// counts all of it. It sits inside the gzip middleware, so both are of the
+// uncompressed body.
+type bodyCaptureWriter struct {
+	gin.ResponseWriter
+	body  bytes.Buffer
+	limit int
+	size  int
+}
+
+func (w *bodyCaptureWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyCaptureWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *bodyCaptureWriter) capture(b []byte) {
+	w.size += len(b)
+	if room := w.limit - w.body.Len(); room > 0 {
+		w.body.Write(b[:min(len(b), room)])
+	}
+}
+
+// BodyLogger returns a middleware that logs request and response bodies at
+// debug level, for debugging integrations. Only the first maxBytes of each
+// body are kept, and the values of fields whose names mention a password,
+// token or secret are redacted. The request body is restored for the handler
+// by replaying the bytes read ahead, so body size limits still apply to the
+// rest. Nothing is captured while logger has debug disabled.
+func BodyLogger(logger *slog.Logger, maxBytes int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if !logger.Enabled(ctx, slog.LevelDebug) || c.IsWebsocket() {
+			c.Next()
+			return
+		}
+
+		// Read ahead one byte past the limit to tell whether it was truncated,
+		// then hand the handler those bytes followed by the rest of the body
+		var requestBody []byte
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			original := c.Request.Body
+			requestBody, _ = io.ReadAll(io.LimitReader(original, int64(maxBytes)+1))
+			c.Request.Body = replayBody{Reader: io.MultiReader(bytes.NewReader(requestBody), original), Closer: original}
+		}
+
+		writer := &bodyCaptureWriter{ResponseWriter: c.Writer, limit: maxBytes + 1}
+		c.Writer = writer
+
+		c.Next()
+
+		c.Writer = writer.ResponseWriter
+
+		// Chunked bodies have no length up front; report what was read ahead
+		requestSize := int(c.Request.ContentLength)
+		if requestSize < 0 {
+			requestSize = len(requestBody)
+		}
+
+		logger.LogAttrs(ctx, slog.LevelDebug, "request bodies",
+			slog.String("request_id", RequestIDFromContext(c)),
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", writer.Status()),
+			bodyAttr("request", requestBody, requestSize, maxBytes, c.Request.Header.Get("Content-Type")),
+			bodyAttr("response", writer.body.Bytes(), writer.size, maxBytes, writer.Header().Get("Content-Type")),
+		)
+	}
+}
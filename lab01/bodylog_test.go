@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bodyLogRouter serves POST /login, which answers with a token and the body
+// it read, behind BodyLogger(logger, maxBytes)
+func bodyLogRouter(logger *slog.Logger, maxBytes int) *gin.Engine {
+	r := gin.New()
+	r.Use(BodyLogger(logger, maxBytes))
+	r.POST("/login", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.JSON(http.StatusOK, gin.H{"access_token": "abc.def.ghi", "received": string(body)})
+	})
+	return r
+}
+
+// loggedBodies decodes the single "request bodies" line logged to out
+func loggedBodies(t *testing.T, out string) map[string]any {
+	t.Helper()
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d log lines, want 1: %s", len(lines), out)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["msg"] != "request bodies" {
+		t.Fatalf("logged %v", entry["msg"])
+	}
+	return entry
+}
+
+func TestBodyLoggerLogsAndRedactsBodies(t *testing.T) {
+	logger, out := captureLogger(t, "debug", "json")
+	const body = `{"username":"ann","password":"hunter2"}`
+	w := post(bodyLogRouter(logger, 1024), "/login", body)
+
+	// The handler still reads the whole, unredacted body
+	var resp struct{ Received string }
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Received != body {
+		t.Fatalf("handler read %q, want %q", resp.Received, body)
+	}
+
+	entry := loggedBodies(t, out.String())
+	request := entry["request"].(map[string]any)
+	response := entry["response"].(map[string]any)
+	if got := request["content"]; got != `{"username":"ann","password":"[REDACTED]"}` {
+		t.Errorf("logged request %v", got)
+	}
+	if request["bytes"] != float64(len(body)) || entry["status"] != float64(http.StatusOK) {
+		t.Errorf("logged %v bytes and status %v", request["bytes"], entry["status"])
+	}
+	content, _ := response["content"].(string)
+	if !strings.Contains(content, `"access_token":"[REDACTED]"`) || strings.Contains(content, "abc.def.ghi") || strings.Contains(content, "hunter2") {
+		t.Errorf("logged response %q, want the token redacted", content)
+	}
+	if strings.Contains(out.String(), "hunter2") {
+		t.Fatalf("password reached the log: %s", out)
+	}
+}
+
+func TestBodyLoggerTruncatesLongBodies(t *testing.T) {
+	logger, out := captureLogger(t, "debug", "json")
+	body := `{"note":"` + strings.Repeat("x", 100) + `"}`
+	w := post(bodyLogRouter(logger, 16), "/login", body)
+	if !strings.Contains(w.Body.String(), strings.Repeat("x", 100)) {
+		t.Fatalf("handler didn't get the full body: %s", w.Body)
+	}
+
+	request := loggedBodies(t, out.String())["request"].(map[string]any)
+	if request["content"] != body[:16] || request["truncated"] != true || request["bytes"] != float64(len(body)) {
+		t.Fatalf("logged %v", request)
+	}
+}
+
+func TestBodyLoggerSilentWithoutDebug(t *testing.T) {
+	logger, out := captureLogger(t, "info", "json")
+	if w := post(bodyLogRouter(logger, 1024), "/login", `{"password":"hunter2"}`); w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("logged at info level: %s", out)
+	}
+}
+
+func TestBodyLoggerLogsBinaryBodiesBySize(t *testing.T) {
+	logger, out := captureLogger(t, "debug", "json")
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader("\x89PNG\r\n"))
+	req.Header.Set("Content-Type", "image/png")
+	bodyLogRouter(logger, 1024).ServeHTTP(httptest.NewRecorder(), req)
+
+	request := loggedBodies(t, out.String())["request"].(map[string]any)
+	if _, logged := request["content"]; logged || request["content_type"] != "image/png" || request["bytes"] != float64(6) {
+		t.Fatalf("logged %v", request)
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		body        string
+		contentType string
+		want        string
+	}{
+		{`{"new_password": "a\"b", "id": 1}`, "application/json", `{"new_password": "[REDACTED]", "id": 1}`},
+		{`{"Token":12345,"secret_key":null}`, "application/json", `{"Token":"[REDACTED]","secret_key":"[REDACTED]"}`},
+		// A value cut off by truncation is still redacted
+		{`{"password":"hun`, "application/json", `{"password":"[REDACTED]"`},
+		{"user=ann&password=hunter2&csrf_token=x", "application/x-www-form-urlencoded; charset=utf-8", "user=ann&password=[REDACTED]&csrf_token=[REDACTED]"},
+		{`{"username":"ann"}`, "application/json", `{"username":"ann"}`},
+	}
+	for _, tt := range tests {
+		if got := redactBody([]byte(tt.body), tt.contentType); got != tt.want {
+			t.Errorf("redactBody(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestLogBodiesOffByDefault(t *testing.T) {
+	cfg, err := loadTestConfig(t, map[string]string{"LOG_BODIES": "", "GIN_MODE": "release"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LogBodies {
+		t.Fatal("LOG_BODIES defaulted to on")
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ErrSearchUnavailable is returned without calling the backend while its
+// circuit breaker is open
+var ErrSearchUnavailable = errors.New("search unavailable")
+
+// breakerState is the state of a circuit breaker, exported as the value of
+// the search_circuit_breaker_state gauge
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerHalfOpen
+	breakerOpen
+)
+
+// searchBreakerState reports each search backend's breaker: 0 closed, 1
+// half-open, 2 open
+var searchBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "search_circuit_breaker_state",
+	Help: "State of the search backend circuit breaker (0 closed, 1 half-open, 2 open).",
+}, []string{"backend"})
+
+// BreakingSearcher stops calling another Searcher after threshold
+// consecutive failures. While open every search fails fast with
+// ErrSearchUnavailable; after cooldown a single search is let through to
+// probe the backend, closing the breaker if it succeeds and reopening it if
+// it fails. Searches abandoned by the client don't count either way.
+type BreakingSearcher struct {
+	next      Searcher
+	name      string
+	threshold int
+	cooldown  time.Duration
+	gauge     prometheus.Gauge
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewBreakingSearcher wraps next with a breaker reported under name
+func NewBreakingSearcher(next Searcher, name string, threshold int, cooldown time.Duration) *BreakingSearcher {
+	s := &BreakingSearcher{
+		next:      next,
+		name:      name,
+		threshold: threshold,
+		cooldown:  cooldown,
+		gauge:     searchBreakerState.WithLabelValues(name),
+	}
+	s.gauge.Set(float64(breakerClosed))
+	return s
+}
+
+func (s *BreakingSearcher) Search(ctx context.Context, query string, limit, offset int) ([]Result, int, error) {
+	return s.call(func() ([]Result, int, error) {
+		return s.next.Search(ctx, query, limit, offset)
+	})
+}
+
+func (s *BreakingSearcher) SearchAfter(ctx context.Context, query string, limit int, afterID int64) ([]Result, int, error) {
+	return s.call(func() ([]Result, int, error) {
+		return s.next.SearchAfter(ctx, query, limit, afterID)
+	})
+}
+
+// call runs search if the breaker allows it and records the outcome. A
+// panic counts as a failure and is re-raised once recorded, so a panicking
+// probe can't leave the breaker half-open for good.
+func (s *BreakingSearcher) call(search func() ([]Result, int, error)) (results []Result, total int, err error) {
+	probe, err := s.allow()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			s.record(probe, fmt.Errorf("search panicked: %v", recovered))
+			panic(recovered)
+		}
+		s.record(probe, err)
+	}()
+	return search()
+}
+
+// allow reports whether a search may reach the backend, and whether it's
+// the probe of a half-open breaker, moving an open breaker to half-open once
+// its cooldown has passed
+func (s *BreakingSearcher) allow() (probe bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch s.state {
+	case breakerOpen:
+		if time.Since(s.openedAt) < s.cooldown {
+			return false, ErrSearchUnavailable
+		}
+		s.setState(breakerHalfOpen)
+		s.probing = true
+		return true, nil
+	case breakerHalfOpen:
+		// Only one probe at a time; the rest fail fast until it returns
+		if s.probing {
+			return false, ErrSearchUnavailable
+		}
+		s.probing = true
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// record updates the breaker with the outcome of a search that was allowed
+func (s *BreakingSearcher) record(probe bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if probe {
+		s.probing = false
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		// The client gave up, which says nothing about the backend
+	case err == nil:
+		s.failures = 0
+		if s.state != breakerClosed {
+			slog.Info("Search backend recovered, closing circuit breaker", "backend", s.name)
+			s.setState(breakerClosed)
+		}
+	default:
+		s.failures++
+		if probe || (s.state == breakerClosed && s.failures >= s.threshold) {
+			slog.Warn("Search backend failing, opening circuit breaker",
+				"backend", s.name, "consecutive_failures", s.failures, "cooldown", s.cooldown, "error", err)
+			s.openedAt = time.Now()
+			s.setState(breakerOpen)
+		}
+	}
+}
+
+// setState moves the breaker to state; the caller holds s.mu
+func (s *BreakingSearcher) setState(state breakerState) {
+	s.state = state
+	s.gauge.Set(float64(state))
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// assertBreaker checks the breaker's state and its gauge
+func assertBreaker(t *testing.T, s *BreakingSearcher, want breakerState) {
+	t.Helper()
+	s.mu.Lock()
+	state := s.state
+	s.mu.Unlock()
+	if state != want {
+		t.Fatalf("state %d, want %d", state, want)
+	}
+	if got := testutil.ToFloat64(searchBreakerState.WithLabelValues(s.name)); got != float64(want) {
+		t.Fatalf("gauge %v, want %d", got, want)
+	}
+}
+
+func TestBreakingSearcherOpensAfterThreshold(t *testing.T) {
+	backend := &fakeSearcher{err: errors.New("backend down")}
+	breaker := NewBreakingSearcher(backend, t.Name(), 3, time.Hour)
+
+	for i := range 3 {
+		assertBreaker(t, breaker, breakerClosed)
+		if _, _, err := breaker.Search(context.Background(), "go", 10, 0); err != backend.err {
+			t.Fatalf("search %d: got %v, want the backend error", i, err)
+		}
+	}
+	assertBreaker(t, breaker, breakerOpen)
+
+	// Open: fail fast without reaching the backend
+	_, _, err := breaker.Search(context.Background(), "go", 10, 0)
+	if !errors.Is(err, ErrSearchUnavailable) {
+		t.Fatalf("got %v, want ErrSearchUnavailable", err)
+	}
+	if backend.calls != 3 {
+		t.Fatalf("backend called %d times, want 3", backend.calls)
+	}
+}
+
+func TestBreakingSearcherSuccessResetsFailures(t *testing.T) {
+	backend := &fakeSearcher{err: errors.New("backend down")}
+	breaker := NewBreakingSearcher(backend, t.Name(), 2, time.Hour)
+
+	breaker.Search(context.Background(), "go", 10, 0)
+	backend.err = nil
+	breaker.Search(context.Background(), "go", 10, 0)
+	backend.err = errors.New("backend down")
+	breaker.Search(context.Background(), "go", 10, 0)
+
+	assertBreaker(t, breaker, breakerClosed)
+}
+
+func TestBreakingSearcherIgnoresCanceledSearches(t *testing.T) {
+	backend := &fakeSearcher{err: context.Canceled}
+	breaker := NewBreakingSearcher(backend, t.Name(), 1, time.Hour)
+
+	breaker.Search(context.Background(), "go", 10, 0)
+	assertBreaker(t, breaker, breakerClosed)
+}
+
+func TestBreakingSearcherRecoversAfterCooldown(t *testing.T) {
+	backend := &fakeSearcher{err: errors.New("backend down")}
+	breaker := NewBreakingSearcher(backend, t.Name(), 1, 20*time.Millisecond)
+
+	breaker.Search(context.Background(), "go", 10, 0)
+	assertBreaker(t, breaker, breakerOpen)
+
+	// A failed probe reopens it for another cooldown
+	time.Sleep(30 * time.Millisecond)
+	breaker.Search(context.Background(), "go", 10, 0)
+	assertBreaker(t, breaker, breakerOpen)
+	if _, _, err := breaker.Search(context.Background(), "go", 10, 0); !errors.Is(err, ErrSearchUnavailable) {
+		t.Fatalf("got %v, want ErrSearchUnavailable right after a failed probe", err)
+	}
+
+	// Once the backend heals the next probe closes it
+	backend.err = nil
+	time.Sleep(30 * time.Millisecond)
+	if _, _, err := breaker.Search(context.Background(), "go", 10, 0); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	assertBreaker(t, breaker, breakerClosed)
+	if _, _, err := breaker.SearchAfter(context.Background(), "go", 10, 0); err != nil {
+		t.Fatalf("search after recovery: %v", err)
+	}
+}
+
+func TestBreakingSearcherAllowsOneProbeAtATime(t *testing.T) {
+	backend := &gatedSearcher{fail: errors.New("backend down")}
+	breaker := NewBreakingSearcher(backend, t.Name(), 1, 10*time.Millisecond)
+	breaker.Search(context.Background(), "go", 10, 0)
+	time.Sleep(20 * time.Millisecond)
+
+	backend.fail = nil
+	backend.release = make(chan struct{})
+	probeDone := make(chan error, 1)
+	go func() {
+		_, _, err := breaker.Search(context.Background(), "go", 10, 0)
+		probeDone <- err
+	}()
+	for backend.calls.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, _, err := breaker.Search(context.Background(), "go", 10, 0); !errors.Is(err, ErrSearchUnavailable) {
+		t.Fatalf("got %v while the probe runs, want ErrSearchUnavailable", err)
+	}
+	close(backend.release)
+	if err := <-probeDone; err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	assertBreaker(t, breaker, breakerClosed)
+}
+
+func TestBreakingSearcherPanickingProbeReopens(t *testing.T) {
+	backend := &gatedSearcher{fail: errors.New("backend down")}
+	breaker := NewBreakingSearcher(backend, t.Name(), 1, 10*time.Millisecond)
+	breaker.Search(context.Background(), "go", 10, 0)
+	time.Sleep(20 * time.Millisecond)
+
+	backend.panic = true
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("the probe's panic should be re-raised")
+			}
+		}()
+		breaker.Search(context.Background(), "go", 10, 0)
+	}()
+	assertBreaker(t, breaker, breakerOpen)
+
+	// The next probe is let through rather than the breaker being stuck
+	backend.panic = false
+	backend.fail = nil
+	time.Sleep(20 * time.Millisecond)
+	if _, _, err := breaker.Search(context.Background(), "go", 10, 0); err != nil {
+		t.Fatalf("probe after the panic: %v", err)
+	}
+	assertBreaker(t, breaker, breakerClosed)
+}
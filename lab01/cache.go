@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrCacheMiss is returned by a Cache when the key isn't present
+var ErrCacheMiss = errors.New("cache miss")
+
+// Cache stores byte values with an expiry
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// RedisCache is a Cache backed by Redis
+type RedisCache struct {
+	client *redis.Client
+}
+
+// NewRedisCache creates a cache from a redis:// URL
+func NewRedisCache(redisURL string) (*RedisCache, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse REDIS_URL: %w", err)
+	}
+	return &RedisCache{client: redis.NewClient(opts)}, nil
+}
+
+// Close releases the Redis connection pool
+func (r *RedisCache) Close() error {
+	return r.client.Close()
+}
+
+// Name identifies the cache in readiness responses
+func (r *RedisCache) Name() string {
+	return "redis"
+}
+
+// Check reports whether Redis is reachable
+func (r *RedisCache) Check(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// Critical reports that searches still work, uncached, without Redis
+func (r *RedisCache) Critical() bool {
+	return false
+}
+
+func (r *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrCacheMiss
+	}
+	return value, err
+}
+
+func (r *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+// Cache status values reported in the X-Cache response header
+const (
+	cacheHit  = "HIT"
+	cacheMiss = "MISS"
+)
+
+// cacheStatusKey is the context key for recording cache hits and misses
+type cacheStatusKey struct{}
+
+// withCacheStatus returns a context in which a CachingSearcher records whether
+// the search was served from cache
+func withCacheStatus(ctx context.Context) (context.Context, *string) {
+	status := new(string)
+	return context.WithValue(ctx, cacheStatusKey{}, status), status
+}
+
+// setCacheStatus records status if the caller asked for it
+func setCacheStatus(ctx context.Context, status string) {
+	if recorded, ok := ctx.Value(cacheStatusKey{}).(*string); ok {
+		*recorded = status
+	}
+}
+
+// cachedSearch is the serialized form of a search result page
+type cachedSearch struct {
+	Results []Result `json:"results"`
+	Total   int      `json:"total"`
+}
+
+// CachingSearcher caches another Searcher's results. Cache failures are
+// logged and the underlying searcher is called directly.
+type CachingSearcher struct {
+	next   Searcher
+	cache  Cache
+	prefix string
+	ttl    time.Duration
+}
+
+// NewCachingSearcher wraps next with a cache whose entries expire after ttl.
+// Keys start with prefix so searchers sharing a cache don't collide.
+func NewCachingSearcher(next Searcher, cache Cache, prefix string, ttl time.Duration) *CachingSearcher {
+	return &CachingSearcher{next: next, cache: cache, prefix: prefix, ttl: ttl}
+}
+
+func (s *CachingSearcher) Search(ctx context.Context, query string, limit, offset int) ([]Result, int, error) {
+	key := fmt.Sprintf("%s:%s:%d:%d", s.prefix, query, limit, offset)
+	return s.cached(ctx, key, func() ([]Result, int, error) {
+		return s.next.Search(ctx, query, limit, offset)
+	})
+}
+
+func (s *CachingSearcher) SearchAfter(ctx context.Context, query string, limit int, afterID int64) ([]Result, int, error) {
+	key := fmt.Sprintf("%s:%s:%d:after:%d", s.prefix, query, limit, afterID)
+	return s.cached(ctx, key, func() ([]Result, int, error) {
+		return s.next.SearchAfter(ctx, query, limit, afterID)
+	})
+}
+
+// cached returns the page stored under key, calling fetch and caching its
+// result on a miss
+func (s *CachingSearcher) cached(ctx context.Context, key string, fetch func() ([]Result, int, error)) ([]Result, int, error) {
+	data, err := s.cache.Get(ctx, key)
+	if err == nil {
+		var cached cachedSearch
+		if err := json.Unmarshal(data, &cached); err == nil {
+			setCacheStatus(ctx, cacheHit)
+			return cached.Results, cached.Total, nil
+		}
+	} else if !errors.Is(err, ErrCacheMiss) {
+		slog.Warn("Search cache unavailable, querying backend", "error", err)
+	}
+
+	setCacheStatus(ctx, cacheMiss)
+
+	results, total, err := fetch()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if data, err := json.Marshal(cachedSearch{Results: results, Total: total}); err == nil {
+		if err := s.cache.Set(ctx, key, data, s.ttl); err != nil {
+			slog.Warn("Failed to cache search results", "error", err)
+		}
+	}
+
+	return results, total, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCache is an in-memory Cache that records TTLs and can be made to fail
+type fakeCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	ttls    map[string]time.Duration
+	getErr  error
+	setErr  error
+}
+
+func newFakeCache() *fakeCache {
+	return &fakeCache{entries: make(map[string][]byte), ttls: make(map[string]time.Duration)}
+}
+
+func (f *fakeCache) Get(ctx context.Context, key string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	value, ok := f.entries[key]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	return value, nil
+}
+
+func (f *fakeCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.setErr != nil {
+		return f.setErr
+	}
+	f.entries[key] = value
+	f.ttls[key] = ttl
+	return nil
+}
+
+func TestCachingSearcherHitAndMiss(t *testing.T) {
+	backend := &fakeSearcher{results: []Result{{ID: 3, Title: "Building APIs with Gin"}}, total: 1}
+	cache := newFakeCache()
+	r := searchRouter(NewCachingSearcher(backend, cache, "search", time.Minute))
+
+	first := get(r, "/search?q=gin&limit=10")
+	if first.Code != http.StatusOK || first.Header().Get("X-Cache") != cacheMiss {
+		t.Fatalf("first search: got %d, X-Cache %q", first.Code, first.Header().Get("X-Cache"))
+	}
+	if ttl, ok := cache.ttls["search:gin:10:0"]; !ok || ttl != time.Minute {
+		t.Fatalf("cache holds %v", cache.ttls)
+	}
+
+	second := get(r, "/search?q=gin&limit=10")
+	if second.Header().Get("X-Cache") != cacheHit {
+		t.Fatalf("repeated search: X-Cache %q", second.Header().Get("X-Cache"))
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Fatalf("cached body %s differs from %s", second.Body, first.Body)
+	}
+	if backend.calls != 1 {
+		t.Fatalf("backend searched %d times, want 1", backend.calls)
+	}
+
+	// Another page is a different key
+	if w := get(r, "/search?q=gin&limit=10&page=2"); w.Header().Get("X-Cache") != cacheMiss || backend.calls != 2 {
+		t.Fatalf("next page: X-Cache %q, %d backend calls", w.Header().Get("X-Cache"), backend.calls)
+	}
+}
+
+func TestCachingSearcherDegradesWithoutCache(t *testing.T) {
+	backend := &fakeSearcher{results: []Result{{ID: 3}}, total: 1}
+	cache := newFakeCache()
+	cache.getErr = errors.New("connection refused")
+	cache.setErr = errors.New("connection refused")
+	searcher := NewCachingSearcher(backend, cache, "search", time.Minute)
+
+	for range 2 {
+		results, total, err := searcher.Search(context.Background(), "go", 10, 0)
+		if err != nil || total != 1 || len(results) != 1 {
+			t.Fatalf("got %v, %d, %v", results, total, err)
+		}
+	}
+	if backend.calls != 2 {
+		t.Fatalf("backend searched %d times, want 2", backend.calls)
+	}
+}
+
+func TestCachingSearcherSkipsBadEntriesAndErrors(t *testing.T) {
+	backend := &fakeSearcher{err: errors.New("backend down")}
+	cache := newFakeCache()
+	searcher := NewCachingSearcher(backend, cache, "search", time.Minute)
+
+	if _, _, err := searcher.Search(context.Background(), "go", 10, 0); err == nil {
+		t.Fatal("backend error was swallowed")
+	}
+	if len(cache.entries) != 0 {
+		t.Fatalf("failed search was cached: %v", cache.entries)
+	}
+
+	// An entry that doesn't decode is treated as a miss and replaced
+	backend.err, backend.results, backend.total = nil, []Result{{ID: 1}}, 1
+	cache.entries["search:go:10:0"] = []byte("not json")
+	ctx, status := withCacheStatus(context.Background())
+	results, _, err := searcher.Search(ctx, "go", 10, 0)
+	if err != nil || len(results) != 1 || *status != cacheMiss {
+		t.Fatalf("got %v, %v, status %q", results, err, *status)
+	}
+	if string(cache.entries["search:go:10:0"]) == "not json" {
+		t.Fatal("bad entry wasn't replaced")
+	}
+}
+
+func TestCachingSearcherKeysSearchAfterSeparately(t *testing.T) {
+	backend := &fakeSearcher{results: []Result{{ID: 5}}, total: 1}
+	cache := newFakeCache()
+	searcher := NewCachingSearcher(backend, cache, "search-terms", time.Minute)
+
+	searcher.Search(context.Background(), "go", 10, 5)
+	searcher.SearchAfter(context.Background(), "go", 10, 5)
+	for _, key := range []string{"search-terms:go:10:5", "search-terms:go:10:after:5"} {
+		if _, ok := cache.entries[key]; !ok {
+			t.Errorf("no entry for %s in %v", key, cache.ttls)
+		}
+	}
+	if backend.calls != 2 {
+		t.Fatalf("backend searched %d times, want 2", backend.calls)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserChanges wakes everyone waiting for a user to change. Each Notify
+// closes the channel handed out by Wait and starts a new one, so any number
+// of waiters are released at once and none can miss a change made after
+// they called Wait.
+type UserChanges struct {
+	mu      sync.Mutex
+	changed chan struct{}
+}
+
+// NewUserChanges creates a broadcaster with no waiters
+func NewUserChanges() *UserChanges {
+	return &UserChanges{changed: make(chan struct{})}
+}
+
+// Wait returns a channel that is closed by the next Notify
+func (u *UserChanges) Wait() <-chan struct{} {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.changed
+}
+
+// Notify releases everyone currently waiting
+func (u *UserChanges) Notify() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	close(u.changed)
+	u.changed = make(chan struct{})
+}
+
+// notifyingUserStore is a UserStore that calls Notify on changes after each
+// successful mutation, whichever store it wraps
+type notifyingUserStore struct {
+	UserStore
+	changes *UserChanges
+}
+
+// NewNotifyingUserStore wraps store so its mutations notify changes
+func NewNotifyingUserStore(store UserStore, changes *UserChanges) UserStore {
+	return &notifyingUserStore{UserStore: store, changes: changes}
+}
+
+func (s *notifyingUserStore) Create(ctx context.Context, user User) (User, error) {
+	user, err := s.UserStore.Create(ctx, user)
+	s.notify(err)
+	return user, err
+}
+
+func (s *notifyingUserStore) Update(ctx context.Context, user User) (User, error) {
+	user, err := s.UserStore.Update(ctx, user)
+	s.notify(err)
+	return user, err
+}
+
+func (s *notifyingUserStore) Delete(ctx context.Context, id int64) error {
+	err := s.UserStore.Delete(ctx, id)
+	s.notify(err)
+	return err
+}
+
+func (s *notifyingUserStore) Restore(ctx context.Context, id int64) (User, error) {
+	user, err := s.UserStore.Restore(ctx, id)
+	s.notify(err)
+	return user, err
+}
+
+// notify wakes waiters unless the mutation failed
+func (s *notifyingUserStore) notify(err error) {
+	if err == nil {
+		s.changes.Notify()
+	}
+}
+
+// UserChangesResponse represents the response structure for a long poll
+type UserChangesResponse struct {
+	// Users changed after since, oldest change first; soft-deleted users
+	// are included with deleted_at set
+	Users []User `json:"users"`
+	// NextSince is the updated_at of the last user, to pass as since on the
+	// next poll
+	NextSince string `json:"next_since"`
+}
+
+// userChangesHandler long-polls for users created, updated, deleted or
+// restored after since. It answers as soon as there are changes, returning
+// up to maxLimit() of them, or with 204 once timeout passes without any.
+// Polls also end with 204 when shutdown begins, so clients reconnect to
+// another instance.
+//
+//	@Summary	Wait for user changes
+//	@Tags		users
+//	@Produce	json
+//	@Param		since	query		string	true	"RFC 3339 timestamp; changes after it are returned"
+//	@Param		tz		query		string	false	"IANA time zone for timestamps, e.g. America/New_York"	default(UTC)
+//	@Success	200		{object}	UserChangesResponse
+//	@Success	204		"No changes before the poll timed out"
+//	@Failure	400		{object}	APIError
+//	@Router		/v1/users/changes [get]
+func userChangesHandler(store UserStore, changes *UserChanges, shutdown context.Context, timeout time.Duration, maxLimit func() int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		since, err := time.Parse(time.RFC3339Nano, c.Query("since"))
+		if err != nil {
+			RespondError(c, http.StatusBadRequest, CodeInvalidQuery, "Query parameter 'since' must be an RFC 3339 timestamp")
+			return
+		}
+
+		ctx := c.Request.Context()
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		for {
+			// Take the channel before looking, so a change made in between
+			// still wakes us
+			changed := changes.Wait()
+
+			users, _, err := store.List(ctx, UserListOptions{
+				SortBy:         "updated_at",
+				Limit:          maxLimit(),
+				IncludeDeleted: true,
+				UpdatedAfter:   since,
+			})
+			if err != nil {
+				respondUserError(c, err)
+				return
+			}
+			if len(users) > 0 {
+				next := users[len(users)-1].UpdatedAt.UTC().Format(time.RFC3339Nano)
+				loc := LocationFromContext(c)
+				for i := range users {
+					users[i] = users[i].In(loc)
+				}
+				RespondData(c, http.StatusOK, UserChangesResponse{Users: users, NextSince: next})
+				return
+			}
+
+			select {
+			case <-changed:
+			case <-timer.C:
+				c.Status(http.StatusNoContent)
+				return
+			case <-shutdown.Done():
+				c.Status(http.StatusNoContent)
+				return
+			case <-ctx.Done():
+				// The client went away, or the request timed out and the
+				// Timeout middleware has already answered
+				c.AbortWithStatus(statusClientClosedRequest)
+				return
+			}
+		}
+	}
+}
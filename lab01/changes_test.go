@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// changesRouter serves /users/changes over store, which notifies changes,
+// with polls giving up after timeout or when shutdown is canceled
+func changesRouter(store UserStore, changes *UserChanges, shutdown context.Context, timeout time.Duration) *gin.Engine {
+	r := gin.New()
+	r.GET("/users/changes", userChangesHandler(store, changes, shutdown, timeout, func() int { return 50 }))
+	return r
+}
+
+// pollTarget is the /users/changes URL for changes after since
+func pollTarget(since time.Time) string {
+	return "/users/changes?since=" + since.UTC().Format(time.RFC3339Nano)
+}
+
+// decodeChanges decodes a 200 long-poll response
+func decodeChanges(t *testing.T, w *httptest.ResponseRecorder) UserChangesResponse {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp UserChangesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestUserChangesCreationUnblocksPoll(t *testing.T) {
+	changes := NewUserChanges()
+	store := NewNotifyingUserStore(NewMemoryUserStore(), changes)
+	r := changesRouter(store, changes, context.Background(), 10*time.Second)
+
+	since := time.Now()
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() { done <- get(r, pollTarget(since)) }()
+
+	select {
+	case w := <-done:
+		t.Fatalf("poll answered %d before any change", w.Code)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	created := seedUsers(t, store, "ann")[0]
+	select {
+	case w := <-done:
+		resp := decodeChanges(t, w)
+		if len(resp.Users) != 1 || resp.Users[0].ID != created.ID {
+			t.Fatalf("got %+v, want the created user", resp.Users)
+		}
+		if resp.NextSince != created.UpdatedAt.UTC().Format(time.RFC3339Nano) {
+			t.Fatalf("next_since is %q, want the user's updated_at", resp.NextSince)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("creating a user didn't wake the poll")
+	}
+}
+
+func TestUserChangesReturnsEarlierChangesImmediately(t *testing.T) {
+	changes := NewUserChanges()
+	store := NewNotifyingUserStore(NewMemoryUserStore(), changes)
+	before := seedUsers(t, store, "ann")[0]
+	after := seedUsers(t, store, "bob", "cyd")
+	if err := store.Delete(context.Background(), after[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	r := changesRouter(store, changes, context.Background(), 10*time.Second)
+
+	resp := decodeChanges(t, get(r, pollTarget(before.UpdatedAt)))
+	if len(resp.Users) != 2 || resp.Users[0].ID != after[0].ID || resp.Users[1].ID != after[1].ID {
+		t.Fatalf("got %+v, want the two users changed after since, oldest first", resp.Users)
+	}
+	if resp.Users[1].DeletedAt == nil {
+		t.Fatal("deleted user reported without deleted_at")
+	}
+}
+
+func TestUserChangesTimesOutWithNoContent(t *testing.T) {
+	changes := NewUserChanges()
+	store := NewNotifyingUserStore(NewMemoryUserStore(), changes)
+	seedUsers(t, store, "ann")
+	r := changesRouter(store, changes, context.Background(), 50*time.Millisecond)
+
+	start := time.Now()
+	if w := get(r, pollTarget(time.Now())); w.Code != http.StatusNoContent {
+		t.Fatalf("status %d, want 204", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("answered after %v, before the timeout", elapsed)
+	}
+}
+
+func TestUserChangesEndsOnShutdownAndDisconnect(t *testing.T) {
+	changes := NewUserChanges()
+	store := NewNotifyingUserStore(NewMemoryUserStore(), changes)
+	shutdown, stop := context.WithCancel(context.Background())
+	r := changesRouter(store, changes, shutdown, 10*time.Second)
+
+	done := make(chan int, 1)
+	go func() { done <- get(r, pollTarget(time.Now())).Code }()
+	time.Sleep(20 * time.Millisecond)
+	stop()
+	select {
+	case code := <-done:
+		if code != http.StatusNoContent {
+			t.Fatalf("shutdown: status %d, want 204", code)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("shutdown didn't end the poll")
+	}
+
+	r = changesRouter(store, changes, context.Background(), 10*time.Second)
+	ctx, disconnect := context.WithCancel(context.Background())
+	go func() {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, pollTarget(time.Now()), nil).WithContext(ctx))
+		done <- w.Code
+	}()
+	time.Sleep(20 * time.Millisecond)
+	disconnect()
+	select {
+	case code := <-done:
+		if code != statusClientClosedRequest {
+			t.Fatalf("disconnect: status %d, want %d", code, statusClientClosedRequest)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("client disconnect didn't end the poll")
+	}
+}
+
+func TestUserChangesRejectsBadSince(t *testing.T) {
+	r := changesRouter(NewMemoryUserStore(), NewUserChanges(), context.Background(), time.Second)
+	for _, target := range []string{"/users/changes", "/users/changes?since=yesterday", "/users/changes?since=2024-01-01"} {
+		if w := get(r, target); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, w.Code)
+		}
+	}
+}
+
+func TestUserChangesNotifyReleasesEveryWaiter(t *testing.T) {
+	changes := NewUserChanges()
+	first, second := changes.Wait(), changes.Wait()
+	changes.Notify()
+	for i, changed := range []<-chan struct{}{first, second} {
+		select {
+		case <-changed:
+		default:
+			t.Fatalf("waiter %d not released", i)
+		}
+	}
+
+	// Later waiters wait for the next change, and failed mutations don't notify
+	next := changes.Wait()
+	store := NewNotifyingUserStore(NewMemoryUserStore(), changes)
+	if err := store.Delete(context.Background(), 999); err == nil {
+		t.Fatal("deleting a missing user succeeded")
+	}
+	select {
+	case <-next:
+		t.Fatal("released by a change that never happened")
+	default:
+	}
+}
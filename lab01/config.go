@@ -0,0 +1,741 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Config holds all settings the service reads at startup
+type Config struct {
+	// Server
+	Port string
+	// GRPCPort is where the gRPC server listens; it's disabled when empty
+	GRPCPort        string
+	GinMode         string
+	ShutdownTimeout time.Duration
+	// StartupTimeout bounds how long startup waits for critical
+	// dependencies before giving up
+	StartupTimeout  time.Duration
+	EnablePprof     bool
+	MaintenanceMode bool
+	OTLPEndpoint    string
+	RequestTimeout  time.Duration
+	ServerTimeouts  ServerTimeouts
+	// MaxConcurrentRequests caps requests handled at once; 0 is unlimited
+	MaxConcurrentRequests int
+
+	// Logging
+	LogLevel             string
+	LogFormat            string
+	SlowRequestThreshold time.Duration
+	// LogSampleRate logs one in this many successful requests; errors and
+	// slow requests are always logged
+	LogSampleRate int
+	AccessLog     LogFileConfig
+	// LogBodies logs request and response bodies, up to LogBodyMaxBytes of
+	// each, at debug level; it's off unless explicitly enabled
+	LogBodies       bool
+	LogBodyMaxBytes int
+
+	// Middleware
+	CORSAllowedOrigins []string
+	RateLimitRPS       int
+	RateLimitBurst     int
+	GzipMinSize        int
+	MaxBodyBytes       int64
+
+	// Network
+	TrustedProxies     []string
+	InternalAllowCIDRs []string
+	InternalDenyCIDRs  []string
+
+	// Streaming
+	SSEHeartbeatInterval time.Duration
+
+	// Storage
+	DatabaseURL string
+	Uploads     UploadConfig
+	Static      StaticConfig
+
+	// Users
+	// UserBatchMax caps the IDs accepted by /users/batch
+	UserBatchMax int
+	// BcryptCost is the work factor user passwords are hashed with
+	BcryptCost int
+	// PostCategories are accepted by /user/:id/posts besides "all"
+	PostCategories []string
+	// UserChangesTimeout is how long /users/changes waits for a change
+	UserChangesTimeout time.Duration
+
+	// GeoIPDBPath is a MaxMind GeoLite2 City database; /geoip is disabled
+	// when the file doesn't exist
+	GeoIPDBPath string
+
+	// Search
+	SearchMaxLimit int
+	RedisURL       string
+	SearchCacheTTL time.Duration
+	SearchDelay    time.Duration
+	// SearchBreakerThreshold consecutive backend failures open the search
+	// circuit breaker for SearchBreakerCooldown; 0 disables the breaker
+	SearchBreakerThreshold int
+	SearchBreakerCooldown  time.Duration
+
+	// Feature flags: a JSON object of flag name -> bool, inline or in a file
+	FeatureFlags     string
+	FeatureFlagsFile string
+
+	// Background work
+	Webhook      WebhookConfig
+	JobWorkers   int
+	JobQueueSize int
+	// HTTPChecks are upstream health endpoints reported by /readyz
+	HTTPChecks []HTTPCheckConfig
+
+	// CleanupInterval is how often scheduled maintenance tasks run
+	CleanupInterval time.Duration
+	// IdempotencyTTL is how long responses to Idempotency-Key requests are kept
+	IdempotencyTTL time.Duration
+
+	// Authentication
+	JWTSecret []byte
+	// SessionSecret signs session cookies; like JWTSecret it's generated
+	// per process when unset
+	SessionSecret []byte
+	// SessionTTL is how long a browser session started by /login lasts
+	SessionTTL time.Duration
+	APIKeys    map[string]string
+	// APIKeyMonthlyQuota caps requests per API key in any APIKeyQuotaWindow
+	// long period, 30 days by default; 0 is unlimited
+	APIKeyMonthlyQuota int
+	APIKeyQuotaWindow  time.Duration
+	// Basic auth credentials for /admin; the group is disabled when unset
+	AdminUser     string
+	AdminPassword string
+	// BootstrapAdminUsername and BootstrapAdminPassword create an admin
+	// user at startup unless one with that username exists
+	BootstrapAdminUsername string
+	BootstrapAdminPassword string
+	// SignatureSecret verifies X-Signature on server-to-server requests
+	SignatureSecret  []byte
+	SignatureMaxSkew time.Duration
+
+	// AuditLogFile receives audit entries as JSON lines; they're kept in
+	// memory when empty
+	AuditLogFile string
+
+	// DotEnvLoaded reports whether a .env file was found
+	DotEnvLoaded bool
+	// ConfigFile is the YAML settings file that was read, if any
+	ConfigFile string
+
+	// TLS
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSRedirectHTTP bool
+	TLSRedirectPort string
+	// TLSClientCA, when set, requires clients to present a certificate
+	// signed by one of the CAs in this PEM file
+	TLSClientCA string
+}
+
+// AdminEnabled reports whether the /admin endpoints should be served
+func (c *Config) AdminEnabled() bool {
+	return c.AdminUser != "" && c.AdminPassword != ""
+}
+
+// TLSEnabled reports whether HTTPS should be served
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// activeConfig is the configuration currently in effect
+var activeConfig atomic.Pointer[Config]
+
+// CurrentConfig returns the configuration currently in effect. Settings that
+// may change on reload should be read through it on each use rather than
+// captured at startup.
+func CurrentConfig() *Config {
+	return activeConfig.Load()
+}
+
+// SetConfig makes cfg the configuration returned by CurrentConfig
+func SetConfig(cfg *Config) {
+	activeConfig.Store(cfg)
+}
+
+// ReloadConfig re-reads .env, the environment and the settings file and, if
+// the result is valid, makes it the current configuration. On error the
+// previous configuration stays in effect. JWT and session secrets generated
+// at startup are kept when none are configured.
+func ReloadConfig() (*Config, error) {
+	next, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if prev := CurrentConfig(); prev != nil {
+		if len(next.JWTSecret) == 0 {
+			next.JWTSecret = prev.JWTSecret
+		}
+		if len(next.SessionSecret) == 0 {
+			next.SessionSecret = prev.SessionSecret
+		}
+	}
+	SetConfig(next)
+	return next, nil
+}
+
+var (
+	// processEnv records the variables set before .env was first read; they
+	// always take precedence over .env values
+	processEnv     map[string]bool
+	processEnvOnce sync.Once
+
+	// dotenvKeys are the variables last applied from .env, so keys removed
+	// from the file are unset on reload
+	dotenvKeys map[string]bool
+)
+
+// loadDotEnv applies variables from .env that the process environment
+// doesn't already set. Unlike godotenv.Load it can be called again to pick
+// up edits to the file.
+func loadDotEnv() error {
+	processEnvOnce.Do(func() {
+		processEnv = make(map[string]bool)
+		for _, kv := range os.Environ() {
+			key, _, _ := strings.Cut(kv, "=")
+			processEnv[key] = true
+		}
+	})
+
+	values, err := godotenv.Read()
+	if err != nil {
+		return err
+	}
+
+	for key := range dotenvKeys {
+		if _, ok := values[key]; !ok {
+			os.Unsetenv(key)
+		}
+	}
+	dotenvKeys = make(map[string]bool, len(values))
+	for key, value := range values {
+		if !processEnv[key] {
+			os.Setenv(key, value)
+			dotenvKeys[key] = true
+		}
+	}
+	return nil
+}
+
+// LoadConfig reads configuration from the environment (and .env if present),
+// then the YAML file named by --config or CONFIG_FILE for anything the
+// environment leaves unset, applies defaults and validates the result
+func LoadConfig() (*Config, error) {
+	// Load environment variables from .env file
+	dotenvErr := loadDotEnv()
+
+	configFile := configFilePath()
+	fileValues, err := readConfigFile(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	env := &envReader{file: fileValues}
+	cfg := &Config{
+		Port:                 env.String("PORT", "9000"),
+		GRPCPort:             env.String("GRPC_PORT", ""),
+		GinMode:              env.String("GIN_MODE", "debug"),
+		LogLevel:             env.String("LOG_LEVEL", ""),
+		LogFormat:            env.String("LOG_FORMAT", ""),
+		SlowRequestThreshold: env.Duration("SLOW_REQUEST_THRESHOLD", 500*time.Millisecond),
+		LogSampleRate:        env.Int("LOG_SAMPLE_RATE", 1),
+		LogBodies:            env.Bool("LOG_BODIES", false),
+		LogBodyMaxBytes:      env.Int("LOG_BODY_MAX_BYTES", 2048),
+		AccessLog: LogFileConfig{
+			Path:       env.String("LOG_FILE", ""),
+			MaxSizeMB:  env.Int("LOG_FILE_MAX_SIZE_MB", 100),
+			MaxBackups: env.Int("LOG_FILE_MAX_BACKUPS", 5),
+			MaxAgeDays: env.Int("LOG_FILE_MAX_AGE_DAYS", 30),
+			Stdout:     env.Bool("LOG_FILE_STDOUT", true),
+		},
+		ShutdownTimeout:       env.Duration("SHUTDOWN_TIMEOUT", 10*time.Second),
+		StartupTimeout:        env.Duration("STARTUP_TIMEOUT", 60*time.Second),
+		EnablePprof:           env.Bool("ENABLE_PPROF", false),
+		MaintenanceMode:       env.Bool("MAINTENANCE_MODE", false),
+		RequestTimeout:        env.Duration("REQUEST_TIMEOUT", 30*time.Second),
+		MaxConcurrentRequests: env.Int("MAX_CONCURRENT_REQUESTS", 0),
+		ServerTimeouts: ServerTimeouts{
+			Read:       env.Duration("READ_TIMEOUT", 15*time.Second),
+			ReadHeader: env.Duration("READ_HEADER_TIMEOUT", 5*time.Second),
+			Write:      env.Duration("WRITE_TIMEOUT", 45*time.Second),
+			Idle:       env.Duration("IDLE_TIMEOUT", 60*time.Second),
+		},
+		OTLPEndpoint: env.String("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+
+		CORSAllowedOrigins: env.List("CORS_ALLOWED_ORIGINS"),
+		RateLimitRPS:       env.Int("RATE_LIMIT_RPS", 10),
+		RateLimitBurst:     env.Int("RATE_LIMIT_BURST", 20),
+		GzipMinSize:        env.Int("GZIP_MIN_SIZE", defaultGzipThreshold),
+		MaxBodyBytes:       int64(env.Int("MAX_BODY_BYTES", defaultMaxBodyBytes)),
+
+		TrustedProxies:     env.List("TRUSTED_PROXIES"),
+		InternalAllowCIDRs: env.List("INTERNAL_ALLOW_CIDRS", privateNetworks...),
+		InternalDenyCIDRs:  env.List("INTERNAL_DENY_CIDRS"),
+
+		SSEHeartbeatInterval: env.Duration("SSE_HEARTBEAT_INTERVAL", 5*time.Second),
+
+		DatabaseURL: env.String("DATABASE_URL", ""),
+		Uploads: UploadConfig{
+			Dir:               env.String("UPLOAD_DIR", "./uploads"),
+			MaxBytes:          int64(env.Int("UPLOAD_MAX_BYTES", 5<<20)),
+			AllowedExtensions: env.List("UPLOAD_ALLOWED_EXTENSIONS", ".png", ".jpg", ".jpeg", ".gif", ".pdf", ".txt"),
+			Retention:         env.Duration("UPLOAD_RETENTION", 0),
+		},
+		Static: StaticConfig{
+			Prefix: env.String("STATIC_PREFIX", "/static"),
+			Dir:    env.String("STATIC_DIR", ""),
+			MaxAge: env.Duration("STATIC_MAX_AGE", time.Hour),
+		},
+
+		UserBatchMax:   env.Int("USER_BATCH_MAX", 100),
+		BcryptCost:     env.Int("BCRYPT_COST", bcrypt.DefaultCost),
+		PostCategories: env.List("POST_CATEGORIES", "news", "tech", "life"),
+
+		UserChangesTimeout: env.Duration("USER_CHANGES_TIMEOUT", 25*time.Second),
+
+		GeoIPDBPath: env.String("GEOIP_DB_PATH", "./GeoLite2-City.mmdb"),
+
+		SearchMaxLimit: env.Int("SEARCH_MAX_LIMIT", 100),
+		RedisURL:       env.String("REDIS_URL", ""),
+		SearchCacheTTL: env.Duration("SEARCH_CACHE_TTL", 60*time.Second),
+		SearchDelay:    env.Duration("SEARCH_DELAY", 0),
+
+		SearchBreakerThreshold: env.Int("SEARCH_BREAKER_THRESHOLD", 5),
+		SearchBreakerCooldown:  env.Duration("SEARCH_BREAKER_COOLDOWN", 30*time.Second),
+
+		Webhook: WebhookConfig{
+			URL:         env.String("WEBHOOK_URL", ""),
+			Secret:      []byte(env.String("WEBHOOK_SECRET", "")),
+			MaxAttempts: env.Int("WEBHOOK_MAX_ATTEMPTS", 5),
+			Timeout:     env.Duration("WEBHOOK_TIMEOUT", 5*time.Second),
+		},
+
+		FeatureFlags:     env.String("FEATURE_FLAGS", ""),
+		FeatureFlagsFile: env.String("FEATURE_FLAGS_FILE", ""),
+
+		JobWorkers:   env.Int("JOB_WORKERS", 4),
+		JobQueueSize: env.Int("JOB_QUEUE_SIZE", 100),
+
+		HTTPChecks: httpChecks(env,
+			env.Map("HEALTH_CHECK_URLS"),
+			env.Int("HEALTH_CHECK_EXPECTED_STATUS", http.StatusOK),
+			env.Duration("HEALTH_CHECK_TIMEOUT", time.Second),
+			env.List("HEALTH_CHECK_CRITICAL"),
+		),
+
+		CleanupInterval: env.Duration("CLEANUP_INTERVAL", time.Hour),
+		IdempotencyTTL:  env.Duration("IDEMPOTENCY_TTL", 24*time.Hour),
+
+		JWTSecret:     []byte(env.String("JWT_SECRET", "")),
+		SessionSecret: []byte(env.String("SESSION_SECRET", "")),
+		SessionTTL:    env.Duration("SESSION_TTL", 12*time.Hour),
+		APIKeys:       env.Map("API_KEYS"),
+
+		APIKeyMonthlyQuota: env.Int("API_KEY_MONTHLY_QUOTA", 10000),
+		APIKeyQuotaWindow:  env.Duration("API_KEY_QUOTA_WINDOW", 30*24*time.Hour),
+
+		AdminUser:     env.String("ADMIN_USER", ""),
+		AdminPassword: env.String("ADMIN_PASSWORD", ""),
+
+		BootstrapAdminUsername: env.String("BOOTSTRAP_ADMIN_USERNAME", ""),
+		BootstrapAdminPassword: env.String("BOOTSTRAP_ADMIN_PASSWORD", ""),
+
+		SignatureSecret:  []byte(env.String("SIGNATURE_SECRET", "")),
+		SignatureMaxSkew: env.Duration("SIGNATURE_MAX_SKEW", 5*time.Minute),
+
+		AuditLogFile: env.String("AUDIT_LOG_FILE", ""),
+
+		TLSCertFile:     env.String("TLS_CERT_FILE", ""),
+		TLSKeyFile:      env.String("TLS_KEY_FILE", ""),
+		TLSRedirectHTTP: env.Bool("TLS_REDIRECT_HTTP", false),
+		TLSRedirectPort: env.String("TLS_REDIRECT_PORT", "80"),
+		TLSClientCA:     env.String("TLS_CLIENT_CA", ""),
+	}
+
+	// Verbose text logs for development, JSON at info level in release mode
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = "debug"
+		if cfg.GinMode == "release" {
+			cfg.LogLevel = "info"
+		}
+	}
+	if cfg.LogFormat == "" {
+		cfg.LogFormat = "text"
+		if cfg.GinMode == "release" {
+			cfg.LogFormat = "json"
+		}
+	}
+
+	// Accept extensions with or without the leading dot
+	for i, ext := range cfg.Uploads.AllowedExtensions {
+		if !strings.HasPrefix(ext, ".") {
+			cfg.Uploads.AllowedExtensions[i] = "." + ext
+		}
+	}
+
+	errs := append(env.errs, env.unknownFileKeys()...)
+	if err := errors.Join(append(errs, cfg.validate()...)...); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	cfg.DotEnvLoaded = dotenvErr == nil
+	cfg.ConfigFile = configFile
+
+	return cfg, nil
+}
+
+// validate checks values that parsed correctly but are out of range
+func (c *Config) validate() []error {
+	var errs []error
+
+	// PORT=0 asks the OS for a free port, which is handy in tests
+	if c.Port != "0" {
+		if err := validatePort("PORT", c.Port); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.GRPCPort != "" && c.GRPCPort != "0" {
+		if err := validatePort("GRPC_PORT", c.GRPCPort); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if !slices.Contains([]string{"debug", "release", "test"}, c.GinMode) {
+		errs = append(errs, fmt.Errorf("GIN_MODE %q must be one of debug, release, test", c.GinMode))
+	}
+	if !slices.Contains([]string{"debug", "info", "warn", "error"}, c.LogLevel) {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL %q must be one of debug, info, warn, error", c.LogLevel))
+	}
+	if !slices.Contains([]string{"text", "json"}, c.LogFormat) {
+		errs = append(errs, fmt.Errorf("LOG_FORMAT %q must be one of text, json", c.LogFormat))
+	}
+	if c.AccessLog.MaxSizeMB <= 0 {
+		errs = append(errs, errors.New("LOG_FILE_MAX_SIZE_MB must be a positive integer"))
+	}
+	if c.AccessLog.MaxBackups < 0 || c.AccessLog.MaxAgeDays < 0 {
+		errs = append(errs, errors.New("LOG_FILE_MAX_BACKUPS and LOG_FILE_MAX_AGE_DAYS must not be negative"))
+	}
+	if c.SlowRequestThreshold <= 0 {
+		errs = append(errs, errors.New("SLOW_REQUEST_THRESHOLD must be positive"))
+	}
+	if c.LogSampleRate < 1 {
+		errs = append(errs, errors.New("LOG_SAMPLE_RATE must be at least 1"))
+	}
+	if c.LogBodyMaxBytes <= 0 {
+		errs = append(errs, errors.New("LOG_BODY_MAX_BYTES must be a positive integer"))
+	}
+	if (c.AdminUser == "") != (c.AdminPassword == "") {
+		errs = append(errs, errors.New("ADMIN_USER and ADMIN_PASSWORD must be set together"))
+	}
+	if c.APIKeyMonthlyQuota < 0 {
+		errs = append(errs, errors.New("API_KEY_MONTHLY_QUOTA must not be negative"))
+	}
+	if c.APIKeyQuotaWindow <= 0 {
+		errs = append(errs, errors.New("API_KEY_QUOTA_WINDOW must be positive"))
+	}
+	if c.EnablePprof && !c.AdminEnabled() {
+		errs = append(errs, errors.New("ENABLE_PPROF requires ADMIN_USER and ADMIN_PASSWORD so profiles aren't publicly exposed"))
+	}
+	if c.Webhook.URL != "" {
+		if len(c.Webhook.Secret) == 0 {
+			errs = append(errs, errors.New("WEBHOOK_SECRET is required when WEBHOOK_URL is set"))
+		}
+		if c.Webhook.MaxAttempts < 1 {
+			errs = append(errs, errors.New("WEBHOOK_MAX_ATTEMPTS must be a positive integer"))
+		}
+		if c.Webhook.Timeout <= 0 {
+			errs = append(errs, errors.New("WEBHOOK_TIMEOUT must be positive"))
+		}
+	}
+	if c.JobWorkers < 1 {
+		errs = append(errs, errors.New("JOB_WORKERS must be a positive integer"))
+	}
+	if c.JobQueueSize < 1 {
+		errs = append(errs, errors.New("JOB_QUEUE_SIZE must be a positive integer"))
+	}
+	if c.SignatureMaxSkew <= 0 {
+		errs = append(errs, errors.New("SIGNATURE_MAX_SKEW must be positive"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT must be positive"))
+	}
+	if c.StartupTimeout <= 0 {
+		errs = append(errs, errors.New("STARTUP_TIMEOUT must be positive"))
+	}
+	if c.RequestTimeout <= 0 {
+		errs = append(errs, errors.New("REQUEST_TIMEOUT must be positive"))
+	}
+	if c.MaxConcurrentRequests < 0 {
+		errs = append(errs, errors.New("MAX_CONCURRENT_REQUESTS must not be negative"))
+	}
+	if c.ServerTimeouts.Read <= 0 || c.ServerTimeouts.ReadHeader <= 0 || c.ServerTimeouts.Write <= 0 || c.ServerTimeouts.Idle <= 0 {
+		errs = append(errs, errors.New("READ_TIMEOUT, READ_HEADER_TIMEOUT, WRITE_TIMEOUT and IDLE_TIMEOUT must be positive"))
+	}
+	if c.ServerTimeouts.Write <= c.RequestTimeout {
+		errs = append(errs, fmt.Errorf("WRITE_TIMEOUT (%s) must exceed REQUEST_TIMEOUT (%s) so timeout responses can be sent", c.ServerTimeouts.Write, c.RequestTimeout))
+	}
+	if c.RateLimitRPS <= 0 {
+		errs = append(errs, errors.New("RATE_LIMIT_RPS must be a positive integer"))
+	}
+	if c.RateLimitBurst <= 0 {
+		errs = append(errs, errors.New("RATE_LIMIT_BURST must be a positive integer"))
+	}
+	if c.GzipMinSize < 0 {
+		errs = append(errs, errors.New("GZIP_MIN_SIZE must be a non-negative integer"))
+	}
+	if c.SSEHeartbeatInterval <= 0 {
+		errs = append(errs, errors.New("SSE_HEARTBEAT_INTERVAL must be positive"))
+	}
+	if c.Uploads.Retention < 0 {
+		errs = append(errs, errors.New("UPLOAD_RETENTION must not be negative"))
+	}
+	if c.IdempotencyTTL <= 0 {
+		errs = append(errs, errors.New("IDEMPOTENCY_TTL must be positive"))
+	}
+	if c.SessionTTL <= 0 {
+		errs = append(errs, errors.New("SESSION_TTL must be positive"))
+	}
+	for _, check := range c.HTTPChecks {
+		if u, err := url.Parse(check.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("HEALTH_CHECK_URLS entry %s has invalid URL %q", check.Name, check.URL))
+		}
+		if check.ExpectedStatus < 100 || check.ExpectedStatus > 599 {
+			errs = append(errs, fmt.Errorf("HEALTH_CHECK_EXPECTED_STATUS %d is not an HTTP status", check.ExpectedStatus))
+			break
+		}
+		if check.Timeout <= 0 {
+			errs = append(errs, errors.New("HEALTH_CHECK_TIMEOUT must be positive"))
+			break
+		}
+	}
+	if c.CleanupInterval <= 0 {
+		errs = append(errs, errors.New("CLEANUP_INTERVAL must be positive"))
+	}
+	if !strings.HasPrefix(c.Static.Prefix, "/") || c.Static.Prefix == "/" || strings.HasSuffix(c.Static.Prefix, "/") {
+		errs = append(errs, fmt.Errorf("STATIC_PREFIX %q must be a path like /static, not / or ending in /", c.Static.Prefix))
+	}
+	if c.Static.MaxAge < 0 {
+		errs = append(errs, errors.New("STATIC_MAX_AGE must not be negative"))
+	}
+	if c.Uploads.MaxBytes <= 0 {
+		errs = append(errs, errors.New("UPLOAD_MAX_BYTES must be a positive integer"))
+	}
+	if c.SearchDelay < 0 {
+		errs = append(errs, errors.New("SEARCH_DELAY must not be negative"))
+	}
+	if c.UserBatchMax <= 0 {
+		errs = append(errs, errors.New("USER_BATCH_MAX must be a positive integer"))
+	}
+	if c.BcryptCost < bcrypt.MinCost || c.BcryptCost > bcrypt.MaxCost {
+		errs = append(errs, fmt.Errorf("BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+	}
+	if (c.BootstrapAdminUsername == "") != (c.BootstrapAdminPassword == "") {
+		errs = append(errs, errors.New("BOOTSTRAP_ADMIN_USERNAME and BOOTSTRAP_ADMIN_PASSWORD must be set together"))
+	}
+	if c.UserChangesTimeout <= 0 || c.UserChangesTimeout >= c.RequestTimeout {
+		errs = append(errs, fmt.Errorf("USER_CHANGES_TIMEOUT (%s) must be positive and less than REQUEST_TIMEOUT (%s)", c.UserChangesTimeout, c.RequestTimeout))
+	}
+	if c.SearchMaxLimit <= 0 {
+		errs = append(errs, errors.New("SEARCH_MAX_LIMIT must be a positive integer"))
+	}
+	if c.SearchCacheTTL <= 0 {
+		errs = append(errs, errors.New("SEARCH_CACHE_TTL must be positive"))
+	}
+	if c.SearchBreakerThreshold < 0 {
+		errs = append(errs, errors.New("SEARCH_BREAKER_THRESHOLD must not be negative"))
+	}
+	if c.SearchBreakerCooldown <= 0 {
+		errs = append(errs, errors.New("SEARCH_BREAKER_COOLDOWN must be positive"))
+	}
+	if c.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("MAX_BODY_BYTES must be a positive integer"))
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+	if c.TLSClientCA != "" && !c.TLSEnabled() {
+		errs = append(errs, errors.New("TLS_CLIENT_CA requires TLS_CERT_FILE and TLS_KEY_FILE"))
+	}
+	if c.TLSRedirectHTTP {
+		if err := validatePort("TLS_REDIRECT_PORT", c.TLSRedirectPort); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// validatePort checks that value is a TCP port number
+func validatePort(name, value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%s %q must be a number between 1 and 65535", name, value)
+	}
+	return nil
+}
+
+// envReader reads typed environment variables, collecting parse errors
+// so they can all be reported at once. Variables that are unset or empty
+// fall back to the settings file, if there is one.
+type envReader struct {
+	errs []error
+	// file holds the settings file's values by variable name
+	file map[string]string
+	// read records every variable looked up, to catch unknown file keys
+	read map[string]bool
+}
+
+// get returns the variable's value from the environment or settings file
+func (r *envReader) get(key string) string {
+	if r.read == nil {
+		r.read = make(map[string]bool)
+	}
+	r.read[key] = true
+
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return r.file[key]
+}
+
+// unknownFileKeys reports settings file keys that no variable was read for,
+// which are most likely typos
+func (r *envReader) unknownFileKeys() []error {
+	var errs []error
+	for _, key := range slices.Sorted(maps.Keys(r.file)) {
+		if !r.read[key] {
+			errs = append(errs, fmt.Errorf("unknown setting %s in config file", strings.ToLower(key)))
+		}
+	}
+	return errs
+}
+
+// String returns the variable's value or def when unset or empty
+func (r *envReader) String(key, def string) string {
+	if v := r.get(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// Int returns the variable parsed as an integer or def when unset
+func (r *envReader) Int(key string, def int) int {
+	v := r.get(key)
+	if v == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s %q is not an integer", key, v))
+		return def
+	}
+	return n
+}
+
+// Bool returns the variable parsed as a boolean or def when unset
+func (r *envReader) Bool(key string, def bool) bool {
+	v := r.get(key)
+	if v == "" {
+		return def
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s %q is not a boolean", key, v))
+		return def
+	}
+	return b
+}
+
+// Duration returns the variable parsed as a Go duration or def when unset
+func (r *envReader) Duration(key string, def time.Duration) time.Duration {
+	v := r.get(key)
+	if v == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s %q is not a duration (e.g. 10s, 1m)", key, v))
+		return def
+	}
+	return d
+}
+
+// Map returns a comma-separated list of key:value pairs as a map
+func (r *envReader) Map(key string) map[string]string {
+	pairs := make(map[string]string)
+	for _, item := range r.List(key) {
+		k, v, ok := strings.Cut(item, ":")
+		if !ok || k == "" || v == "" {
+			r.errs = append(r.errs, fmt.Errorf("%s entry %q must be in key:value form", key, item))
+			continue
+		}
+		pairs[k] = v
+	}
+	return pairs
+}
+
+// List returns the comma-separated variable as a slice with blanks removed,
+// or def when the variable is unset or empty
+func (r *envReader) List(key string, def ...string) []string {
+	var items []string
+	for _, item := range strings.Split(r.get(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return def
+	}
+	return items
+}
+
+// httpChecks builds a readiness check for each name:url in urls, sorted by
+// name so /readyz lists them in a stable order. Names in critical make their
+// check critical; unknown names are reported to env.
+func httpChecks(env *envReader, urls map[string]string, expectedStatus int, timeout time.Duration, critical []string) []HTTPCheckConfig {
+	for _, name := range critical {
+		if _, ok := urls[name]; !ok {
+			env.errs = append(env.errs, fmt.Errorf("HEALTH_CHECK_CRITICAL name %q is not in HEALTH_CHECK_URLS", name))
+		}
+	}
+
+	var checks []HTTPCheckConfig
+	for name, target := range urls {
+		checks = append(checks, HTTPCheckConfig{
+			Name:           name,
+			URL:            target,
+			ExpectedStatus: expectedStatus,
+			Timeout:        timeout,
+			Critical:       slices.Contains(critical, name),
+		})
+	}
+	slices.SortFunc(checks, func(a, b HTTPCheckConfig) int { return strings.Compare(a.Name, b.Name) })
+	return checks
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadTestConfig runs LoadConfig in an empty working directory, so no .env
+// is read, with env set on top of the process environment
+func loadTestConfig(t *testing.T, env map[string]string) (*Config, error) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	return LoadConfig()
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg, err := loadTestConfig(t, map[string]string{
+		"PORT": "", "GIN_MODE": "", "LOG_LEVEL": "", "LOG_FORMAT": "", "REQUEST_TIMEOUT": "", "CORS_ALLOWED_ORIGINS": "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "9000" || cfg.GinMode != "debug" || cfg.LogLevel != "debug" || cfg.LogFormat != "text" {
+		t.Fatalf("got port %s, mode %s, log %s/%s", cfg.Port, cfg.GinMode, cfg.LogLevel, cfg.LogFormat)
+	}
+	if cfg.RequestTimeout != 30*time.Second || cfg.ServerTimeouts.Write != 45*time.Second {
+		t.Fatalf("got request timeout %s, write timeout %s", cfg.RequestTimeout, cfg.ServerTimeouts.Write)
+	}
+	if cfg.CORSAllowedOrigins != nil {
+		t.Fatalf("CORS origins default to %v", cfg.CORSAllowedOrigins)
+	}
+	if cfg.DotEnvLoaded {
+		t.Fatal("DotEnvLoaded without a .env")
+	}
+}
+
+func TestLoadConfigReleaseLogging(t *testing.T) {
+	cfg, err := loadTestConfig(t, map[string]string{"GIN_MODE": "release", "LOG_LEVEL": "", "LOG_FORMAT": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LogLevel != "info" || cfg.LogFormat != "json" {
+		t.Fatalf("release mode logs at %s/%s", cfg.LogLevel, cfg.LogFormat)
+	}
+}
+
+func TestLoadConfigParsesValues(t *testing.T) {
+	cfg, err := loadTestConfig(t, map[string]string{
+		"PORT":                      "8080",
+		"CORS_ALLOWED_ORIGINS":      " http://a.example, ,http://b.example ",
+		"REQUEST_TIMEOUT":           "5s",
+		"WRITE_TIMEOUT":             "10s",
+		"USER_CHANGES_TIMEOUT":      "2s",
+		"API_KEYS":                  "k1:billing,k2:reports",
+		"UPLOAD_ALLOWED_EXTENSIONS": "png,.txt",
+		"LOG_BODIES":                "true",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "8080" || cfg.RequestTimeout != 5*time.Second || !cfg.LogBodies {
+		t.Fatalf("got port %s, timeout %s, log bodies %v", cfg.Port, cfg.RequestTimeout, cfg.LogBodies)
+	}
+	if !slices.Equal(cfg.CORSAllowedOrigins, []string{"http://a.example", "http://b.example"}) {
+		t.Fatalf("CORS origins are %q", cfg.CORSAllowedOrigins)
+	}
+	if cfg.APIKeys["k1"] != "billing" || cfg.APIKeys["k2"] != "reports" || len(cfg.APIKeys) != 2 {
+		t.Fatalf("API keys are %v", cfg.APIKeys)
+	}
+	if !slices.Equal(cfg.Uploads.AllowedExtensions, []string{".png", ".txt"}) {
+		t.Fatalf("extensions are %q", cfg.Uploads.AllowedExtensions)
+	}
+}
+
+func TestLoadConfigRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"non-numeric port", map[string]string{"PORT": "http"}, `PORT "http" must be a number between 1 and 65535`},
+		{"port out of range", map[string]string{"PORT": "70000"}, `PORT "70000" must be a number`},
+		{"negative port", map[string]string{"PORT": "-1"}, `PORT "-1" must be a number`},
+		{"gin mode", map[string]string{"GIN_MODE": "prod"}, `GIN_MODE "prod" must be one of`},
+		{"log level", map[string]string{"LOG_LEVEL": "trace"}, `LOG_LEVEL "trace" must be one of`},
+		{"integer", map[string]string{"RATE_LIMIT_RPS": "ten"}, `RATE_LIMIT_RPS "ten" is not an integer`},
+		{"boolean", map[string]string{"LOG_BODIES": "sometimes"}, `LOG_BODIES "sometimes" is not a boolean`},
+		{"duration", map[string]string{"REQUEST_TIMEOUT": "30"}, `REQUEST_TIMEOUT "30" is not a duration`},
+		{"pair", map[string]string{"API_KEYS": "k1"}, `API_KEYS entry "k1" must be in key:value form`},
+		{"write timeout", map[string]string{"WRITE_TIMEOUT": "10s"}, "WRITE_TIMEOUT (10s) must exceed REQUEST_TIMEOUT (30s)"},
+		{"half of a pair", map[string]string{"ADMIN_USER": "admin", "ADMIN_PASSWORD": ""}, "ADMIN_USER and ADMIN_PASSWORD must be set together"},
+		{"webhook secret", map[string]string{"WEBHOOK_URL": "http://hooks.example", "WEBHOOK_SECRET": ""}, "WEBHOOK_SECRET is required"},
+		{"health check URL", map[string]string{"HEALTH_CHECK_URLS": "db:localhost:5432"}, `HEALTH_CHECK_URLS entry db has invalid URL`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadTestConfig(t, tt.env)
+			if err == nil {
+				t.Fatal("invalid configuration was accepted")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error %q doesn't mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigReportsEveryError(t *testing.T) {
+	_, err := loadTestConfig(t, map[string]string{"PORT": "x", "GIN_MODE": "prod", "JOB_WORKERS": "0"})
+	if err == nil {
+		t.Fatal("invalid configuration was accepted")
+	}
+	for _, want := range []string{"PORT", "GIN_MODE", "JOB_WORKERS"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %s", err, want)
+		}
+	}
+}
+
+func TestLoadConfigReadsDotEnv(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeDotEnv(t, "SEARCH_BREAKER_THRESHOLD=7\n")
+	t.Cleanup(func() {
+		writeDotEnv(t, "")
+		loadDotEnv()
+	})
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.DotEnvLoaded || cfg.SearchBreakerThreshold != 7 {
+		t.Fatalf("got loaded %v, threshold %d", cfg.DotEnvLoaded, cfg.SearchBreakerThreshold)
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/config.yaml"
+	yaml := "port: 9100\nrate_limit_rps: 3\ncors_allowed_origins: [http://a.example, http://b.example]\napi_keys: {k1: billing}\n"
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The environment wins over the file
+	cfg, err := loadTestConfig(t, map[string]string{"CONFIG_FILE": path, "PORT": "9200", "RATE_LIMIT_RPS": "", "CORS_ALLOWED_ORIGINS": "", "API_KEYS": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "9200" || cfg.RateLimitRPS != 3 || cfg.ConfigFile != path {
+		t.Fatalf("got port %s, RPS %d, file %s", cfg.Port, cfg.RateLimitRPS, cfg.ConfigFile)
+	}
+	if !slices.Equal(cfg.CORSAllowedOrigins, []string{"http://a.example", "http://b.example"}) || cfg.APIKeys["k1"] != "billing" {
+		t.Fatalf("got origins %q, keys %v", cfg.CORSAllowedOrigins, cfg.APIKeys)
+	}
+
+	if err := os.WriteFile(path, []byte("prot: 9100\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), "unknown setting prot") {
+		t.Fatalf("typo in the file: got %v", err)
+	}
+}
+
+func TestValidatePort(t *testing.T) {
+	for _, port := range []string{"1", "80", "65535"} {
+		if err := validatePort("PORT", port); err != nil {
+			t.Errorf("%s: %v", port, err)
+		}
+	}
+	for _, port := range []string{"", "0", "65536", "9000a", " 80"} {
+		if err := validatePort("PORT", port); err == nil {
+			t.Errorf("%q was accepted", port)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileFlag names a YAML settings file, taking precedence over
+// CONFIG_FILE
+var configFileFlag = flag.String("config", "", "YAML file to read settings from (overrides CONFIG_FILE)")
+
+// configFilePath returns the settings file to read, or "" for none
+func configFilePath() string {
+	if *configFileFlag != "" {
+		return *configFileFlag
+	}
+	return os.Getenv("CONFIG_FILE")
+}
+
+// readConfigFile reads a YAML file of settings keyed by their environment
+// variable names, in any case:
+//
+//	port: 9000
+//	log_level: debug
+//	cors_allowed_origins: [http://localhost:3000, http://localhost:5173]
+//	api_keys: {k1: billing, k2: reports}
+//
+// Values come back as the strings the variable would hold: sequences are
+// joined with commas and mappings become comma-separated key:value pairs.
+// An empty path reads nothing.
+func readConfigFile(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		s, err := configFileValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s %w", path, key, err)
+		}
+		values[strings.ToUpper(key)] = s
+	}
+	return values, nil
+}
+
+// configFileValue converts a YAML value to its environment variable form
+func configFileValue(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string, bool, int, float64:
+		return fmt.Sprint(v), nil
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := configFileValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	case map[string]any:
+		pairs := make([]string, 0, len(v))
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			s, err := configFileValue(v[key])
+			if err != nil {
+				return "", err
+			}
+			pairs = append(pairs, key+":"+s)
+		}
+		return strings.Join(pairs, ","), nil
+	default:
+		return "", fmt.Errorf("has unsupported value %v", value)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes a YAML settings file and returns its path
+func writeConfigFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFromYAMLFile(t *testing.T) {
+	path := writeConfigFile(t, `
+port: 9100
+LOG_LEVEL: warn
+request_timeout: 40s
+cors_allowed_origins: [http://localhost:3000, http://localhost:5173]
+api_keys: {k1: billing, k2: reports}
+`)
+	cfg, err := loadTestConfig(t, map[string]string{
+		"CONFIG_FILE": path, "PORT": "", "LOG_LEVEL": "", "REQUEST_TIMEOUT": "", "SHUTDOWN_TIMEOUT": "", "CORS_ALLOWED_ORIGINS": "", "API_KEYS": "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "9100" || cfg.LogLevel != "warn" || cfg.RequestTimeout != 40*time.Second {
+		t.Fatalf("got port %s, log level %s, request timeout %s", cfg.Port, cfg.LogLevel, cfg.RequestTimeout)
+	}
+	if want := []string{"http://localhost:3000", "http://localhost:5173"}; !slices.Equal(cfg.CORSAllowedOrigins, want) {
+		t.Fatalf("CORS origins are %v, want %v", cfg.CORSAllowedOrigins, want)
+	}
+	if len(cfg.APIKeys) != 2 || cfg.APIKeys["k1"] != "billing" || cfg.APIKeys["k2"] != "reports" {
+		t.Fatalf("API keys are %v", cfg.APIKeys)
+	}
+	if cfg.ConfigFile != path {
+		t.Fatalf("ConfigFile is %q, want %q", cfg.ConfigFile, path)
+	}
+	// Defaults still fill what the file leaves out
+	if cfg.ShutdownTimeout != 10*time.Second {
+		t.Fatalf("SHUTDOWN_TIMEOUT defaulted to %s", cfg.ShutdownTimeout)
+	}
+}
+
+func TestLoadConfigEnvOverridesFile(t *testing.T) {
+	path := writeConfigFile(t, "port: 9100\nlog_level: warn\n")
+	cfg, err := loadTestConfig(t, map[string]string{"CONFIG_FILE": path, "PORT": "9200", "LOG_LEVEL": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "9200" || cfg.LogLevel != "warn" {
+		t.Fatalf("got port %s and log level %s, want PORT from the environment and the level from the file", cfg.Port, cfg.LogLevel)
+	}
+}
+
+func TestConfigFlagOverridesConfigFileEnv(t *testing.T) {
+	fromEnv := writeConfigFile(t, "port: 9100\n")
+	fromFlag := writeConfigFile(t, "port: 9300\n")
+	prev := *configFileFlag
+	*configFileFlag = fromFlag
+	t.Cleanup(func() { *configFileFlag = prev })
+
+	cfg, err := loadTestConfig(t, map[string]string{"CONFIG_FILE": fromEnv, "PORT": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "9300" || cfg.ConfigFile != fromFlag {
+		t.Fatalf("got port %s from %s, want the --config file", cfg.Port, cfg.ConfigFile)
+	}
+}
+
+func TestLoadConfigRejectsBadConfigFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		contains string
+	}{
+		{"missing", filepath.Join(t.TempDir(), "missing.yaml"), "read config file"},
+		{"malformed", writeConfigFile(t, "port: [9000\n"), "parse"},
+		{"unsupported value", writeConfigFile(t, "api_keys: 2024-01-01\n"), "api_keys has unsupported value"},
+		{"unknown key", writeConfigFile(t, "prot: 9000\n"), "unknown setting prot"},
+		{"invalid value", writeConfigFile(t, "request_timeout: soon\n"), "REQUEST_TIMEOUT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadTestConfig(t, map[string]string{"CONFIG_FILE": tt.path, "REQUEST_TIMEOUT": "", "API_KEYS": ""})
+			if err == nil || !strings.Contains(err.Error(), tt.contains) {
+				t.Fatalf("got %v, want an error mentioning %q", err, tt.contains)
+			}
+		})
+	}
+}
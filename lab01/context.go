@@ -0,0 +1,133 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ctxKey names a value stored in the Gin context. All keys are declared here
+// and only read or written through the typed helpers below, so middleware and
+// handlers can't disagree on a key's spelling or its value's type.
+type ctxKey string
+
+const (
+	requestIDKey ctxKey = "request_id"
+	userIDKey    ctxKey = "user_id"
+	roleKey      ctxKey = "role"
+	apiClientKey ctxKey = "api_client"
+	clientCNKey  ctxKey = "client_cn"
+	flagsKey     ctxKey = "flags"
+	locationKey  ctxKey = "location"
+	sessionKey   ctxKey = "session"
+)
+
+// setValue stores value under key in the request context
+func setValue[T any](c *gin.Context, key ctxKey, value T) {
+	c.Set(string(key), value)
+}
+
+// getValue returns the value stored under key, and false when it's unset or
+// not a T
+func getValue[T any](c *gin.Context, key ctxKey) (T, bool) {
+	value, _ := c.Get(string(key))
+	typed, ok := value.(T)
+	return typed, ok
+}
+
+// SetRequestID stores the request's correlation ID
+func SetRequestID(c *gin.Context, id string) { setValue(c, requestIDKey, id) }
+
+// GetRequestID returns the ID set by the RequestID middleware
+func GetRequestID(c *gin.Context) (string, bool) { return getValue[string](c, requestIDKey) }
+
+// RequestIDFromContext returns the ID set by the RequestID middleware, or ""
+func RequestIDFromContext(c *gin.Context) string {
+	id, _ := GetRequestID(c)
+	return id
+}
+
+// SetUserID stores the subject of a verified token
+func SetUserID(c *gin.Context, id string) { setValue(c, userIDKey, id) }
+
+// GetUserID returns the subject set by AuthRequired
+func GetUserID(c *gin.Context) (string, bool) { return getValue[string](c, userIDKey) }
+
+// UserIDFromContext returns the subject set by AuthRequired, or ""
+func UserIDFromContext(c *gin.Context) string {
+	id, _ := GetUserID(c)
+	return id
+}
+
+// SetRole stores the role of a verified token
+func SetRole(c *gin.Context, role string) { setValue(c, roleKey, role) }
+
+// GetRole returns the role set by AuthRequired
+func GetRole(c *gin.Context) (string, bool) { return getValue[string](c, roleKey) }
+
+// RoleFromContext returns the role set by AuthRequired, or ""
+func RoleFromContext(c *gin.Context) string {
+	role, _ := GetRole(c)
+	return role
+}
+
+// SetAPIClient stores the name of the client an API key belongs to
+func SetAPIClient(c *gin.Context, client string) { setValue(c, apiClientKey, client) }
+
+// GetAPIClient returns the client name set by APIKeyAuth
+func GetAPIClient(c *gin.Context) (string, bool) { return getValue[string](c, apiClientKey) }
+
+// APIClientFromContext returns the client name set by APIKeyAuth, or ""
+func APIClientFromContext(c *gin.Context) string {
+	client, _ := GetAPIClient(c)
+	return client
+}
+
+// SetClientCN stores the subject CN of a verified client certificate
+func SetClientCN(c *gin.Context, cn string) { setValue(c, clientCNKey, cn) }
+
+// GetClientCN returns the client certificate CN set by ClientCert
+func GetClientCN(c *gin.Context) (string, bool) { return getValue[string](c, clientCNKey) }
+
+// ClientCNFromContext returns the client certificate CN set by ClientCert,
+// or "" when the request wasn't made with a client certificate
+func ClientCNFromContext(c *gin.Context) string {
+	cn, _ := GetClientCN(c)
+	return cn
+}
+
+// SetFlags stores the feature flag snapshot for the request
+func SetFlags(c *gin.Context, flags FeatureFlags) { setValue(c, flagsKey, flags) }
+
+// GetFlags returns the snapshot set by the Flags middleware
+func GetFlags(c *gin.Context) (FeatureFlags, bool) { return getValue[FeatureFlags](c, flagsKey) }
+
+// FlagsFromContext returns the snapshot set by the Flags middleware, or no
+// flags at all, which reads as every flag being off
+func FlagsFromContext(c *gin.Context) FeatureFlags {
+	flags, _ := GetFlags(c)
+	return flags
+}
+
+// SetLocation stores the time zone timestamps should be formatted in
+func SetLocation(c *gin.Context, loc *time.Location) { setValue(c, locationKey, loc) }
+
+// GetLocation returns the time zone set by the Timezone middleware
+func GetLocation(c *gin.Context) (*time.Location, bool) {
+	return getValue[*time.Location](c, locationKey)
+}
+
+// LocationFromContext returns the time zone set by the Timezone middleware,
+// or UTC
+func LocationFromContext(c *gin.Context) *time.Location {
+	if loc, ok := GetLocation(c); ok {
+		return loc
+	}
+	return time.UTC
+}
+
+// SetSession stores the session of a verified session cookie
+func SetSession(c *gin.Context, session Session) { setValue(c, sessionKey, session) }
+
+// GetSession returns the session set by SessionAuth
+func GetSession(c *gin.Context) (Session, bool) { return getValue[Session](c, sessionKey) }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestContext returns a bare Gin context with no values set
+func newTestContext() *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	return c
+}
+
+func TestContextStringValuesRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		set    func(*gin.Context, string)
+		get    func(*gin.Context) (string, bool)
+		orZero func(*gin.Context) string
+	}{
+		{"request ID", SetRequestID, GetRequestID, RequestIDFromContext},
+		{"user ID", SetUserID, GetUserID, UserIDFromContext},
+		{"role", SetRole, GetRole, RoleFromContext},
+		{"API client", SetAPIClient, GetAPIClient, APIClientFromContext},
+		{"client CN", SetClientCN, GetClientCN, ClientCNFromContext},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestContext()
+			if value, ok := tt.get(c); ok || value != "" || tt.orZero(c) != "" {
+				t.Fatalf("unset: got %q, %t", value, ok)
+			}
+
+			tt.set(c, "value")
+			if value, ok := tt.get(c); !ok || value != "value" || tt.orZero(c) != "value" {
+				t.Fatalf("set: got %q, %t", value, ok)
+			}
+		})
+	}
+
+	// Each value has its own key
+	c := newTestContext()
+	SetUserID(c, "alice")
+	if _, ok := GetAPIClient(c); ok {
+		t.Fatal("user ID readable as the API client")
+	}
+}
+
+func TestContextTypedValuesRoundTrip(t *testing.T) {
+	c := newTestContext()
+	if FlagsFromContext(c).Enabled("new_search") || LocationFromContext(c) != time.UTC {
+		t.Fatal("unset flags and location should read as all off and UTC")
+	}
+	if _, ok := GetSession(c); ok {
+		t.Fatal("unset session reported as set")
+	}
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := Session{ID: "s1", UserID: "alice", Role: RoleUser, ExpiresAt: time.Now().Add(time.Hour)}
+	SetFlags(c, FeatureFlags{"new_search": true})
+	SetLocation(c, tokyo)
+	SetSession(c, session)
+
+	if flags, ok := GetFlags(c); !ok || !flags.Enabled("new_search") {
+		t.Fatalf("flags: got %v, %t", flags, ok)
+	}
+	if loc := LocationFromContext(c); loc != tokyo {
+		t.Fatalf("location: got %v", loc)
+	}
+	if got, ok := GetSession(c); !ok || got != session {
+		t.Fatalf("session: got %+v, %t", got, ok)
+	}
+}
+
+func TestContextValueOfWrongTypeReadsAsUnset(t *testing.T) {
+	c := newTestContext()
+	c.Set(string(userIDKey), 42)
+	if id, ok := GetUserID(c); ok || id != "" {
+		t.Fatalf("got %q, %t for a non-string value", id, ok)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORS returns a middleware that only allows cross-origin requests from
+// allowedOrigins. A "*" entry allows any origin but never sends credentials.
+func CORS(allowedOrigins []string) gin.HandlerFunc {
+	allowAny := slices.Contains(allowedOrigins, "*")
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+
+		// The response differs per Origin, so caches must key on it
+		c.Header("Vary", "Origin")
+
+		switch {
+		case origin != "" && slices.Contains(allowedOrigins, origin):
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Access-Control-Allow-Credentials", "true")
+		case allowAny:
+			c.Header("Access-Control-Allow-Origin", "*")
+		}
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, If-None-Match, If-Modified-Since, Idempotency-Key, Prefer")
+		c.Header("Access-Control-Expose-Headers", "ETag, Last-Modified, Idempotent-Replayed, Preference-Applied")
+
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
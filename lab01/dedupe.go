@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// sharedSearch is the outcome of a search shared by concurrent callers
+type sharedSearch struct {
+	results     []Result
+	total       int
+	cacheStatus string
+}
+
+// dedupedCall is a backend search in progress, shared by waiters callers.
+// done is closed once result and err are set.
+type dedupedCall struct {
+	done    chan struct{}
+	result  sharedSearch
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// DedupingSearcher collapses concurrent identical searches on another
+// Searcher into one backend call whose result every caller receives. Nothing
+// is kept once the call returns, so errors aren't cached and a later search
+// always reaches the backend.
+type DedupingSearcher struct {
+	next  Searcher
+	mu    sync.Mutex
+	calls map[string]*dedupedCall
+}
+
+// NewDedupingSearcher wraps next so a burst of identical searches costs one
+// call. Queries are compared ignoring case, as the searchers here match.
+func NewDedupingSearcher(next Searcher) *DedupingSearcher {
+	return &DedupingSearcher{next: next, calls: make(map[string]*dedupedCall)}
+}
+
+func (s *DedupingSearcher) Search(ctx context.Context, query string, limit, offset int) ([]Result, int, error) {
+	key := fmt.Sprintf("%s:%d:%d", strings.ToLower(query), limit, offset)
+	return s.shared(ctx, key, func(ctx context.Context) ([]Result, int, error) {
+		return s.next.Search(ctx, query, limit, offset)
+	})
+}
+
+func (s *DedupingSearcher) SearchAfter(ctx context.Context, query string, limit int, afterID int64) ([]Result, int, error) {
+	key := fmt.Sprintf("%s:%d:after:%d", strings.ToLower(query), limit, afterID)
+	return s.shared(ctx, key, func(ctx context.Context) ([]Result, int, error) {
+		return s.next.SearchAfter(ctx, query, limit, afterID)
+	})
+}
+
+// shared runs fetch once for all concurrent callers with the same key. The
+// call isn't canceled with whichever caller started it, so one client going
+// away doesn't fail the others, but it keeps that caller's deadline, and it
+// is canceled once every caller has gone. Each caller returns as soon as its
+// own context is done. A panic in fetch is returned to every caller as an
+// error.
+func (s *DedupingSearcher) shared(ctx context.Context, key string, fetch func(context.Context) ([]Result, int, error)) ([]Result, int, error) {
+	s.mu.Lock()
+	call, ok := s.calls[key]
+	if !ok {
+		var fetchCtx context.Context
+		var cancel context.CancelFunc
+		if deadline, ok := ctx.Deadline(); ok {
+			fetchCtx, cancel = context.WithDeadline(context.WithoutCancel(ctx), deadline)
+		} else {
+			fetchCtx, cancel = context.WithCancel(context.WithoutCancel(ctx))
+		}
+		call = &dedupedCall{done: make(chan struct{}), cancel: cancel}
+		s.calls[key] = call
+		go s.run(fetchCtx, key, call, fetch)
+	}
+	call.waiters++
+	s.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		s.leave(key, call)
+		return nil, 0, ctx.Err()
+	case <-call.done:
+		if call.err != nil {
+			return nil, 0, call.err
+		}
+		if call.result.cacheStatus != "" {
+			setCacheStatus(ctx, call.result.cacheStatus)
+		}
+		// Every caller gets its own slice, so one can't modify another's page
+		return slices.Clone(call.result.results), call.result.total, nil
+	}
+}
+
+// leave drops a caller that stopped waiting for call, canceling the search
+// when nobody is left waiting for it. A canceled call is forgotten at once,
+// so a later caller starts a fresh search instead of joining it.
+func (s *DedupingSearcher) leave(key string, call *dedupedCall) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	call.waiters--
+	if call.waiters == 0 {
+		call.cancel()
+		if s.calls[key] == call {
+			delete(s.calls, key)
+		}
+	}
+}
+
+// run performs call's search and wakes its waiters
+func (s *DedupingSearcher) run(ctx context.Context, key string, call *dedupedCall, fetch func(context.Context) ([]Result, int, error)) {
+	defer call.cancel()
+	defer func() {
+		// The search runs on a goroutine of its own, where Recovery can't
+		// catch a panic, so a panicking backend would take the process down
+		if recovered := recover(); recovered != nil {
+			slog.Error("search panicked",
+				"panic", fmt.Sprint(recovered),
+				"stack", string(debug.Stack()),
+			)
+			call.err = fmt.Errorf("search panicked: %v", recovered)
+		}
+
+		s.mu.Lock()
+		if s.calls[key] == call {
+			delete(s.calls, key)
+		}
+		s.mu.Unlock()
+		close(call.done)
+	}()
+
+	ctx, cacheStatus := withCacheStatus(ctx)
+	results, total, err := fetch(ctx)
+	call.result = sharedSearch{results: results, total: total, cacheStatus: *cacheStatus}
+	call.err = err
+}
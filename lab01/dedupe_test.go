@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gatedSearcher counts searches and holds each one until release is closed
+type gatedSearcher struct {
+	calls   atomic.Int32
+	release chan struct{}
+	fail    error
+	panic   bool
+}
+
+func (s *gatedSearcher) Search(ctx context.Context, query string, limit, offset int) ([]Result, int, error) {
+	s.calls.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+	if s.panic {
+		panic("backend exploded")
+	}
+	if s.fail != nil {
+		return nil, 0, s.fail
+	}
+	return []Result{{ID: 1, Title: query}}, 1, nil
+}
+
+func (s *gatedSearcher) SearchAfter(ctx context.Context, query string, limit int, afterID int64) ([]Result, int, error) {
+	return s.Search(ctx, query, limit, 0)
+}
+
+func TestDedupingSearcherSharesConcurrentCalls(t *testing.T) {
+	backend := &gatedSearcher{release: make(chan struct{})}
+	searcher := NewDedupingSearcher(backend)
+
+	const callers = 10
+	var started, done sync.WaitGroup
+	results := make([][]Result, callers)
+	errs := make([]error, callers)
+	started.Add(callers)
+	done.Add(callers)
+	for i := range callers {
+		go func() {
+			defer done.Done()
+			started.Done()
+			// Queries differing only in case share the call
+			query := "go"
+			if i%2 == 1 {
+				query = "Go"
+			}
+			results[i], _, errs[i] = searcher.Search(context.Background(), query, 10, 0)
+		}()
+	}
+	started.Wait()
+	// Give every caller time to join the call before it returns
+	time.Sleep(50 * time.Millisecond)
+	close(backend.release)
+	done.Wait()
+
+	if calls := backend.calls.Load(); calls != 1 {
+		t.Fatalf("backend called %d times, want 1", calls)
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("caller %d: %v", i, err)
+		}
+	}
+
+	// Each caller owns its slice
+	results[0][0].Title = "changed"
+	for i := 1; i < callers; i++ {
+		if results[i][0].Title == "changed" {
+			t.Fatalf("caller %d shares its result slice with caller 0", i)
+		}
+	}
+}
+
+func TestDedupingSearcherKeysOnPage(t *testing.T) {
+	backend := &gatedSearcher{}
+	searcher := NewDedupingSearcher(backend)
+
+	searcher.Search(context.Background(), "go", 10, 0)
+	searcher.Search(context.Background(), "go", 10, 10)
+	searcher.SearchAfter(context.Background(), "go", 10, 5)
+
+	if calls := backend.calls.Load(); calls != 3 {
+		t.Fatalf("backend called %d times, want 3", calls)
+	}
+}
+
+func TestDedupingSearcherDoesNotCacheErrors(t *testing.T) {
+	backend := &gatedSearcher{fail: errors.New("backend down")}
+	searcher := NewDedupingSearcher(backend)
+
+	if _, _, err := searcher.Search(context.Background(), "go", 10, 0); err == nil {
+		t.Fatal("want the backend error")
+	}
+	backend.fail = nil
+	results, _, err := searcher.Search(context.Background(), "go", 10, 0)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("got %v, %v; want the retried result", results, err)
+	}
+	if calls := backend.calls.Load(); calls != 2 {
+		t.Fatalf("backend called %d times, want 2", calls)
+	}
+}
+
+func TestDedupingSearcherReturnsPanicsAsErrors(t *testing.T) {
+	backend := &gatedSearcher{panic: true}
+	searcher := NewDedupingSearcher(backend)
+
+	// Reaching the assertions at all means the panic didn't escape on
+	// the search goroutine and kill the test binary
+	_, _, err := searcher.Search(context.Background(), "go", 10, 0)
+	if err == nil {
+		t.Fatal("want an error for the panicking search")
+	}
+
+	backend.panic = false
+	if _, _, err := searcher.Search(context.Background(), "go", 10, 0); err != nil {
+		t.Fatalf("search after the panic: %v", err)
+	}
+}
+
+func TestDedupingSearcherCallerCancelDoesNotFailOthers(t *testing.T) {
+	backend := &gatedSearcher{release: make(chan struct{})}
+	searcher := NewDedupingSearcher(backend)
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, _, err := searcher.Search(leaderCtx, "go", 10, 0)
+		leaderErr <- err
+	}()
+	for backend.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	followerErr := make(chan error, 1)
+	go func() {
+		_, _, err := searcher.Search(context.Background(), "go", 10, 0)
+		followerErr <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	cancelLeader()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("leader got %v, want context.Canceled", err)
+	}
+	close(backend.release)
+	if err := <-followerErr; err != nil {
+		t.Fatalf("follower got %v, want the shared result", err)
+	}
+}
+
+// blockingSearcher holds each search until its context is done, reporting
+// the context's error on canceled
+type blockingSearcher struct {
+	calls    atomic.Int32
+	canceled chan error
+}
+
+func (s *blockingSearcher) Search(ctx context.Context, query string, limit, offset int) ([]Result, int, error) {
+	s.calls.Add(1)
+	<-ctx.Done()
+	s.canceled <- ctx.Err()
+	return nil, 0, ctx.Err()
+}
+
+func (s *blockingSearcher) SearchAfter(ctx context.Context, query string, limit int, afterID int64) ([]Result, int, error) {
+	return s.Search(ctx, query, limit, 0)
+}
+
+func TestDedupingSearcherCancelsWhenLastCallerLeaves(t *testing.T) {
+	backend := &blockingSearcher{canceled: make(chan error, 2)}
+	searcher := NewDedupingSearcher(backend)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	callerErr := make(chan error, 1)
+	go func() {
+		_, _, err := searcher.Search(ctx, "go", 10, 0)
+		callerErr <- err
+	}()
+	for backend.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-callerErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("caller got %v, want context.Canceled", err)
+	}
+	select {
+	case err := <-backend.canceled:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("backend search ended with %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("backend search kept running with nobody waiting for it")
+	}
+
+	// A later caller starts a fresh search rather than joining the canceled one
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	searcher.Search(ctx, "go", 10, 0)
+	if calls := backend.calls.Load(); calls != 2 {
+		t.Fatalf("backend called %d times, want a second search", calls)
+	}
+}
+
+func TestDedupingSearcherKeepsSearchWhileCallersWait(t *testing.T) {
+	backend := &blockingSearcher{canceled: make(chan error, 1)}
+	searcher := NewDedupingSearcher(backend)
+
+	first, cancelFirst := context.WithCancel(context.Background())
+	second, cancelSecond := context.WithCancel(context.Background())
+	done := make(chan struct{}, 2)
+	for _, ctx := range []context.Context{first, second} {
+		go func() {
+			searcher.Search(ctx, "go", 10, 0)
+			done <- struct{}{}
+		}()
+	}
+	for backend.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	cancelFirst()
+	<-done
+	select {
+	case err := <-backend.canceled:
+		t.Fatalf("backend search canceled (%v) while a caller still waits", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancelSecond()
+	<-done
+	select {
+	case <-backend.canceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("backend search kept running after the last caller left")
+	}
+	if calls := backend.calls.Load(); calls != 1 {
+		t.Fatalf("backend called %d times, want 1 shared search", calls)
+	}
+}
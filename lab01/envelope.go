@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// envelopePreference is the Prefer header token clients send to have
+// responses wrapped in an Envelope
+const envelopePreference = "envelope"
+
+// Envelope is the consistent response shape some frontends prefer. Exactly
+// one of Data and Error is set.
+type Envelope struct {
+	Data  any       `json:"data"`
+	Meta  *ListMeta `json:"meta"`
+	Error *APIError `json:"error"`
+}
+
+// ListMeta describes the page of results carried in an Envelope's data
+type ListMeta struct {
+	Query string `json:"query,omitempty"`
+	Pagination
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// wantsEnvelope reports whether the request carries "Prefer: envelope"
+func wantsEnvelope(c *gin.Context) bool {
+	for _, header := range c.Request.Header.Values("Prefer") {
+		for _, preference := range strings.Split(header, ",") {
+			token, _, _ := strings.Cut(preference, ";")
+			if strings.EqualFold(strings.TrimSpace(token), envelopePreference) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RespondData writes data as JSON, wrapped in an Envelope when the client
+// asked for one
+func RespondData(c *gin.Context, status int, data any) {
+	// The body's shape depends on Prefer, so caches must key on it
+	c.Writer.Header().Add("Vary", "Prefer")
+	if wantsEnvelope(c) {
+		c.Header("Preference-Applied", envelopePreference)
+		c.JSON(status, Envelope{Data: data})
+		return
+	}
+	c.JSON(status, data)
+}
+
+// RespondList writes body, the endpoint's usual list response, or when the
+// client asked for an envelope, items with meta carrying the pagination
+func RespondList(c *gin.Context, status int, body, items any, meta ListMeta) {
+	c.Writer.Header().Add("Vary", "Prefer")
+	if wantsEnvelope(c) {
+		c.Header("Preference-Applied", envelopePreference)
+		c.JSON(status, Envelope{Data: items, Meta: &meta})
+		return
+	}
+	c.JSON(status, body)
+}
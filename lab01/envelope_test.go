@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// envelopeRouter serves the user and search endpoints over seeded users Ann
+// and Bob and a search returning one result
+func envelopeRouter(t *testing.T) *gin.Engine {
+	store := NewMemoryUserStore()
+	seedUsers(t, store, "Ann", "Bob")
+	maxLimit := func() int { return 100 }
+	searcher := &fakeSearcher{results: []Result{{ID: 7, Title: "Go"}}, total: 1}
+
+	r := gin.New()
+	r.GET("/user/:id", getUserHandler(store))
+	r.GET("/users", listUsersHandler(store, maxLimit))
+	r.GET("/search", searchHandler(searcher, searcher, maxLimit))
+	return r
+}
+
+// getEnveloped GETs target on h with "Prefer: envelope" and decodes the
+// envelope, keeping data raw for the caller to decode
+func getEnveloped(t *testing.T, h http.Handler, target string) (*httptest.ResponseRecorder, map[string]json.RawMessage) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Prefer", "return=minimal, envelope")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("%s: decode %q: %v", target, w.Body, err)
+	}
+	if len(envelope) != 3 || envelope["data"] == nil || envelope["meta"] == nil || envelope["error"] == nil {
+		t.Fatalf("%s: got %s, want exactly data, meta and error", target, w.Body)
+	}
+	if w.Header().Get("Preference-Applied") != envelopePreference {
+		t.Fatalf("%s: Preference-Applied is %q", target, w.Header().Get("Preference-Applied"))
+	}
+	return w, envelope
+}
+
+func TestEnvelopeSingleResource(t *testing.T) {
+	r := envelopeRouter(t)
+
+	w, envelope := getEnveloped(t, r, "/user/1")
+	var user User
+	if err := json.Unmarshal(envelope["data"], &user); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || user.Name != "Ann" || string(envelope["meta"]) != "null" || string(envelope["error"]) != "null" {
+		t.Fatalf("got %d %s, want Ann as data with null meta and error", w.Code, w.Body)
+	}
+
+	plain := get(r, "/user/1")
+	if err := json.Unmarshal(plain.Body.Bytes(), &user); err != nil || user.Name != "Ann" {
+		t.Fatalf("without Prefer got %s, want the bare user", plain.Body)
+	}
+	if plain.Header().Get("Vary") != "Prefer" {
+		t.Fatalf("Vary is %q, want Prefer", plain.Header().Get("Vary"))
+	}
+}
+
+func TestEnvelopeList(t *testing.T) {
+	r := envelopeRouter(t)
+
+	_, envelope := getEnveloped(t, r, "/users?limit=1&page=2")
+	var users []User
+	if err := json.Unmarshal(envelope["data"], &users); err != nil {
+		t.Fatal(err)
+	}
+	var meta ListMeta
+	if err := json.Unmarshal(envelope["meta"], &meta); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].Name != "Bob" {
+		t.Fatalf("data is %s, want just Bob", envelope["data"])
+	}
+	if meta.Total != 2 || meta.Limit != 1 || meta.Page != 2 || meta.Offset != 1 {
+		t.Fatalf("meta is %+v, want total 2 on page 2 of size 1", meta)
+	}
+
+	_, envelope = getEnveloped(t, r, "/search?q=go")
+	if err := json.Unmarshal(envelope["meta"], &meta); err != nil {
+		t.Fatal(err)
+	}
+	var results []Result
+	if err := json.Unmarshal(envelope["data"], &results); err != nil {
+		t.Fatal(err)
+	}
+	if meta.Query != "go" || meta.Total != 1 || len(results) != 1 || results[0].ID != 7 {
+		t.Fatalf("search envelope has data %s and meta %+v", envelope["data"], meta)
+	}
+}
+
+func TestEnvelopeError(t *testing.T) {
+	w, envelope := getEnveloped(t, envelopeRouter(t), "/user/99")
+
+	var apiErr APIError
+	if err := json.Unmarshal(envelope["error"], &apiErr); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusNotFound || apiErr.Code != CodeNotFound || string(envelope["data"]) != "null" {
+		t.Fatalf("got %d %s, want a 404 with the APIError in error and null data", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Machine-readable error codes returned in APIError.Code
+const (
+	CodeInvalidQuery         = "INVALID_QUERY"
+	CodeInvalidRequest       = "INVALID_REQUEST"
+	CodeInvalidID            = "INVALID_ID"
+	CodeUnauthorized         = "UNAUTHORIZED"
+	CodeForbidden            = "FORBIDDEN"
+	CodeNotFound             = "NOT_FOUND"
+	CodeMethodNotAllowed     = "METHOD_NOT_ALLOWED"
+	CodeConflict             = "CONFLICT"
+	CodePayloadTooLarge      = "PAYLOAD_TOO_LARGE"
+	CodePreconditionFailed   = "PRECONDITION_FAILED"
+	CodePreconditionRequired = "PRECONDITION_REQUIRED"
+	CodeRateLimited          = "RATE_LIMITED"
+	CodeQuotaExceeded        = "QUOTA_EXCEEDED"
+	CodeInternal             = "INTERNAL_ERROR"
+	CodeUnavailable          = "SERVICE_UNAVAILABLE"
+	CodeTimeout              = "TIMEOUT"
+)
+
+// APIError is the body of every error response. The human-readable message
+// stays under the "error" key that clients already read; Code is the stable
+// value to branch on programmatically.
+type APIError struct {
+	Code      string         `json:"code"`
+	Message   string         `json:"error"`
+	Details   map[string]any `json:"details,omitempty"`
+	RequestID string         `json:"request_id,omitempty"`
+}
+
+// RespondError aborts the request with an APIError body
+func RespondError(c *gin.Context, status int, code, message string) {
+	RespondErrorWithDetails(c, status, code, message, nil)
+}
+
+// RespondErrorWithDetails aborts the request with an APIError body carrying
+// extra structured details. The message is translated to the language asked
+// for by Accept-Language when a translation exists; the code never changes.
+func RespondErrorWithDetails(c *gin.Context, status int, code, message string, details map[string]any) {
+	lang, message := localizeMessage(c, code, message)
+	c.Header("Content-Language", lang.String())
+	c.Writer.Header().Add("Vary", "Accept-Language")
+
+	apiErr := APIError{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: RequestIDFromContext(c),
+	}
+	if wantsEnvelope(c) {
+		c.Header("Preference-Applied", envelopePreference)
+		c.AbortWithStatusJSON(status, Envelope{Error: &apiErr})
+		return
+	}
+	c.AbortWithStatusJSON(status, apiErr)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errInvalidETag is returned for If-Match values that aren't a version ETag
+var errInvalidETag = errors.New("invalid ETag")
+
+// versionETag returns the strong ETag for a resource version with its
+// timestamps in loc. The same version formatted in another time zone is a
+// different representation, so anything but UTC is appended after an "@":
+// "3" in UTC, "3@America/New_York" otherwise.
+func versionETag(version int64, loc *time.Location) string {
+	tag := strconv.FormatInt(version, 10)
+	if loc != time.UTC {
+		tag += "@" + loc.String()
+	}
+	return `"` + tag + `"`
+}
+
+// parseVersionETag returns the version in an ETag produced by versionETag,
+// whatever its time zone. Weak tags are rejected because If-Match requires
+// strong comparison.
+func parseVersionETag(etag string) (int64, error) {
+	unquoted, ok := strings.CutPrefix(etag, `"`)
+	if !ok {
+		return 0, errInvalidETag
+	}
+	unquoted, ok = strings.CutSuffix(unquoted, `"`)
+	if !ok {
+		return 0, errInvalidETag
+	}
+
+	unquoted, _, _ = strings.Cut(unquoted, "@")
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version < 1 {
+		return 0, errInvalidETag
+	}
+	return version, nil
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag,
+// using the weak comparison RFC 9110 requires for GET
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestVersionETag(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versionETag(3, time.UTC); got != `"3"` {
+		t.Fatalf("UTC: got %s", got)
+	}
+	if got := versionETag(3, newYork); got != `"3@America/New_York"` {
+		t.Fatalf("New York: got %s", got)
+	}
+}
+
+func TestParseVersionETag(t *testing.T) {
+	tests := []struct {
+		etag    string
+		want    int64
+		wantErr bool
+	}{
+		{`"3"`, 3, false},
+		{`"3@America/New_York"`, 3, false},
+		{`W/"3"`, 0, true},
+		{`3`, 0, true},
+		{`"0"`, 0, true},
+		{`"-1"`, 0, true},
+		{`"abc"`, 0, true},
+		{`"@UTC"`, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseVersionETag(tt.etag)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseVersionETag(%s) = %d, %v; want %d, error %v", tt.etag, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"3"`, true},
+		{`W/"3"`, true},
+		{`"2", "3"`, true},
+		{`*`, true},
+		{`"4"`, false},
+		{`"3@America/New_York"`, false},
+		{``, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, `"3"`); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+// userETagRouter serves GET, PUT and PATCH of one seeded user with the tz
+// middleware
+func userETagRouter(t *testing.T) http.Handler {
+	t.Helper()
+	store := NewMemoryUserStore()
+	seedUsers(t, store, "Ann")
+	audit := NewAuditLogger(&MemoryAuditStore{})
+
+	r := gin.New()
+	r.GET("/user/:id", Timezone(), getUserHandler(store))
+	r.PUT("/user/:id", Timezone(), updateUserHandler(store, nil, audit))
+	r.PATCH("/user/:id", Timezone(), patchUserHandler(store, nil, audit))
+	return r
+}
+
+// conditionalRequest sends method to target on h with header set to etag
+func conditionalRequest(h http.Handler, method, target, header, etag, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(header, etag)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetUserETagDependsOnTimeZone(t *testing.T) {
+	r := userETagRouter(t)
+
+	utc := get(r, "/user/1").Header().Get("ETag")
+	local := get(r, "/user/1?tz=America/New_York").Header().Get("ETag")
+	if utc == "" || local == "" || utc == local {
+		t.Fatalf("ETags %s and %s should both be set and differ", utc, local)
+	}
+
+	if w := conditionalRequest(r, http.MethodGet, "/user/1?tz=America/New_York", "If-None-Match", utc, ""); w.Code != http.StatusOK {
+		t.Fatalf("UTC ETag revalidated a New York copy: got %d", w.Code)
+	}
+	if w := conditionalRequest(r, http.MethodGet, "/user/1?tz=America/New_York", "If-None-Match", local, ""); w.Code != http.StatusNotModified {
+		t.Fatalf("matching ETag: got %d, want 304", w.Code)
+	}
+	if w := conditionalRequest(r, http.MethodGet, "/user/1", "If-None-Match", local, ""); w.Code != http.StatusOK {
+		t.Fatalf("New York ETag revalidated a UTC copy: got %d", w.Code)
+	}
+}
+
+func TestIfMatchAcceptsTimeZoneETag(t *testing.T) {
+	r := userETagRouter(t)
+
+	etag := get(r, "/user/1?tz=Asia/Tokyo").Header().Get("ETag")
+	w := conditionalRequest(r, http.MethodPut, "/user/1?tz=Asia/Tokyo", "If-Match", etag,
+		`{"name":"Ann Lee","email":"Ann@example.com"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT: got %d: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("ETag"); got != `"2@Asia/Tokyo"` {
+		t.Fatalf("PUT ETag is %s", got)
+	}
+
+	if w := conditionalRequest(r, http.MethodPatch, "/user/1", "If-Match", etag, `{"name":"Ann"}`); w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale PATCH: got %d, want 412", w.Code)
+	}
+	if w := conditionalRequest(r, http.MethodPatch, "/user/1", "If-Match", `"2@Asia/Tokyo"`, `{"name":"Ann"}`); w.Code != http.StatusOK {
+		t.Fatalf("PATCH: got %d: %s", w.Code, w.Body)
+	}
+	stored := get(r, "/user/1")
+	if !strings.Contains(stored.Body.String(), `"version":3`) {
+		t.Fatalf("user wasn't updated: %s", stored.Body)
+	}
+}
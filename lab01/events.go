@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HeartbeatEvent is the payload of each server-sent heartbeat
+type HeartbeatEvent struct {
+	Time          string `json:"time"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+}
+
+// eventsHandler streams a JSON heartbeat every interval until the client
+// disconnects or shutdown is canceled
+//
+//	@Summary	Server-sent heartbeat events
+//	@Tags		realtime
+//	@Produce	text/event-stream
+//	@Success	200	{object}	HeartbeatEvent
+//	@Router		/events [get]
+func eventsHandler(shutdown context.Context, interval time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		// Stop reverse proxies such as nginx from buffering the stream
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+
+		activeConnections.WithLabelValues("sse").Inc()
+		defer activeConnections.WithLabelValues("sse").Dec()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		first := true
+		c.Stream(func(w io.Writer) bool {
+			// Send the first heartbeat immediately, then one per tick
+			if !first {
+				select {
+				case <-c.Request.Context().Done():
+					return false
+				case <-shutdown.Done():
+					return false
+				case <-ticker.C:
+				}
+			}
+			first = false
+
+			c.SSEvent("heartbeat", HeartbeatEvent{
+				Time:          time.Now().UTC().Format(time.RFC3339),
+				UptimeSeconds: int64(time.Since(startTime).Seconds()),
+			})
+			return true
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventsServer serves /events with a short heartbeat interval until
+// shutdown is canceled
+func eventsServer(t *testing.T, shutdown context.Context) *httptest.Server {
+	t.Helper()
+	r := gin.New()
+	r.GET("/events", eventsHandler(shutdown, 10*time.Millisecond))
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// openEvents starts reading the event stream at server with ctx
+func openEvents(t *testing.T, ctx context.Context, server *httptest.Server) *http.Response {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// readHeartbeats reads n heartbeat events from the stream
+func readHeartbeats(t *testing.T, stream *bufio.Scanner, n int) []HeartbeatEvent {
+	t.Helper()
+	var events []HeartbeatEvent
+	var name string
+	for len(events) < n && stream.Scan() {
+		line := stream.Text()
+		if value, ok := strings.CutPrefix(line, "event:"); ok {
+			name = value
+			continue
+		}
+		if data, ok := strings.CutPrefix(line, "data:"); ok {
+			if name != "heartbeat" {
+				t.Fatalf("got event %q", name)
+			}
+			var event HeartbeatEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				t.Fatalf("data %q isn't JSON: %v", data, err)
+			}
+			events = append(events, event)
+		}
+	}
+	if len(events) < n {
+		t.Fatalf("stream ended after %d events: %v", len(events), stream.Err())
+	}
+	return events
+}
+
+func TestEventsStreamsHeartbeats(t *testing.T) {
+	server := eventsServer(t, context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resp := openEvents(t, ctx, server)
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("Content-Type is %q", ct)
+	}
+	if cc := resp.Header.Get("Cache-Control"); cc != "no-cache" {
+		t.Fatalf("Cache-Control is %q", cc)
+	}
+
+	for _, event := range readHeartbeats(t, bufio.NewScanner(resp.Body), 2) {
+		if _, err := time.Parse(time.RFC3339, event.Time); err != nil {
+			t.Fatalf("time %q: %v", event.Time, err)
+		}
+	}
+	waitForActiveConnections(t, "sse", 1)
+
+	// The handler returns once the client goes away
+	cancel()
+	waitForActiveConnections(t, "sse", 0)
+}
+
+func TestEventsEndOnShutdown(t *testing.T) {
+	shutdown, stop := context.WithCancel(context.Background())
+	server := eventsServer(t, shutdown)
+
+	resp := openEvents(t, context.Background(), server)
+	stream := bufio.NewScanner(resp.Body)
+	readHeartbeats(t, stream, 1)
+
+	stop()
+	done := make(chan struct{})
+	go func() {
+		for stream.Scan() {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream still open after shutdown")
+	}
+	waitForActiveConnections(t, "sse", 0)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamBatchSize is how many results are fetched and flushed at a time when
+// streaming NDJSON or CSV
+const streamBatchSize = 100
+
+// csvHeader is the header row of CSV search exports
+var csvHeader = []string{"id", "title", "snippet"}
+
+// csvRecord is the CSV row for result
+func csvRecord(result Result) []string {
+	return []string{strconv.FormatInt(result.ID, 10), result.Title, result.Snippet}
+}
+
+// writeResultsCSV writes one page of results to the client as a CSV
+// attachment
+func writeResultsCSV(c *gin.Context, results []Result) error {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename=results.csv`)
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	if err := w.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, result := range results {
+		if err := w.Write(csvRecord(result)); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// streamResults fetches every match for the request's q in batches with
+// SearchAfter and passes each batch to write, flushing after it so clients
+// can process results incrementally. begin sets the headers and writes any
+// preamble once the first batch is in, so a search that fails straight away
+// still gets an error status. The route must be exempt from Timeout, which
+// buffers responses.
+func streamResults(c *gin.Context, searcher Searcher, begin func() error, write func([]Result) error) {
+	query := c.Query("q")
+	if query == "" {
+		RespondError(c, http.StatusBadRequest, CodeInvalidQuery, "Query parameter 'q' is required")
+		return
+	}
+
+	ctx := c.Request.Context()
+	var afterID int64
+	for {
+		results, _, err := searcher.SearchAfter(ctx, query, streamBatchSize, afterID)
+		if err != nil {
+			// Once streaming has begun the status can't change
+			if c.Writer.Written() {
+				slog.Warn("Search stream interrupted", "path", c.FullPath(), "error", err)
+			} else {
+				respondSearchError(c, err)
+			}
+			return
+		}
+
+		if !c.Writer.Written() {
+			if err := begin(); err != nil {
+				return
+			}
+		}
+		if err := write(results); err != nil {
+			return
+		}
+		c.Writer.Flush()
+
+		// Stop at the last page or once the client has gone away
+		if len(results) < streamBatchSize || ctx.Err() != nil {
+			return
+		}
+		afterID = results[len(results)-1].ID
+	}
+}
+
+// searchStreamHandler streams every match as newline-delimited JSON
+//
+//	@Summary	Stream all search results
+//	@Tags		search
+//	@Produce	application/x-ndjson
+//	@Param		q	query		string	true	"Search query"
+//	@Success	200	{object}	Result	"One Result per line"
+//	@Failure	400	{object}	APIError
+//	@Failure	500	{object}	APIError
+//	@Router		/v1/search/stream [get]
+func searchStreamHandler(searcher Searcher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		enc := json.NewEncoder(c.Writer)
+		streamResults(c, searcher, func() error {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(http.StatusOK)
+			return nil
+		}, func(results []Result) error {
+			for _, result := range results {
+				if err := enc.Encode(result); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// searchExportHandler streams every match as a CSV attachment with a header
+// row, for spreadsheets. Unlike format=csv on /search it isn't limited to a
+// page, and rows reach the client batch by batch rather than all at once.
+//
+//	@Summary	Export all search results as CSV
+//	@Tags		search
+//	@Produce	text/csv
+//	@Param		q	query		string				true	"Search query"
+//	@Success	200	{string}	string				"id,title,snippet header, then one row per result"
+//	@Header		200	{string}	Content-Disposition	"attachment; filename=results.csv"
+//	@Failure	400	{object}	APIError
+//	@Failure	500	{object}	APIError
+//	@Router		/v1/search.csv [get]
+func searchExportHandler(searcher Searcher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w := csv.NewWriter(c.Writer)
+		streamResults(c, searcher, func() error {
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			c.Header("Content-Disposition", `attachment; filename=results.csv`)
+			c.Status(http.StatusOK)
+			return w.Write(csvHeader)
+		}, func(results []Result) error {
+			for _, result := range results {
+				if err := w.Write(csvRecord(result)); err != nil {
+					return err
+				}
+			}
+			w.Flush()
+			if err := w.Error(); err != nil {
+				slog.Warn("CSV export interrupted", "error", err)
+				return err
+			}
+			return nil
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// goDocuments returns n documents, IDs 1 to n, all matching "go"
+func goDocuments(n int) []Result {
+	docs := make([]Result, n)
+	for i := range docs {
+		docs[i] = Result{ID: int64(i + 1), Title: fmt.Sprintf("Go %d", i+1), Snippet: "about go, with a comma"}
+	}
+	return docs
+}
+
+// heldSearcher answers the first SearchAfter at once and every later one
+// only after release is closed
+type heldSearcher struct {
+	Searcher
+	first   bool
+	release chan struct{}
+}
+
+func (s *heldSearcher) SearchAfter(ctx context.Context, query string, limit int, afterID int64) ([]Result, int, error) {
+	if s.first {
+		select {
+		case <-s.release:
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+	}
+	s.first = true
+	return s.Searcher.SearchAfter(ctx, query, limit, afterID)
+}
+
+func TestSearchCSVFormat(t *testing.T) {
+	docs := goDocuments(3)
+	w := get(searchRouter(&fakeSearcher{results: docs, total: 3}), "/search?q=go&format=csv")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != "attachment; filename=results.csv" {
+		t.Fatalf("Content-Disposition %q", got)
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || strings.Join(records[0], ",") != "id,title,snippet" {
+		t.Fatalf("got %d records starting %v, want a header and 3 rows", len(records), records[0])
+	}
+	if records[1][0] != "1" || records[1][2] != "about go, with a comma" {
+		t.Fatalf("first row %v", records[1])
+	}
+}
+
+func TestSearchFormatDefaultsToJSON(t *testing.T) {
+	w := get(searchRouter(&fakeSearcher{}), "/search?q=go")
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("Content-Type %q", w.Header().Get("Content-Type"))
+	}
+	if w := get(searchRouter(&fakeSearcher{}), "/search?q=go&format=xml"); w.Code != http.StatusBadRequest {
+		t.Fatalf("format=xml: got %d, want 400", w.Code)
+	}
+}
+
+func TestSearchExportStreamsEveryMatch(t *testing.T) {
+	r := gin.New()
+	r.GET("/search.csv", searchExportHandler(NewMemorySearcher(goDocuments(250))))
+
+	w := get(r, "/search.csv?q=go")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 251 {
+		t.Fatalf("got %d records, want a header and 250 rows across 3 batches", len(records))
+	}
+	if records[250][0] != "250" {
+		t.Fatalf("last row %v", records[250])
+	}
+
+	// No matches still gets the header
+	w = get(r, "/search.csv?q=rust")
+	if w.Code != http.StatusOK || w.Body.String() != "id,title,snippet\n" {
+		t.Fatalf("no matches: got %d %q", w.Code, w.Body)
+	}
+	if w := get(r, "/search.csv"); w.Code != http.StatusBadRequest {
+		t.Fatalf("missing q: got %d, want 400", w.Code)
+	}
+}
+
+func TestSearchExportFailingSearchGetsErrorStatus(t *testing.T) {
+	r := gin.New()
+	r.GET("/search.csv", searchExportHandler(&fakeSearcher{err: errors.New("backend down")}))
+	if w := get(r, "/search.csv?q=go"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("got %d, want 500", w.Code)
+	}
+}
+
+// TestSearchExportFlushesThroughTimeout reads the first batch of rows while
+// the second is still being searched for, past the request timeout, which
+// only works if Timeout passes the export through unbuffered
+func TestSearchExportFlushesThroughTimeout(t *testing.T) {
+	release := make(chan struct{})
+	searcher := &heldSearcher{Searcher: NewMemorySearcher(goDocuments(150)), release: release}
+
+	r := gin.New()
+	r.Use(Timeout(50*time.Millisecond, "/search.csv"))
+	r.GET("/search.csv", searchExportHandler(searcher))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/search.csv?q=go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got %d", resp.StatusCode)
+	}
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	// Header and the first 100 rows arrive before the next batch is searched
+	for i := range 1 + streamBatchSize {
+		select {
+		case _, ok := <-lines:
+			if !ok {
+				t.Fatalf("stream ended after %d lines", i)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("line %d not flushed while the next batch is pending", i+1)
+		}
+	}
+
+	// Outlive the request timeout, then let the rest through
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	var rest []string
+	for line := range lines {
+		rest = append(rest, line)
+	}
+	if len(rest) != 50 || !strings.HasPrefix(rest[49], "150,") {
+		t.Fatalf("got %d more lines, want the remaining 50 rows: %v", len(rest), rest)
+	}
+}
+
+func TestSearchStreamNDJSON(t *testing.T) {
+	r := gin.New()
+	r.GET("/search/stream", searchStreamHandler(NewMemorySearcher(goDocuments(150))))
+
+	w := get(r, "/search/stream?q=go")
+	if w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("Content-Type %q", w.Header().Get("Content-Type"))
+	}
+	dec := json.NewDecoder(w.Body)
+	var n int
+	for dec.More() {
+		var result Result
+		if err := dec.Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		n++
+		if result.ID != int64(n) {
+			t.Fatalf("line %d has ID %d", n, result.ID)
+		}
+	}
+	if n != 150 {
+		t.Fatalf("got %d results, want 150", n)
+	}
+}
+
+// cancelingSearcher counts SearchAfter calls and cancels the request, as a
+// departing client would, after the first
+type cancelingSearcher struct {
+	Searcher
+	cancel context.CancelFunc
+	calls  int
+}
+
+func (s *cancelingSearcher) SearchAfter(ctx context.Context, query string, limit int, afterID int64) ([]Result, int, error) {
+	s.calls++
+	results, total, err := s.Searcher.SearchAfter(ctx, query, limit, afterID)
+	s.cancel()
+	return results, total, err
+}
+
+func TestSearchStreamNDJSONFlushesLineByLine(t *testing.T) {
+	searcher := &heldSearcher{Searcher: NewMemorySearcher(goDocuments(250)), release: make(chan struct{})}
+	r := gin.New()
+	r.GET("/search/stream", searchStreamHandler(searcher))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/search/stream?q=go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// The first batch arrives while the second search is still held
+	lines := bufio.NewScanner(resp.Body)
+	n := 0
+	for n < streamBatchSize && lines.Scan() {
+		var result Result
+		if err := json.Unmarshal(lines.Bytes(), &result); err != nil {
+			t.Fatalf("line %d %q: %v", n+1, lines.Text(), err)
+		}
+		n++
+		if result.ID != int64(n) {
+			t.Fatalf("line %d has ID %d", n, result.ID)
+		}
+	}
+	if n != streamBatchSize {
+		t.Fatalf("read %d lines before the stream ended: %v", n, lines.Err())
+	}
+
+	close(searcher.release)
+	for lines.Scan() {
+		n++
+	}
+	if n != 250 {
+		t.Fatalf("got %d lines, want 250", n)
+	}
+}
+
+func TestSearchStreamStopsWhenClientLeaves(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	searcher := &cancelingSearcher{Searcher: NewMemorySearcher(goDocuments(1000)), cancel: cancel}
+	r := gin.New()
+	r.GET("/search/stream", searchStreamHandler(searcher))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search/stream?q=go", nil).WithContext(ctx))
+	if searcher.calls != 1 {
+		t.Fatalf("searched %d batches after the client left, want 1", searcher.calls)
+	}
+	if got := strings.Count(w.Body.String(), "\n"); got != streamBatchSize {
+		t.Fatalf("wrote %d lines, want %d", got, streamBatchSize)
+	}
+}
+
+func TestSearchStreamRequiresQuery(t *testing.T) {
+	r := gin.New()
+	r.GET("/search/stream", searchStreamHandler(NewMemorySearcher(goDocuments(1))))
+	if w := get(r, "/search/stream"); w.Code != http.StatusBadRequest {
+		t.Fatalf("got %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FeatureFlags maps flag names to whether they're enabled
+type FeatureFlags map[string]bool
+
+// Enabled reports whether name is switched on; unknown flags are off
+func (f FeatureFlags) Enabled(name string) bool {
+	return f[name]
+}
+
+// FlagStore holds the current feature flags and reloads them from the source
+// named by the current configuration: FEATURE_FLAGS_FILE when it's set,
+// otherwise the inline FEATURE_FLAGS JSON
+type FlagStore struct {
+	current atomic.Pointer[FeatureFlags]
+}
+
+// NewFlagStore loads flags from the current configuration's source
+func NewFlagStore() (*FlagStore, error) {
+	s := &FlagStore{}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Load returns the current flags
+func (s *FlagStore) Load() FeatureFlags {
+	return *s.current.Load()
+}
+
+// Reload re-reads the flag source, keeping the current flags on error. Call
+// it after ReloadConfig so a changed FEATURE_FLAGS or FEATURE_FLAGS_FILE
+// takes effect.
+func (s *FlagStore) Reload() error {
+	cfg := CurrentConfig()
+	data := []byte(cfg.FeatureFlags)
+	if cfg.FeatureFlagsFile != "" {
+		var err error
+		if data, err = os.ReadFile(cfg.FeatureFlagsFile); err != nil {
+			return fmt.Errorf("read feature flags: %w", err)
+		}
+	}
+
+	flags := FeatureFlags{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &flags); err != nil {
+			return fmt.Errorf("parse feature flags: %w", err)
+		}
+	}
+
+	s.current.Store(&flags)
+	return nil
+}
+
+// Flags returns a middleware storing a snapshot of the current flags in the
+// context, so a request sees consistent values even across a reload
+func Flags(store *FlagStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		SetFlags(c, store.Load())
+		c.Next()
+	}
+}
+
+// flagsHandler reports the current feature flags
+//
+//	@Summary	List feature flags
+//	@Tags		admin
+//	@Produce	json
+//	@Security	AdminAuth
+//	@Success	200	{object}	map[string]bool
+//	@Failure	401
+//	@Failure	403	{object}	APIError
+//	@Router		/admin/flags [get]
+func flagsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, FlagsFromContext(c))
+}
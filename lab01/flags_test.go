@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// flagsRouter serves /search and /flags behind the Flags middleware, with
+// oldSearcher and newSearcher on either side of the new_search flag
+func flagsRouter(store *FlagStore, oldSearcher, newSearcher Searcher) *gin.Engine {
+	r := gin.New()
+	r.Use(Flags(store))
+	r.GET("/search", searchHandler(oldSearcher, newSearcher, func() int { return 100 }))
+	r.GET("/flags", flagsHandler)
+	return r
+}
+
+func TestNewSearchFlagSwitchesBackend(t *testing.T) {
+	store := &FlagStore{}
+	store.current.Store(&FeatureFlags{"new_search": false})
+	oldSearcher, newSearcher := &fakeSearcher{}, &fakeSearcher{}
+	r := flagsRouter(store, oldSearcher, newSearcher)
+
+	for _, enabled := range []bool{false, true, false} {
+		store.current.Store(&FeatureFlags{"new_search": enabled})
+		*oldSearcher, *newSearcher = fakeSearcher{}, fakeSearcher{}
+
+		if w := get(r, "/search?q=go"); w.Code != http.StatusOK {
+			t.Fatalf("new_search=%t: status %d: %s", enabled, w.Code, w.Body)
+		}
+		used, unused := oldSearcher, newSearcher
+		if enabled {
+			used, unused = newSearcher, oldSearcher
+		}
+		if used.query != "go" || unused.query != "" {
+			t.Errorf("new_search=%t: searched the wrong backend (old %q, new %q)", enabled, oldSearcher.query, newSearcher.query)
+		}
+	}
+}
+
+func TestFlagsHandlerListsCurrentFlags(t *testing.T) {
+	store := &FlagStore{}
+	r := flagsRouter(store, &fakeSearcher{}, &fakeSearcher{})
+
+	for _, want := range []FeatureFlags{{"new_search": true, "beta": false}, {}} {
+		store.current.Store(&want)
+
+		w := get(r, "/flags")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		var got FeatureFlags
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) || got.Enabled("new_search") != want.Enabled("new_search") {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestFlagStoreParsesConfiguredFlags(t *testing.T) {
+	prev := CurrentConfig()
+	t.Cleanup(func() { SetConfig(prev) })
+
+	cfg := &Config{FeatureFlags: `{"new_search":true}`}
+	SetConfig(cfg)
+	store, err := NewFlagStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags := store.Load()
+	if !flags.Enabled("new_search") || flags.Enabled("unknown") {
+		t.Fatalf("got %v, want only new_search on", flags)
+	}
+
+	cfg.FeatureFlags = `{"new_search":`
+	if err := store.Reload(); err == nil {
+		t.Fatal("invalid JSON reloaded without error")
+	}
+	if !store.Load().Enabled("new_search") {
+		t.Fatal("failed reload replaced the previous flags")
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oschwald/geoip2-golang"
+)
+
+// GeoIPResponse is the location an IP address resolves to
+type GeoIPResponse struct {
+	Country string `json:"country"`
+	City    string `json:"city"`
+}
+
+// openGeoIP opens the GeoLite2 City database at path. It returns nil without
+// an error when the file doesn't exist, so the lookup route can be skipped.
+func openGeoIP(path string) (*geoip2.Reader, error) {
+	if _, err := os.Stat(path); err != nil {
+		slog.Info("GeoIP database not found, /geoip disabled", "path", path)
+		return nil, nil
+	}
+
+	db, err := geoip2.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open GEOIP_DB_PATH: %w", err)
+	}
+	slog.Info("Loaded GeoIP database", "path", path, "type", db.Metadata().DatabaseType)
+	return db, nil
+}
+
+// geoIPHandler resolves the ip path parameter to a country and city
+//
+//	@Summary	Look up an IP address's location
+//	@Tags		geoip
+//	@Produce	json
+//	@Param		ip	path		string	true	"IPv4 or IPv6 address"
+//	@Success	200	{object}	GeoIPResponse
+//	@Failure	400	{object}	APIError
+//	@Failure	404	{object}	APIError
+//	@Router		/v1/geoip/{ip} [get]
+func geoIPHandler(db *geoip2.Reader) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := net.ParseIP(c.Param("ip"))
+		if ip == nil {
+			RespondError(c, http.StatusBadRequest, CodeInvalidRequest, "ip must be a valid IPv4 or IPv6 address")
+			return
+		}
+
+		if ip.To4() == nil && db.Metadata().IPVersion == 4 {
+			RespondError(c, http.StatusBadRequest, CodeInvalidRequest, "The GeoIP database only covers IPv4 addresses")
+			return
+		}
+
+		record, err := db.City(ip)
+		if err != nil {
+			RespondError(c, http.StatusInternalServerError, CodeInternal, "GeoIP lookup failed")
+			return
+		}
+
+		response := GeoIPResponse{
+			Country: record.Country.Names["en"],
+			City:    record.City.Names["en"],
+		}
+		if response.Country == "" && response.City == "" {
+			RespondError(c, http.StatusNotFound, CodeNotFound, "No location found for ip")
+			return
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
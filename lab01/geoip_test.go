@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oschwald/geoip2-golang"
+)
+
+// mmdbValue appends v in the MaxMind DB data section encoding. Only the
+// types the fixture needs are supported: maps, strings, uints and arrays.
+func mmdbValue(buf *bytes.Buffer, v any) {
+	// control writes a type and size byte; types above 7 are extended
+	control := func(typ, size int) {
+		if typ > 7 {
+			buf.WriteByte(byte(size))
+			buf.WriteByte(byte(typ - 7))
+			return
+		}
+		buf.WriteByte(byte(typ<<5 | size))
+	}
+	unsigned := func(typ int, n uint64, width int) {
+		b := binary.BigEndian.AppendUint64(nil, n)
+		b = bytes.TrimLeft(b[8-width:], "\x00")
+		control(typ, len(b))
+		buf.Write(b)
+	}
+
+	switch v := v.(type) {
+	case string:
+		control(2, len(v))
+		buf.WriteString(v)
+	case uint16:
+		unsigned(5, uint64(v), 2)
+	case uint32:
+		unsigned(6, uint64(v), 4)
+	case uint64:
+		unsigned(9, v, 8)
+	case []string:
+		control(11, len(v))
+		for _, s := range v {
+			mmdbValue(buf, s)
+		}
+	case [][2]any:
+		control(7, len(v))
+		for _, kv := range v {
+			mmdbValue(buf, kv[0])
+			mmdbValue(buf, kv[1])
+		}
+	default:
+		panic("unsupported mmdb value")
+	}
+}
+
+// geoIPFixture builds an IPv4 GeoIP2-City database placing 81.0.0.0/8 in
+// London, United Kingdom, with nothing recorded for any other address
+func geoIPFixture(t *testing.T) []byte {
+	t.Helper()
+	const prefix, bits = 81, 8
+	const nodeCount = bits
+
+	var data bytes.Buffer
+	mmdbValue(&data, [][2]any{
+		{"city", [][2]any{{"names", [][2]any{{"en", "London"}}}}},
+		{"country", [][2]any{{"names", [][2]any{{"en", "United Kingdom"}}}}},
+	})
+
+	// One node per prefix bit; the branch off the prefix is empty, the end
+	// of it points at the record at the start of the data section
+	var db bytes.Buffer
+	record := func(n uint32) { db.Write([]byte{byte(n >> 16), byte(n >> 8), byte(n)}) }
+	for i := range bits {
+		next := uint32(i + 1)
+		if i == bits-1 {
+			next = nodeCount + 16
+		}
+		if prefix>>(bits-1-i)&1 == 0 {
+			record(next)
+			record(nodeCount)
+		} else {
+			record(nodeCount)
+			record(next)
+		}
+	}
+	db.Write(make([]byte, 16))
+	db.Write(data.Bytes())
+
+	db.WriteString("\xab\xcd\xefMaxMind.com")
+	mmdbValue(&db, [][2]any{
+		{"binary_format_major_version", uint16(2)},
+		{"binary_format_minor_version", uint16(0)},
+		{"build_epoch", uint64(1700000000)},
+		{"database_type", "GeoIP2-City"},
+		{"description", [][2]any{{"en", "lab01 test fixture"}}},
+		{"ip_version", uint16(4)},
+		{"languages", []string{"en"}},
+		{"node_count", uint32(nodeCount)},
+		{"record_size", uint16(24)},
+	})
+	return db.Bytes()
+}
+
+func TestGeoIPHandler(t *testing.T) {
+	db, err := geoip2.FromBytes(geoIPFixture(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.GET("/geoip/:ip", geoIPHandler(db))
+
+	w := get(r, "/geoip/81.2.69.160")
+	var resp GeoIPResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || resp.Country != "United Kingdom" || resp.City != "London" {
+		t.Fatalf("got %d %+v, want London, United Kingdom", w.Code, resp)
+	}
+
+	tests := []struct {
+		ip   string
+		want int
+	}{
+		{"203.0.113.5", http.StatusNotFound},
+		{"not-an-ip", http.StatusBadRequest},
+		{"999.1.1.1", http.StatusBadRequest},
+		{"2001:db8::1", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := get(r, "/geoip/"+tt.ip); w.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.ip, w.Code, tt.want)
+		}
+	}
+}
+
+func TestOpenGeoIP(t *testing.T) {
+	db, err := openGeoIP(filepath.Join(t.TempDir(), "missing.mmdb"))
+	if db != nil || err != nil {
+		t.Fatalf("missing database: got %v, %v; want the route disabled without an error", db, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "GeoIP2-City.mmdb")
+	if err := os.WriteFile(path, geoIPFixture(t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	db, err = openGeoIP(path)
+	if err != nil || db == nil {
+		t.Fatalf("got %v, %v", db, err)
+	}
+	db.Close()
+
+	corrupt := filepath.Join(t.TempDir(), "corrupt.mmdb")
+	if err := os.WriteFile(corrupt, []byte("not a database"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openGeoIP(corrupt); err == nil {
+		t.Fatal("corrupt database opened without an error")
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// graphQLSchema describes the queries served on /graphql
+//
+//go:embed schemas/api.graphql
+var graphQLSchema string
+
+// graphQLMaxDepth bounds query nesting so a single request can't fan out
+// without limit
+const graphQLMaxDepth = 10
+
+// graphQLRequest is the body of a POST /graphql request
+type graphQLRequest struct {
+	Query         string         `json:"query" binding:"required"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// graphQLHandler serves GraphQL queries over POST and the GraphiQL
+// playground over GET. Queries run on the same stores as the REST endpoints,
+// under the same body size and timeout limits.
+func graphQLHandler(users UserStore, searcher Searcher, maxLimit func() int) gin.HandlerFunc {
+	schema := graphql.MustParseSchema(graphQLSchema,
+		&graphQLResolver{users: users, searcher: searcher, maxLimit: maxLimit},
+		graphql.MaxDepth(graphQLMaxDepth))
+
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet {
+			c.Data(http.StatusOK, "text/html; charset=utf-8", graphiQLPage)
+			return
+		}
+
+		var req graphQLRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+
+		// Query errors are reported in the body with a 200, as GraphQL
+		// clients expect
+		c.JSON(http.StatusOK, schema.Exec(c.Request.Context(), req.Query, req.OperationName, req.Variables))
+	}
+}
+
+// graphQLResolver resolves the root Query fields
+type graphQLResolver struct {
+	users    UserStore
+	searcher Searcher
+	maxLimit func() int
+}
+
+// graphQLLimit validates an optional page size and caps it at maxLimit, as
+// parsePagination does for REST
+func graphQLLimit(limit *int32, maxLimit int) (int, error) {
+	if limit == nil {
+		return min(defaultPageLimit, maxLimit), nil
+	}
+	if *limit < 1 {
+		return 0, errors.New("limit must be a positive integer")
+	}
+	return min(int(*limit), maxLimit), nil
+}
+
+func (r *graphQLResolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	id, err := strconv.ParseInt(string(args.ID), 10, 64)
+	if err != nil || id < 1 {
+		return nil, errors.New("id must be a positive integer")
+	}
+
+	user, err := r.users.Get(ctx, id)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt != nil {
+		return nil, nil
+	}
+	return &userResolver{user}, nil
+}
+
+type userFilterInput struct {
+	Name           *string
+	IncludeDeleted *bool
+}
+
+type paginationInput struct {
+	Limit  *int32
+	Offset *int32
+}
+
+func (r *graphQLResolver) Users(ctx context.Context, args struct {
+	Filter     *userFilterInput
+	Pagination *paginationInput
+}) (*userPageResolver, error) {
+	opts := UserListOptions{SortBy: "created_at"}
+	if f := args.Filter; f != nil {
+		if f.Name != nil {
+			opts.Name = *f.Name
+		}
+		if f.IncludeDeleted != nil {
+			opts.IncludeDeleted = *f.IncludeDeleted
+		}
+	}
+
+	var pagination paginationInput
+	if args.Pagination != nil {
+		pagination = *args.Pagination
+	}
+	limit, err := graphQLLimit(pagination.Limit, r.maxLimit())
+	if err != nil {
+		return nil, err
+	}
+	opts.Limit = limit
+	if pagination.Offset != nil {
+		if *pagination.Offset < 0 {
+			return nil, errors.New("offset must not be negative")
+		}
+		opts.Offset = int(*pagination.Offset)
+	}
+
+	users, total, err := r.users.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	page := &userPageResolver{total: int32(total)}
+	for _, user := range users {
+		page.users = append(page.users, &userResolver{user})
+	}
+	return page, nil
+}
+
+func (r *graphQLResolver) Search(ctx context.Context, args struct {
+	Q     string
+	Limit *int32
+}) (*searchPageResolver, error) {
+	if args.Q == "" {
+		return nil, errors.New("q must not be empty")
+	}
+	limit, err := graphQLLimit(args.Limit, r.maxLimit())
+	if err != nil {
+		return nil, err
+	}
+
+	results, total, err := r.searcher.Search(ctx, args.Q, limit, 0)
+	if err != nil {
+		return nil, err
+	}
+	page := &searchPageResolver{query: args.Q, total: int32(total)}
+	for _, result := range results {
+		page.results = append(page.results, &searchResultResolver{result})
+	}
+	return page, nil
+}
+
+type userResolver struct{ u User }
+
+func (r *userResolver) ID() graphql.ID    { return graphql.ID(strconv.FormatInt(r.u.ID, 10)) }
+func (r *userResolver) Username() string  { return r.u.Username }
+func (r *userResolver) Name() string      { return r.u.Name }
+func (r *userResolver) Email() string     { return r.u.Email }
+func (r *userResolver) Version() int32    { return int32(r.u.Version) }
+func (r *userResolver) CreatedAt() string { return r.u.CreatedAt.UTC().Format(time.RFC3339Nano) }
+func (r *userResolver) UpdatedAt() string { return r.u.UpdatedAt.UTC().Format(time.RFC3339Nano) }
+func (r *userResolver) DeletedAt() *string {
+	if r.u.DeletedAt == nil {
+		return nil
+	}
+	deletedAt := r.u.DeletedAt.UTC().Format(time.RFC3339Nano)
+	return &deletedAt
+}
+
+type userPageResolver struct {
+	total int32
+	users []*userResolver
+}
+
+func (r *userPageResolver) Total() int32           { return r.total }
+func (r *userPageResolver) Users() []*userResolver { return r.users }
+
+type searchPageResolver struct {
+	query   string
+	total   int32
+	results []*searchResultResolver
+}
+
+func (r *searchPageResolver) Query() string                    { return r.query }
+func (r *searchPageResolver) Total() int32                     { return r.total }
+func (r *searchPageResolver) Results() []*searchResultResolver { return r.results }
+
+type searchResultResolver struct{ r Result }
+
+func (r *searchResultResolver) ID() graphql.ID  { return graphql.ID(strconv.FormatInt(r.r.ID, 10)) }
+func (r *searchResultResolver) Title() string   { return r.r.Title }
+func (r *searchResultResolver) Snippet() string { return r.r.Snippet }
+
+// graphiQLPage is the in-browser query editor served on GET /graphql
+var graphiQLPage = []byte(`<!DOCTYPE html>
+<html>
+<head>
+  <title>GraphiQL</title>
+  <link rel="stylesheet" href="https://unpkg.com/graphiql@3/graphiql.min.css" />
+</head>
+<body style="margin: 0">
+  <div id="graphiql" style="height: 100vh"></div>
+  <script crossorigin src="https://unpkg.com/react@18/umd/react.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/react-dom@18/umd/react-dom.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/graphiql@3/graphiql.min.js"></script>
+  <script>
+    const fetcher = GraphiQL.createFetcher({ url: window.location.pathname });
+    ReactDOM.createRoot(document.getElementById("graphiql")).render(React.createElement(GraphiQL, { fetcher }));
+  </script>
+</body>
+</html>
+`)
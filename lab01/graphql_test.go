@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// graphQLRouter serves /graphql over store and searcher with a max page
+// size of 50
+func graphQLRouter(store UserStore, searcher Searcher) *gin.Engine {
+	r := gin.New()
+	handler := graphQLHandler(store, searcher, func() int { return 50 })
+	r.GET("/graphql", handler)
+	r.POST("/graphql", handler)
+	return r
+}
+
+// graphQLResponse is the decoded body of a /graphql response
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// postQuery sends query to /graphql and decodes the response
+func postQuery(t *testing.T, h http.Handler, query string) graphQLResponse {
+	t.Helper()
+	body, err := json.Marshal(graphQLRequest{Query: query})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := post(h, "/graphql", string(body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp graphQLResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestGraphQLUserByID(t *testing.T) {
+	store := NewMemoryUserStore()
+	users := seedUsers(t, store, "ann", "bob")
+	r := graphQLRouter(store, &fakeSearcher{})
+
+	resp := postQuery(t, r, fmt.Sprintf(`{ user(id: "%d") { id username name email version deletedAt } }`, users[1].ID))
+	if len(resp.Errors) != 0 {
+		t.Fatalf("errors: %+v", resp.Errors)
+	}
+	want := fmt.Sprintf(`{"user":{"id":"%d","username":"ubob","name":"bob","email":"bob@example.com","version":%d,"deletedAt":null}}`,
+		users[1].ID, users[1].Version)
+	if string(resp.Data) != want {
+		t.Fatalf("got %s, want %s", resp.Data, want)
+	}
+}
+
+func TestGraphQLUserMissingOrDeletedIsNull(t *testing.T) {
+	store := NewMemoryUserStore()
+	users := seedUsers(t, store, "ann")
+	if err := store.Delete(context.Background(), users[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	r := graphQLRouter(store, &fakeSearcher{})
+
+	for _, id := range []string{fmt.Sprint(users[0].ID), "999"} {
+		resp := postQuery(t, r, fmt.Sprintf(`{ user(id: "%s") { id } }`, id))
+		if len(resp.Errors) != 0 || string(resp.Data) != `{"user":null}` {
+			t.Errorf("id %s: got %s %+v, want a null user", id, resp.Data, resp.Errors)
+		}
+	}
+
+	resp := postQuery(t, r, `{ user(id: "abc") { id } }`)
+	if len(resp.Errors) == 0 {
+		t.Fatalf("non-numeric id: got %s, want an error", resp.Data)
+	}
+}
+
+func TestGraphQLUsersAndSearchReuseStores(t *testing.T) {
+	store := NewMemoryUserStore()
+	seedUsers(t, store, "ann", "bob", "cyd")
+	searcher := &fakeSearcher{results: []Result{{ID: 7, Title: "Go", Snippet: "gophers"}}, total: 1}
+	r := graphQLRouter(store, searcher)
+
+	resp := postQuery(t, r, `{ users(pagination: {limit: 2, offset: 1}) { total users { username } } }`)
+	if want := `{"users":{"total":3,"users":[{"username":"ubob"},{"username":"ucyd"}]}}`; string(resp.Data) != want {
+		t.Fatalf("users: got %s %+v, want %s", resp.Data, resp.Errors, want)
+	}
+
+	resp = postQuery(t, r, `{ search(q: "go", limit: 500) { query total results { id title snippet } } }`)
+	if want := `{"search":{"query":"go","total":1,"results":[{"id":"7","title":"Go","snippet":"gophers"}]}}`; string(resp.Data) != want {
+		t.Fatalf("search: got %s %+v, want %s", resp.Data, resp.Errors, want)
+	}
+	if searcher.query != "go" || searcher.limit != 50 {
+		t.Fatalf("searcher got %q limit %d, want the limit capped at 50", searcher.query, searcher.limit)
+	}
+}
+
+func TestGraphQLServesGraphiQLOnGet(t *testing.T) {
+	w := get(graphQLRouter(NewMemoryUserStore(), &fakeSearcher{}), "/graphql")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(w.Body.String(), "GraphiQL") {
+		t.Fatalf("got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestGraphQLRejectsMissingQuery(t *testing.T) {
+	if w := post(graphQLRouter(NewMemoryUserStore(), &fakeSearcher{}), "/graphql", `{}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("got %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"lab01/labpb"
+)
+
+// labGRPCServer implements the Lab gRPC service on the same user store and
+// searcher as the REST API
+type labGRPCServer struct {
+	labpb.UnimplementedLabServer
+	users    UserStore
+	searcher Searcher
+	maxLimit func() int
+}
+
+// newGRPCServer returns a gRPC server with the Lab service registered
+func newGRPCServer(users UserStore, searcher Searcher, maxLimit func() int) *grpc.Server {
+	server := grpc.NewServer()
+	labpb.RegisterLabServer(server, &labGRPCServer{users: users, searcher: searcher, maxLimit: maxLimit})
+	return server
+}
+
+func (s *labGRPCServer) Ping(context.Context, *labpb.PingRequest) (*labpb.PingResponse, error) {
+	return &labpb.PingResponse{Message: "pong", Status: "healthy"}, nil
+}
+
+func (s *labGRPCServer) GetUser(ctx context.Context, req *labpb.GetUserRequest) (*labpb.User, error) {
+	if req.GetId() < 1 {
+		return nil, status.Error(codes.InvalidArgument, "id must be a positive integer")
+	}
+
+	user, err := s.users.Get(ctx, req.GetId())
+	if errors.Is(err, ErrUserNotFound) || (err == nil && user.DeletedAt != nil) {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &labpb.User{
+		Id:        user.ID,
+		Username:  user.Username,
+		Name:      user.Name,
+		Email:     user.Email,
+		Version:   user.Version,
+		CreatedAt: timestamppb.New(user.CreatedAt),
+		UpdatedAt: timestamppb.New(user.UpdatedAt),
+	}, nil
+}
+
+func (s *labGRPCServer) Search(ctx context.Context, req *labpb.SearchRequest) (*labpb.SearchResponse, error) {
+	if req.GetQuery() == "" {
+		return nil, status.Error(codes.InvalidArgument, "query is required")
+	}
+	if req.GetLimit() < 0 || req.GetOffset() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit and offset must not be negative")
+	}
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+	limit = min(limit, s.maxLimit())
+
+	results, total, err := s.searcher.Search(ctx, req.GetQuery(), limit, int(req.GetOffset()))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	response := &labpb.SearchResponse{Query: req.GetQuery(), Total: int32(total)}
+	for _, result := range results {
+		response.Results = append(response.Results, &labpb.Result{Id: result.ID, Title: result.Title, Snippet: result.Snippet})
+	}
+	return response, nil
+}
+
+// grpcError maps a store or searcher error to a gRPC status, keeping
+// cancellations and deadlines distinguishable from internal failures
+func grpcError(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	if errors.Is(err, ErrSearchUnavailable) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"lab01/labpb"
+)
+
+// grpcClient serves the Lab service over an in-process bufconn listener and
+// returns a client connected to it
+func grpcClient(t *testing.T, users UserStore, searcher Searcher) labpb.LabClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := newGRPCServer(users, searcher, func() int { return 50 })
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return labpb.NewLabClient(conn)
+}
+
+func TestGRPCPing(t *testing.T) {
+	client := grpcClient(t, NewMemoryUserStore(), &fakeSearcher{})
+	resp, err := client.Ping(context.Background(), &labpb.PingRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetMessage() != "pong" || resp.GetStatus() != "healthy" {
+		t.Fatalf("got %v", resp)
+	}
+}
+
+func TestGRPCGetUser(t *testing.T) {
+	store := NewMemoryUserStore()
+	users := seedUsers(t, store, "ann", "bob")
+	if err := store.Delete(context.Background(), users[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	client := grpcClient(t, store, &fakeSearcher{})
+
+	user, err := client.GetUser(context.Background(), &labpb.GetUserRequest{Id: users[0].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.GetUsername() != "uann" || user.GetEmail() != "ann@example.com" || !user.GetCreatedAt().AsTime().Equal(users[0].CreatedAt) {
+		t.Fatalf("got %v", user)
+	}
+
+	tests := []struct {
+		name string
+		id   int64
+		want codes.Code
+	}{
+		{"deleted", users[1].ID, codes.NotFound},
+		{"missing", 999, codes.NotFound},
+		{"zero", 0, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		_, err := client.GetUser(context.Background(), &labpb.GetUserRequest{Id: tt.id})
+		if got := status.Code(err); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGRPCSearch(t *testing.T) {
+	searcher := &fakeSearcher{results: []Result{{ID: 7, Title: "Go", Snippet: "gophers"}}, total: 1}
+	client := grpcClient(t, NewMemoryUserStore(), searcher)
+
+	resp, err := client.Search(context.Background(), &labpb.SearchRequest{Query: "go", Limit: 500, Offset: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetTotal() != 1 || len(resp.GetResults()) != 1 || resp.GetResults()[0].GetTitle() != "Go" {
+		t.Fatalf("got %v", resp)
+	}
+	if searcher.limit != 50 || searcher.offset != 10 {
+		t.Fatalf("searcher got limit %d offset %d, want the limit capped at 50", searcher.limit, searcher.offset)
+	}
+
+	if _, err := client.Search(context.Background(), &labpb.SearchRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("empty query: got %v", err)
+	}
+	searcher.err = ErrSearchUnavailable
+	if _, err := client.Search(context.Background(), &labpb.SearchRequest{Query: "go"}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("search backend down: got %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"compress/gzip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultGzipThreshold is the minimum body size worth compressing
+const defaultGzipThreshold = 1024
+
+// incompressibleTypes are content type prefixes that are already compressed
+// or are streamed and must not be buffered
+var incompressibleTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/pdf",
+	"text/event-stream",
+}
+
+// Gzip returns a middleware that compresses response bodies of at least 1KB
+func Gzip() gin.HandlerFunc {
+	return GzipWithThreshold(defaultGzipThreshold)
+}
+
+// GzipWithThreshold returns a gzip middleware that leaves bodies smaller than
+// threshold bytes uncompressed
+func GzipWithThreshold(threshold int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+
+		if !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		writer := &gzipWriter{ResponseWriter: c.Writer, threshold: threshold}
+		c.Writer = writer
+		defer writer.finish()
+
+		c.Next()
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		encoding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(encoding) != "gzip" {
+			continue
+		}
+		return strings.ReplaceAll(params, " ", "") != "q=0"
+	}
+	return false
+}
+
+// gzipWriter buffers the start of a response until it knows whether the body
+// is large enough to compress, then streams the rest
+type gzipWriter struct {
+	gin.ResponseWriter
+	threshold int
+	buf       []byte
+	decided   bool
+	gz        *gzip.Writer
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, data...)
+		if len(w.buf) >= w.threshold {
+			if err := w.decide(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(data), nil
+	}
+
+	if w.gz != nil {
+		return w.gz.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush sends buffered data immediately, compressing only if the threshold
+// was already reached
+func (w *gzipWriter) Flush() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide picks compressed or plain output and writes out the buffered bytes
+func (w *gzipWriter) decide(largeEnough bool) error {
+	w.decided = true
+
+	header := w.Header()
+	if largeEnough && header.Get("Content-Encoding") == "" && compressible(header.Get("Content-Type")) {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", "gzip")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// finish writes any small buffered body and closes the gzip stream
+func (w *gzipWriter) finish() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
+
+// compressible reports whether a content type benefits from gzip
+func compressible(contentType string) bool {
+	for _, prefix := range incompressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+	return true
+}
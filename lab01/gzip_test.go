@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gzipRouter serves /body, which writes body with contentType, behind the
+// gzip middleware
+func gzipRouter(threshold int, contentType string, body []byte) *gin.Engine {
+	r := gin.New()
+	r.Use(GzipWithThreshold(threshold))
+	r.GET("/body", func(c *gin.Context) {
+		c.Data(http.StatusOK, contentType, body)
+	})
+	return r
+}
+
+// getWithEncoding sends a GET to target on h with Accept-Encoding set
+func getWithEncoding(h http.Handler, target, encoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if encoding != "" {
+		req.Header.Set("Accept-Encoding", encoding)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// gunzip decompresses body, failing the test if it isn't valid gzip
+func gunzip(t *testing.T, body []byte) []byte {
+	t.Helper()
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plain
+}
+
+func TestGzipCompressesLargeBodies(t *testing.T) {
+	body := []byte(strings.Repeat(`{"title":"Getting started with Go"},`, 100))
+	r := gzipRouter(defaultGzipThreshold, "application/json", body)
+
+	w := getWithEncoding(r, "/body", "deflate, gzip;q=0.8")
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding is %q", got)
+	}
+	if w.Body.Len() >= len(body) {
+		t.Fatalf("compressed body is %d bytes, plain is %d", w.Body.Len(), len(body))
+	}
+	if plain := gunzip(t, w.Body.Bytes()); !bytes.Equal(plain, body) {
+		t.Fatal("decompressed body differs from the uncompressed one")
+	}
+	if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+		t.Fatalf("Vary is %q", got)
+	}
+}
+
+func TestGzipLeavesResponsesUncompressed(t *testing.T) {
+	large := []byte(strings.Repeat("a", 2*defaultGzipThreshold))
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		encoding    string
+	}{
+		{"not accepted", "application/json", large, ""},
+		{"other encoding", "application/json", large, "br, deflate"},
+		{"refused with q=0", "application/json", large, "gzip;q=0"},
+		{"under the threshold", "application/json", []byte(`{"ok":true}`), "gzip"},
+		{"already compressed", "image/png", large, "gzip"},
+		{"event stream", "text/event-stream", large, "gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := getWithEncoding(gzipRouter(defaultGzipThreshold, tt.contentType, tt.body), "/body", tt.encoding)
+			if got := w.Header().Get("Content-Encoding"); got != "" {
+				t.Fatalf("Content-Encoding is %q", got)
+			}
+			if !bytes.Equal(w.Body.Bytes(), tt.body) {
+				t.Fatal("body was changed")
+			}
+		})
+	}
+}
+
+func TestGzipThresholdIsConfigurable(t *testing.T) {
+	body := []byte(strings.Repeat("ab", 50))
+	if w := getWithEncoding(gzipRouter(100, "text/plain", body), "/body", "gzip"); w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("body at the threshold wasn't compressed")
+	}
+	if w := getWithEncoding(gzipRouter(101, "text/plain", body), "/body", "gzip"); w.Header().Get("Content-Encoding") != "" {
+		t.Fatal("body under the threshold was compressed")
+	}
+}
+
+func TestGzipFlushBeforeThresholdStaysPlain(t *testing.T) {
+	r := gin.New()
+	r.Use(Gzip())
+	r.GET("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Writer.WriteString("{\"id\":1}\n")
+		c.Writer.Flush()
+		c.Writer.WriteString(strings.Repeat("{\"id\":2}\n", 200))
+	})
+
+	w := getWithEncoding(r, "/stream", "gzip")
+	if got := w.Header().Get("Content-Encoding"); got != "" {
+		t.Fatalf("Content-Encoding is %q after an early flush", got)
+	}
+	if !strings.HasPrefix(w.Body.String(), "{\"id\":1}\n{\"id\":2}\n") {
+		t.Fatalf("body starts %q", w.Body.String()[:20])
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := map[string]bool{
+		"gzip":                 true,
+		"gzip, deflate, br":    true,
+		"br;q=1.0, gzip;q=0.5": true,
+		"gzip; q=0":            false,
+		"deflate":              false,
+		"":                     false,
+		"x-gzip":               false,
+	}
+	for header, want := range tests {
+		if got := acceptsGzip(header); got != want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
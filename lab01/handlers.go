@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchResponse represents the response structure for the search endpoint
+type SearchResponse struct {
+	Query string `json:"query"`
+	Pagination
+	Total      int      `json:"total"`
+	Results    []Result `json:"results"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+// respondNegotiated renders data as JSON or XML depending on the Accept
+// header, defaulting to JSON when it's missing or */*
+func respondNegotiated(c *gin.Context, status int, data any) {
+	c.Negotiate(status, gin.Negotiate{
+		Offered: []string{gin.MIMEJSON, gin.MIMEXML},
+		Data:    data,
+	})
+}
+
+// pingHandler is the basic health check endpoint
+//
+//	@Summary	Ping the service
+//	@Tags		health
+//	@Produce	json,xml
+//	@Success	200	{object}	PingResponse
+//	@Router		/ping [get]
+//	@Router		/ping [head]
+func pingHandler(c *gin.Context) {
+	respondNegotiated(c, http.StatusOK, PingResponse{
+		Message: "pong",
+		Status:  "healthy",
+	})
+}
+
+// healthHandler is the enhanced health check endpoint
+//
+//	@Summary	Service health
+//	@Tags		health
+//	@Produce	json,xml
+//	@Param		tz	query		string	false	"IANA time zone for timestamps, e.g. America/New_York"	default(UTC)
+//	@Success	200	{object}	HealthResponse
+//	@Router		/health [get]
+//	@Router		/health [head]
+func healthHandler(c *gin.Context) {
+	respondNegotiated(c, http.StatusOK, HealthResponse{
+		Service:       "Go API with Gin",
+		Status:        "running",
+		Version:       version,
+		UptimeSeconds: int64(time.Since(startTime).Seconds()),
+		StartedAt:     startTime.In(LocationFromContext(c)).Format(time.RFC3339),
+	})
+}
+
+// searchHandler demonstrates query parameters, returning at most maxLimit()
+// results per page from searcher, or from termSearcher when the new_search
+// feature flag is enabled
+//
+//	@Summary	Search
+//	@Tags		search
+//	@Produce	json,text/csv
+//	@Param		q		query		string	true	"Search query"
+//	@Param		format	query		string	false	"Response format"											Enums(json, csv)	default(json)
+//	@Param		limit	query		int		false	"Maximum number of results (capped at SEARCH_MAX_LIMIT)"	default(10)			minimum(1)
+//	@Param		page	query		int		false	"Page number"												default(1)			minimum(1)
+//	@Param		cursor	query		string	false	"Opaque next_cursor from a previous response; takes precedence over page"
+//	@Success	200		{object}	SearchResponse
+//	@Header		200		{string}	X-Cache	"HIT or MISS when the search cache is enabled"
+//	@Header		200		{string}	Warning	"Set when page is ignored in favour of cursor"
+//	@Failure	400		{object}	APIError
+//	@Failure	500		{object}	APIError
+//	@Failure	503		{object}	APIError
+//	@Router		/v1/search [get]
+func searchHandler(searcher, termSearcher Searcher, maxLimit func() int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		backend := searcher
+		if FlagsFromContext(c).Enabled("new_search") {
+			backend = termSearcher
+		}
+
+		// Get query parameters
+		query := c.Query("q") // Required query parameter
+
+		// Validate required parameter
+		if query == "" {
+			RespondError(c, http.StatusBadRequest, CodeInvalidQuery, "Query parameter 'q' is required")
+			return
+		}
+
+		format := c.DefaultQuery("format", "json")
+		if format != "json" && format != "csv" {
+			RespondError(c, http.StatusBadRequest, CodeInvalidQuery, "Query parameter 'format' must be json or csv")
+			return
+		}
+
+		// Optional limit and page, validated and converted to an offset
+		pagination, err := parsePagination(c, maxLimit())
+		if err != nil {
+			RespondError(c, http.StatusBadRequest, CodeInvalidQuery, err.Error())
+			return
+		}
+
+		// A cursor resumes after the last result of the previous page and
+		// replaces page/offset entirely
+		cursor := c.Query("cursor")
+		var afterID int64
+		if cursor != "" {
+			if afterID, err = decodeCursor(cursor); err != nil {
+				RespondError(c, http.StatusBadRequest, CodeInvalidQuery, err.Error())
+				return
+			}
+			if _, ok := c.GetQuery("page"); ok {
+				c.Header("Warning", `299 - "page is ignored when cursor is supplied"`)
+			}
+			pagination = Pagination{Limit: pagination.Limit}
+		}
+
+		ctx, cacheStatus := withCacheStatus(c.Request.Context())
+		var results []Result
+		var total int
+		if cursor != "" {
+			results, total, err = backend.SearchAfter(ctx, query, pagination.Limit, afterID)
+		} else {
+			results, total, err = backend.Search(ctx, query, pagination.Limit, pagination.Offset)
+		}
+		if *cacheStatus != "" {
+			c.Header("X-Cache", *cacheStatus)
+		}
+		if err != nil {
+			respondSearchError(c, err)
+			return
+		}
+
+		if format == "csv" {
+			// Headers are already sent, so a write error can only be logged
+			if err := writeResultsCSV(c, results); err != nil {
+				slog.Warn("CSV export interrupted", "error", err)
+			}
+			return
+		}
+
+		// A full page may be followed by more results
+		var nextCursor string
+		if len(results) == pagination.Limit {
+			nextCursor = encodeCursor(results[len(results)-1].ID)
+		}
+
+		RespondList(c, http.StatusOK, SearchResponse{
+			Query:      query,
+			Pagination: pagination,
+			Total:      total,
+			Results:    results,
+			NextCursor: nextCursor,
+		}, results, ListMeta{Query: query, Pagination: pagination, Total: total, NextCursor: nextCursor})
+	}
+}
+
+// statusClientClosedRequest is the non-standard status (popularised by nginx)
+// recorded when the client disconnects before the response is ready
+const statusClientClosedRequest = 499
+
+// respondSearchError maps a Searcher error to an HTTP response. Searches
+// abandoned by the client get a 499 that nobody will read, but which shows
+// up in logs and metrics as a cancellation rather than a server error.
+func respondSearchError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		slog.Info("Search canceled by client", "request_id", RequestIDFromContext(c))
+		c.AbortWithStatus(statusClientClosedRequest)
+	case errors.Is(err, context.DeadlineExceeded):
+		RespondError(c, http.StatusServiceUnavailable, CodeTimeout, "request timed out")
+	case errors.Is(err, ErrSearchUnavailable):
+		RespondError(c, http.StatusServiceUnavailable, CodeUnavailable, "search unavailable")
+	default:
+		RespondError(c, http.StatusInternalServerError, CodeInternal, "Search failed")
+	}
+}
+
+// postSortFields are the orders posts can be listed in
+var postSortFields = []string{"date", "popularity", "title"}
+
+// getUserPostsHandler combines both path and query parameters. sort must be
+// one of postSortFields and category "all" or one of categories; the allowed
+// values are listed in the 400 details otherwise.
+//
+//	@Summary	List a user's posts
+//	@Tags		users
+//	@Produce	json
+//	@Param		id			path		int		true	"User ID"
+//	@Param		category	query		string	false	"Post category"	default(all)
+//	@Param		sort		query		string	false	"Sort order"	Enums(date, popularity, title)	default(date)
+//	@Success	200			{object}	map[string]any
+//	@Failure	400			{object}	APIError
+//	@Router		/v1/user/{id}/posts [get]
+func getUserPostsHandler(categories []string) gin.HandlerFunc {
+	allowedCategories := append([]string{"all"}, categories...)
+
+	return func(c *gin.Context) {
+		userID, ok := parseIDParam(c, "id")
+		if !ok {
+			return
+		}
+
+		category := c.DefaultQuery("category", "all")
+		if !slices.Contains(allowedCategories, category) {
+			RespondErrorWithDetails(c, http.StatusBadRequest, CodeInvalidQuery, "invalid category",
+				map[string]any{"allowed": allowedCategories})
+			return
+		}
+
+		sort := c.DefaultQuery("sort", "date")
+		if !slices.Contains(postSortFields, sort) {
+			RespondErrorWithDetails(c, http.StatusBadRequest, CodeInvalidQuery, "invalid sort field",
+				map[string]any{"allowed": postSortFields})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"user_id":  userID,
+			"category": category,
+			"sort":     sort,
+			"posts":    []string{}, // Placeholder for actual posts
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetUserPostsValidatesQuery(t *testing.T) {
+	r := gin.New()
+	r.GET("/user/:id/posts", getUserPostsHandler([]string{"tech", "travel"}))
+
+	tests := []struct {
+		target       string
+		want         int
+		wantCategory string
+		wantSort     string
+		wantAllowed  []string
+	}{
+		{"/user/7/posts", http.StatusOK, "all", "date", nil},
+		{"/user/7/posts?sort=popularity&category=tech", http.StatusOK, "tech", "popularity", nil},
+		{"/user/7/posts?sort=title&category=all", http.StatusOK, "all", "title", nil},
+		{"/user/7/posts?sort=id", http.StatusBadRequest, "", "", postSortFields},
+		{"/user/7/posts?sort=DATE", http.StatusBadRequest, "", "", postSortFields},
+		{"/user/7/posts?category=food", http.StatusBadRequest, "", "", []string{"all", "tech", "travel"}},
+		{"/user/abc/posts", http.StatusBadRequest, "", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			w := get(r, tt.target)
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+
+			if tt.want == http.StatusOK {
+				var body struct {
+					UserID   int64  `json:"user_id"`
+					Category string `json:"category"`
+					Sort     string `json:"sort"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatal(err)
+				}
+				if body.UserID != 7 || body.Category != tt.wantCategory || body.Sort != tt.wantSort {
+					t.Fatalf("got %+v, want user 7, category %s, sort %s", body, tt.wantCategory, tt.wantSort)
+				}
+				return
+			}
+
+			if tt.wantAllowed != nil {
+				var body struct {
+					Details struct {
+						Allowed []string `json:"allowed"`
+					} `json:"details"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatal(err)
+				}
+				if !slices.Equal(body.Details.Allowed, tt.wantAllowed) {
+					t.Fatalf("allowed values are %v, want %v", body.Details.Allowed, tt.wantAllowed)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout bounds how long each readiness check may take
+const readinessTimeout = 2 * time.Second
+
+// ReadinessChecker reports whether a dependency is able to serve traffic
+type ReadinessChecker interface {
+	// Name identifies the dependency in readiness responses
+	Name() string
+	// Check returns an error when the dependency is unreachable
+	Check(ctx context.Context) error
+	// Critical reports whether the service is unusable while the check
+	// fails. Failing non-critical checks only mark the service degraded.
+	Critical() bool
+}
+
+// Readiness and per-check statuses
+const (
+	readinessReady    = "ready"
+	readinessDegraded = "degraded"
+	readinessNotReady = "not ready"
+	readinessStarting = "starting"
+
+	checkOK       = "ok"
+	checkDegraded = "degraded"
+	checkDown     = "down"
+)
+
+// ReadinessResponse represents the response structure for the readiness probe
+type ReadinessResponse struct {
+	Status string `json:"status" enums:"ready,degraded,not ready,starting"`
+	// Checks maps each dependency to ok, degraded (a failing non-critical
+	// check) or down (a failing critical one)
+	Checks map[string]string `json:"checks,omitempty"`
+	// Failing lists the critical checks that failed
+	Failing []string `json:"failing,omitempty"`
+}
+
+// livenessHandler reports that the process is up and serving requests
+func livenessHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, HealthResponse{
+		Service:       "Go API with Gin",
+		Status:        "alive",
+		Version:       version,
+		UptimeSeconds: int64(time.Since(startTime).Seconds()),
+		StartedAt:     startTime.In(LocationFromContext(c)).Format(time.RFC3339),
+	})
+}
+
+// startupBackoff spaces out dependency checks while the service starts
+var startupBackoff = Backoff{Base: 250 * time.Millisecond, Max: 5 * time.Second}
+
+// StartupGate keeps the service out of rotation until its critical
+// dependencies have been reached once after startup
+type StartupGate struct {
+	open atomic.Bool
+}
+
+// Open lets readiness be decided by the checks from now on
+func (g *StartupGate) Open() {
+	g.open.Store(true)
+}
+
+// Started reports whether the startup checks have passed
+func (g *StartupGate) Started() bool {
+	return g.open.Load()
+}
+
+// waitForDependencies retries each critical checker until it passes,
+// bounded by ctx's deadline, and returns the first one that never did.
+// Non-critical dependencies don't hold up startup.
+func waitForDependencies(ctx context.Context, checkers []ReadinessChecker) error {
+	for _, checker := range checkers {
+		if !checker.Critical() {
+			continue
+		}
+		// Attempts are only limited by ctx
+		err := startupBackoff.Retry(ctx, math.MaxInt, func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+			defer cancel()
+			return checker.Check(ctx)
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", checker.Name(), err)
+		}
+	}
+	return nil
+}
+
+// checkAll runs every checker at once, each bounded by readinessTimeout, so
+// a slow dependency can't use up the time of the others. It returns each
+// checker's error, in checkers order.
+func checkAll(ctx context.Context, checkers []ReadinessChecker) []error {
+	errs := make([]error, len(checkers))
+	var wg sync.WaitGroup
+	for i, checker := range checkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+			defer cancel()
+			errs[i] = checker.Check(ctx)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// readinessHandler runs every registered checker and reports each one's
+// status. It returns 503 if a critical check fails, and 200 with a degraded
+// status if only non-critical ones do. Until gate opens it returns 503 with
+// a starting status without running the checks.
+func readinessHandler(checkers []ReadinessChecker, gate *StartupGate) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !gate.Started() {
+			c.JSON(http.StatusServiceUnavailable, ReadinessResponse{Status: readinessStarting})
+			return
+		}
+
+		errs := checkAll(c.Request.Context(), checkers)
+
+		response := ReadinessResponse{Status: readinessReady, Checks: make(map[string]string, len(checkers))}
+		for i, checker := range checkers {
+			err := errs[i]
+			switch {
+			case err == nil:
+				response.Checks[checker.Name()] = checkOK
+			case checker.Critical():
+				response.Checks[checker.Name()] = checkDown
+				response.Failing = append(response.Failing, checker.Name())
+				response.Status = readinessNotReady
+			default:
+				response.Checks[checker.Name()] = checkDegraded
+				if response.Status == readinessReady {
+					response.Status = readinessDegraded
+				}
+			}
+		}
+
+		status := http.StatusOK
+		if response.Status == readinessNotReady {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, response)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeChecker is a ReadinessChecker that takes delay and then fails with
+// err, or hangs until its context is done when hang is set
+type fakeChecker struct {
+	name     string
+	critical bool
+	delay    time.Duration
+	hang     bool
+	err      error
+}
+
+func (f *fakeChecker) Name() string   { return f.name }
+func (f *fakeChecker) Critical() bool { return f.critical }
+
+func (f *fakeChecker) Check(ctx context.Context) error {
+	if f.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	select {
+	case <-time.After(f.delay):
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// readyRouter serves readinessHandler at /readyz, with the gate open
+func readyRouter(checkers ...ReadinessChecker) *gin.Engine {
+	gate := &StartupGate{}
+	gate.Open()
+	r := gin.New()
+	r.GET("/readyz", readinessHandler(checkers, gate))
+	return r
+}
+
+// readiness fetches /readyz from r and decodes the response
+func readiness(t *testing.T, r http.Handler) (int, ReadinessResponse) {
+	t.Helper()
+	w := get(r, "/readyz")
+	var resp ReadinessResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %q: %v", w.Body, err)
+	}
+	return w.Code, resp
+}
+
+func TestReadinessStatuses(t *testing.T) {
+	down := errors.New("unreachable")
+	tests := []struct {
+		name        string
+		checkers    []ReadinessChecker
+		wantCode    int
+		wantStatus  string
+		wantChecks  map[string]string
+		wantFailing []string
+	}{
+		{"no checks", nil, http.StatusOK, readinessReady, nil, nil},
+		{"all passing", []ReadinessChecker{&fakeChecker{name: "db", critical: true}, &fakeChecker{name: "geo"}},
+			http.StatusOK, readinessReady, map[string]string{"db": checkOK, "geo": checkOK}, nil},
+		{"non-critical failing", []ReadinessChecker{&fakeChecker{name: "db", critical: true}, &fakeChecker{name: "geo", err: down}},
+			http.StatusOK, readinessDegraded, map[string]string{"db": checkOK, "geo": checkDegraded}, nil},
+		{"critical failing", []ReadinessChecker{&fakeChecker{name: "db", critical: true, err: down}, &fakeChecker{name: "geo", err: down}},
+			http.StatusServiceUnavailable, readinessNotReady, map[string]string{"db": checkDown, "geo": checkDegraded}, []string{"db"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp := readiness(t, readyRouter(tt.checkers...))
+			if code != tt.wantCode || resp.Status != tt.wantStatus {
+				t.Fatalf("got %d %q, want %d %q", code, resp.Status, tt.wantCode, tt.wantStatus)
+			}
+			if !maps.Equal(resp.Checks, tt.wantChecks) || !slices.Equal(resp.Failing, tt.wantFailing) {
+				t.Fatalf("got checks %v failing %v, want %v and %v", resp.Checks, resp.Failing, tt.wantChecks, tt.wantFailing)
+			}
+		})
+	}
+}
+
+func TestReadinessSlowCheckDoesNotStarveOthers(t *testing.T) {
+	router := readyRouter(
+		&fakeChecker{name: "slow-upstream", hang: true},
+		&fakeChecker{name: "db", critical: true, delay: 50 * time.Millisecond},
+	)
+
+	start := time.Now()
+	code, resp := readiness(t, router)
+	elapsed := time.Since(start)
+
+	if code != http.StatusOK || resp.Checks["db"] != checkOK || resp.Checks["slow-upstream"] != checkDegraded {
+		t.Fatalf("got %d %+v; want 200 with db ok and slow-upstream degraded", code, resp)
+	}
+	// The checks ran side by side, not one after the other
+	if elapsed > readinessTimeout+time.Second {
+		t.Fatalf("took %s, want about %s", elapsed, readinessTimeout)
+	}
+}
+
+func TestProbesAnswerHEAD(t *testing.T) {
+	gate := &StartupGate{}
+	gate.Open()
+	r := gin.New()
+	RegisterRoutes(r, probeRoutes(nil, gate))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	for _, path := range []string{"/ping", "/health", "/healthz", "/readyz", "/version"} {
+		getResp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(getResp.Body)
+		getResp.Body.Close()
+
+		headResp, err := http.Head(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		headBody, _ := io.ReadAll(headResp.Body)
+		headResp.Body.Close()
+
+		if headResp.StatusCode != getResp.StatusCode || len(headBody) != 0 {
+			t.Errorf("HEAD %s: got %d with %d body bytes, GET got %d", path, headResp.StatusCode, len(headBody), getResp.StatusCode)
+		}
+		if got := headResp.Header.Get("Content-Type"); got != getResp.Header.Get("Content-Type") {
+			t.Errorf("HEAD %s: Content-Type %q, GET sent %q", path, got, getResp.Header.Get("Content-Type"))
+		}
+		// /health reports the uptime, which can change length between calls
+		if path != "/health" && headResp.ContentLength != int64(len(body)) {
+			t.Errorf("HEAD %s: Content-Length %d, GET body is %d bytes", path, headResp.ContentLength, len(body))
+		}
+	}
+}
+
+// recoveringChecker is a critical ReadinessChecker that fails until it has
+// been checked failures times
+type recoveringChecker struct {
+	failures int32
+	checks   atomic.Int32
+}
+
+func (r *recoveringChecker) Name() string   { return "db" }
+func (r *recoveringChecker) Critical() bool { return true }
+
+func (r *recoveringChecker) Check(context.Context) error {
+	if r.checks.Add(1) <= r.failures {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+// withStartupBackoff shortens the startup retry delay until the test ends
+func withStartupBackoff(t *testing.T) {
+	t.Helper()
+	prev := startupBackoff
+	startupBackoff = Backoff{Base: time.Millisecond, Max: 5 * time.Millisecond}
+	t.Cleanup(func() { startupBackoff = prev })
+}
+
+func TestReadinessWaitsForStartup(t *testing.T) {
+	withStartupBackoff(t)
+	db := &recoveringChecker{failures: 3}
+	checkers := []ReadinessChecker{db}
+	gate := &StartupGate{}
+	r := gin.New()
+	RegisterRoutes(r, probeRoutes(checkers, gate))
+
+	if code, resp := readiness(t, r); code != http.StatusServiceUnavailable || resp.Status != readinessStarting {
+		t.Fatalf("before startup: got %d %q, want 503 %q", code, resp.Status, readinessStarting)
+	}
+	if db.checks.Load() != 0 {
+		t.Fatal("readiness ran the checks before startup finished")
+	}
+	if w := get(r, "/healthz"); w.Code != http.StatusOK {
+		t.Fatalf("liveness during startup: got %d, want 200", w.Code)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := waitForDependencies(ctx, checkers); err != nil {
+		t.Fatal(err)
+	}
+	if got := db.checks.Load(); got != 4 {
+		t.Fatalf("checked %d times, want retries until the fourth check passed", got)
+	}
+	gate.Open()
+
+	if code, resp := readiness(t, r); code != http.StatusOK || resp.Status != readinessReady {
+		t.Fatalf("after startup: got %d %q, want 200 %q", code, resp.Status, readinessReady)
+	}
+}
+
+func TestWaitForDependenciesGivesUpAtDeadline(t *testing.T) {
+	withStartupBackoff(t)
+	down := errors.New("unreachable")
+	checkers := []ReadinessChecker{
+		&fakeChecker{name: "geo", err: down},
+		&fakeChecker{name: "db", critical: true, err: down},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := waitForDependencies(ctx, checkers)
+	if err == nil || !strings.HasPrefix(err.Error(), "db: ") {
+		t.Fatalf("got %v, want the critical db check reported", err)
+	}
+
+	// A failing non-critical dependency doesn't hold up startup
+	if err := waitForDependencies(context.Background(), checkers[:1]); err != nil {
+		t.Fatalf("non-critical failure: got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// httpCheckAttempts is how many times an upstream is tried before it's
+// reported as failing, so a single dropped connection doesn't flap readiness
+const httpCheckAttempts = 3
+
+// HTTPCheckConfig describes an upstream service whose health endpoint is
+// polled by the readiness probe
+type HTTPCheckConfig struct {
+	Name string
+	URL  string
+	// ExpectedStatus is the status code a healthy upstream answers with
+	ExpectedStatus int
+	Timeout        time.Duration
+	// Critical marks the service not ready, rather than degraded, while the
+	// upstream is failing
+	Critical bool
+}
+
+// HTTPChecker is a ReadinessChecker that GETs an upstream health endpoint
+type HTTPChecker struct {
+	cfg    HTTPCheckConfig
+	client *http.Client
+}
+
+// NewHTTPChecker returns a checker for the upstream described by cfg
+func NewHTTPChecker(cfg HTTPCheckConfig) *HTTPChecker {
+	return &HTTPChecker{cfg: cfg, client: &http.Client{}}
+}
+
+// Name identifies the upstream in readiness responses
+func (h *HTTPChecker) Name() string {
+	return h.cfg.Name
+}
+
+// Critical reports whether the upstream is required to serve traffic
+func (h *HTTPChecker) Critical() bool {
+	return h.cfg.Critical
+}
+
+// Check reports an error unless the upstream answers with the expected
+// status. Network errors, timeouts, 429 and 5xx responses are retried within
+// ctx, the probe's own deadline; any other unexpected status fails the check
+// at once.
+func (h *HTTPChecker) Check(ctx context.Context) error {
+	return Retry(ctx, httpCheckAttempts, h.check)
+}
+
+// check makes a single attempt, bounded by the configured timeout
+func (h *HTTPChecker) check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.cfg.URL, nil)
+	if err != nil {
+		return Permanent(err)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	// Drain a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+
+	if resp.StatusCode == h.cfg.ExpectedStatus {
+		return nil
+	}
+	err = fmt.Errorf("%s answered %d, want %d", h.cfg.Name, resp.StatusCode, h.cfg.ExpectedStatus)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return err
+	}
+	return Permanent(err)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flippingUpstream answers its health endpoint with status, counting hits
+type flippingUpstream struct {
+	status atomic.Int32
+	hits   atomic.Int32
+}
+
+func (u *flippingUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.hits.Add(1)
+	w.WriteHeader(int(u.status.Load()))
+}
+
+func TestHTTPCheckerReportsUpstreamHealth(t *testing.T) {
+	for _, critical := range []bool{false, true} {
+		upstream := &flippingUpstream{}
+		upstream.status.Store(http.StatusOK)
+		server := httptest.NewServer(upstream)
+		defer server.Close()
+
+		checker := NewHTTPChecker(HTTPCheckConfig{
+			Name:           "billing",
+			URL:            server.URL,
+			ExpectedStatus: http.StatusOK,
+			Timeout:        time.Second,
+			Critical:       critical,
+		})
+		router := readyRouter(checker)
+
+		code, resp := readiness(t, router)
+		if code != http.StatusOK || resp.Status != readinessReady || resp.Checks["billing"] != checkOK {
+			t.Fatalf("critical=%v, healthy upstream: got %d %+v", critical, code, resp)
+		}
+
+		upstream.status.Store(http.StatusInternalServerError)
+		code, resp = readiness(t, router)
+		wantCode, wantStatus, wantCheck := http.StatusOK, readinessDegraded, checkDegraded
+		if critical {
+			wantCode, wantStatus, wantCheck = http.StatusServiceUnavailable, readinessNotReady, checkDown
+		}
+		if code != wantCode || resp.Status != wantStatus || resp.Checks["billing"] != wantCheck {
+			t.Fatalf("critical=%v, failing upstream: got %d %+v, want %d %s", critical, code, resp, wantCode, wantStatus)
+		}
+
+		// The healthy probe hit it once, the failing one every attempt
+		if hits := upstream.hits.Load(); hits != 1+httpCheckAttempts {
+			t.Fatalf("critical=%v: upstream hit %d times over two probes, want %d", critical, hits, 1+httpCheckAttempts)
+		}
+	}
+}
+
+func TestHTTPCheckerExpectedStatus(t *testing.T) {
+	upstream := &flippingUpstream{}
+	upstream.status.Store(http.StatusNoContent)
+	server := httptest.NewServer(upstream)
+	defer server.Close()
+
+	check := func(expected int) error {
+		return NewHTTPChecker(HTTPCheckConfig{Name: "up", URL: server.URL, ExpectedStatus: expected, Timeout: time.Second}).Check(context.Background())
+	}
+	if err := check(http.StatusNoContent); err != nil {
+		t.Fatalf("expected 204: %v", err)
+	}
+	if err := check(http.StatusOK); err == nil {
+		t.Fatal("expected 200 but got 204: want an error")
+	}
+}
+
+func TestHTTPCheckerTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	checker := NewHTTPChecker(HTTPCheckConfig{Name: "slow", URL: server.URL, ExpectedStatus: http.StatusOK, Timeout: 50 * time.Millisecond})
+	start := time.Now()
+	if err := checker.Check(context.Background()); err == nil {
+		t.Fatal("want a timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("took %s, want about the 50ms timeout", elapsed)
+	}
+}
+
+func TestHTTPCheckerUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	checker := NewHTTPChecker(HTTPCheckConfig{Name: "gone", URL: url, ExpectedStatus: http.StatusOK, Timeout: time.Second})
+	if err := checker.Check(context.Background()); err == nil {
+		t.Fatal("want an error for an unreachable upstream")
+	}
+}
+
+func TestHTTPCheckerRetriesOnlyTransientStatuses(t *testing.T) {
+	tests := []struct {
+		status   int
+		wantHits int32
+	}{
+		{http.StatusServiceUnavailable, httpCheckAttempts},
+		{http.StatusTooManyRequests, httpCheckAttempts},
+		{http.StatusNotFound, 1},
+		{http.StatusUnauthorized, 1},
+		{http.StatusNoContent, 1},
+	}
+	for _, tt := range tests {
+		upstream := &flippingUpstream{}
+		upstream.status.Store(int32(tt.status))
+		server := httptest.NewServer(upstream)
+
+		checker := NewHTTPChecker(HTTPCheckConfig{Name: "up", URL: server.URL, ExpectedStatus: http.StatusOK, Timeout: time.Second})
+		if err := checker.Check(context.Background()); err == nil {
+			t.Errorf("%d: want an error", tt.status)
+		}
+		if hits := upstream.hits.Load(); hits != tt.wantHits {
+			t.Errorf("%d: upstream hit %d times, want %d", tt.status, hits, tt.wantHits)
+		}
+		server.Close()
+	}
+}
+
+func TestHTTPCheckerRecoversWithinRetries(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	checker := NewHTTPChecker(HTTPCheckConfig{Name: "up", URL: server.URL, ExpectedStatus: http.StatusOK, Timeout: time.Second})
+	if err := checker.Check(context.Background()); err != nil {
+		t.Fatalf("one 503 then 200: got %v", err)
+	}
+	if got := hits.Load(); got != 2 {
+		t.Fatalf("upstream hit %d times, want 2", got)
+	}
+}
+
+func TestHTTPCheckerStopsRetryingAtProbeDeadline(t *testing.T) {
+	upstream := &flippingUpstream{}
+	upstream.status.Store(http.StatusServiceUnavailable)
+	server := httptest.NewServer(upstream)
+	defer server.Close()
+
+	// Cancel after the first attempt, so no retry may start
+	ctx, cancel := context.WithCancel(context.Background())
+	checker := NewHTTPChecker(HTTPCheckConfig{Name: "up", URL: server.URL, ExpectedStatus: http.StatusOK, Timeout: time.Second})
+	checker.client.Transport = cancelAfterResponse{cancel}
+	if err := checker.Check(ctx); err == nil {
+		t.Fatal("want an error")
+	}
+	if hits := upstream.hits.Load(); hits != 1 {
+		t.Fatalf("upstream hit %d times after the probe ctx was done, want 1", hits)
+	}
+}
+
+// cancelAfterResponse is a RoundTripper that cancels a context once a
+// response has arrived
+type cancelAfterResponse struct {
+	cancel context.CancelFunc
+}
+
+func (c cancelAfterResponse) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	c.cancel()
+	return resp, err
+}
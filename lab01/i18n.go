@@ -0,0 +1,61 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+// supportedLanguages are the languages error messages are available in. The
+// first is the default and is the language messages are written in at the
+// call site.
+var supportedLanguages = []language.Tag{language.English, language.Spanish}
+
+// languageMatcher picks the best supported language for an Accept-Language
+var languageMatcher = language.NewMatcher(supportedLanguages)
+
+// errorMessages translates the message for each error code. English isn't
+// listed: the specific message passed to RespondError is used as is.
+var errorMessages = map[language.Tag]map[string]string{
+	language.Spanish: {
+		CodeInvalidQuery:         "Parámetros de consulta no válidos",
+		CodeInvalidRequest:       "Cuerpo de la solicitud no válido",
+		CodeInvalidID:            "Identificador no válido",
+		CodeUnauthorized:         "Autenticación requerida",
+		CodeForbidden:            "No tiene permiso para realizar esta acción",
+		CodeNotFound:             "Recurso no encontrado",
+		CodeMethodNotAllowed:     "Método no permitido",
+		CodeConflict:             "La solicitud entra en conflicto con el estado actual",
+		CodePayloadTooLarge:      "El cuerpo de la solicitud es demasiado grande",
+		CodePreconditionFailed:   "La precondición ha fallado",
+		CodePreconditionRequired: "Se requiere una precondición",
+		CodeRateLimited:          "Demasiadas solicitudes",
+		CodeQuotaExceeded:        "Cuota excedida",
+		CodeInternal:             "Error interno del servidor",
+		CodeUnavailable:          "Servicio no disponible",
+		CodeTimeout:              "La solicitud ha excedido el tiempo de espera",
+	},
+}
+
+// negotiateLanguage returns the supported language that best matches the
+// request's Accept-Language, or English when nothing matches
+func negotiateLanguage(c *gin.Context) language.Tag {
+	preferred, _, err := language.ParseAcceptLanguage(c.GetHeader("Accept-Language"))
+	if err != nil || len(preferred) == 0 {
+		return supportedLanguages[0]
+	}
+	_, index, confidence := languageMatcher.Match(preferred...)
+	if confidence == language.No {
+		return supportedLanguages[0]
+	}
+	return supportedLanguages[index]
+}
+
+// localizeMessage returns the message for code in the request's language,
+// falling back to the English message when there's no translation
+func localizeMessage(c *gin.Context, code, message string) (language.Tag, string) {
+	lang := negotiateLanguage(c)
+	if translated, ok := errorMessages[lang][code]; ok {
+		return lang, translated
+	}
+	return supportedLanguages[0], message
+}
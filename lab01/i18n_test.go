@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+func TestErrorMessagesFollowAcceptLanguage(t *testing.T) {
+	r := gin.New()
+	r.GET("/missing", func(c *gin.Context) {
+		RespondError(c, http.StatusNotFound, CodeNotFound, "User not found")
+	})
+
+	tests := []struct {
+		acceptLanguage string
+		wantMessage    string
+		wantLanguage   string
+	}{
+		{"", "User not found", "en"},
+		{"en-US,en;q=0.9", "User not found", "en"},
+		{"es", "Recurso no encontrado", "es"},
+		{"es-MX,es;q=0.9,en;q=0.5", "Recurso no encontrado", "es"},
+		{"fr-FR,es;q=0.5", "Recurso no encontrado", "es"},
+		{"de, fr", "User not found", "en"},
+		{";;;not a header", "User not found", "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.acceptLanguage, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			var body APIError
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != CodeNotFound {
+				t.Errorf("code is %q, want it unchanged by language", body.Code)
+			}
+			if body.Message != tt.wantMessage {
+				t.Errorf("message is %q, want %q", body.Message, tt.wantMessage)
+			}
+			if got := w.Header().Get("Content-Language"); got != tt.wantLanguage {
+				t.Errorf("Content-Language is %q, want %q", got, tt.wantLanguage)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept-Language" {
+				t.Errorf("Vary is %q", got)
+			}
+		})
+	}
+}
+
+func TestEveryErrorCodeHasSpanishMessage(t *testing.T) {
+	for _, code := range []string{
+		CodeInvalidQuery, CodeInvalidRequest, CodeInvalidID, CodeUnauthorized, CodeForbidden, CodeNotFound,
+		CodeMethodNotAllowed, CodeConflict, CodePayloadTooLarge, CodePreconditionFailed, CodePreconditionRequired,
+		CodeRateLimited, CodeQuotaExceeded, CodeInternal, CodeUnavailable, CodeTimeout,
+	} {
+		if errorMessages[language.Spanish][code] == "" {
+			t.Errorf("no Spanish message for %s", code)
+		}
+	}
+}
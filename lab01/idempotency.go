@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader is the header clients set to make a POST safe to retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength bounds the keys we're willing to store
+const maxIdempotencyKeyLength = 255
+
+// replayedHeaders are the response headers stored and replayed with a body
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// idempotencyRecord is the outcome of the first request made with a key.
+// done is false while that request is still being handled.
+type idempotencyRecord struct {
+	bodyHash [sha256.Size]byte
+	done     bool
+	status   int
+	header   http.Header
+	body     []byte
+	expires  time.Time
+}
+
+// idempotencyStore keeps idempotencyRecords in memory for ttl
+type idempotencyStore struct {
+	mu         sync.Mutex
+	records    map[string]*idempotencyRecord
+	ttl        time.Duration
+	lastPruned time.Time
+}
+
+// newIdempotencyStore returns a store remembering responses for ttl
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{records: make(map[string]*idempotencyRecord), ttl: ttl, lastPruned: time.Now()}
+}
+
+// begin returns the live record for key, or reserves key for a new request
+// with bodyHash and returns nil
+func (s *idempotencyStore) begin(key string, bodyHash [sha256.Size]byte) *idempotencyRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastPruned) > s.ttl {
+		for k, record := range s.records {
+			if record.done && now.After(record.expires) {
+				delete(s.records, k)
+			}
+		}
+		s.lastPruned = now
+	}
+
+	if record, ok := s.records[key]; ok && (!record.done || now.Before(record.expires)) {
+		copied := *record
+		return &copied
+	}
+	s.records[key] = &idempotencyRecord{bodyHash: bodyHash}
+	return nil
+}
+
+// finish stores the response for a key reserved by begin
+func (s *idempotencyStore) finish(key string, status int, header http.Header, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok {
+		record.done = true
+		record.status = status
+		record.header = header
+		record.body = body
+		record.expires = time.Now().Add(s.ttl)
+	}
+}
+
+// release forgets a key reserved by begin, so the request can be retried
+func (s *idempotencyStore) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+}
+
+// recordingWriter copies the response body as it's written
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency returns a middleware that replays the stored response when a
+// request repeats an Idempotency-Key header. Keys are scoped to the route and
+// the client authenticated by AuthRequired or APIKeyAuth, so it must run
+// after them. Reusing a key with a different body, or while the first
+// request is still running, is a 409. 5xx responses and panics aren't
+// stored, so those can be retried with the same key.
+func Idempotency(store *idempotencyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			RespondError(c, http.StatusBadRequest, CodeInvalidRequest, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			if isBodyTooLarge(err) {
+				respondBodyTooLarge(c)
+				return
+			}
+			RespondError(c, http.StatusBadRequest, CodeInvalidRequest, "Failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		client := UserIDFromContext(c)
+		if client == "" {
+			client = APIClientFromContext(c)
+		}
+		scope := c.Request.Method + " " + c.FullPath() + "\x00" + client + "\x00" + key
+		bodyHash := sha256.Sum256(body)
+
+		if record := store.begin(scope, bodyHash); record != nil {
+			switch {
+			case record.bodyHash != bodyHash:
+				RespondError(c, http.StatusConflict, CodeConflict, "Idempotency-Key was already used with a different request body")
+			case !record.done:
+				RespondError(c, http.StatusConflict, CodeConflict, "A request with this Idempotency-Key is still in progress")
+			default:
+				for name, values := range record.header {
+					c.Writer.Header()[name] = values
+				}
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(record.status, record.header.Get("Content-Type"), record.body)
+				c.Abort()
+			}
+			return
+		}
+
+		// Free the key unless a response is stored for it, including when
+		// the handler panics, so it doesn't stay in progress for good
+		finished := false
+		defer func() {
+			if !finished {
+				store.release(scope)
+			}
+		}()
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		status := writer.Status()
+		if status >= http.StatusInternalServerError || c.Request.Context().Err() != nil {
+			return
+		}
+
+		header := make(http.Header)
+		for _, name := range replayedHeaders {
+			if value := writer.Header().Get(name); value != "" {
+				header.Set(name, value)
+			}
+		}
+		store.finish(scope, status, header, writer.body.Bytes())
+		finished = true
+	}
+}
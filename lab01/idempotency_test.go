@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// idempotentRouter serves POST /user behind Idempotency, taking the
+// authenticated client from an X-Test-User header the way AuthRequired would
+// set it
+func idempotentRouter(t *testing.T, store UserStore) *gin.Engine {
+	jobs := NewJobQueue(1, 10)
+	t.Cleanup(func() { jobs.Shutdown(context.Background()) })
+
+	r := gin.New()
+	r.POST("/user", func(c *gin.Context) {
+		SetUserID(c, c.GetHeader("X-Test-User"))
+	}, Idempotency(newIdempotencyStore(time.Hour)),
+		createUserHandler(store, nil, jobs, NewAuditLogger(&MemoryAuditStore{}), bcrypt.MinCost))
+	return r
+}
+
+// postIdempotent POSTs body to /user on h as client with the given
+// Idempotency-Key, leaving the header out when key is empty
+func postIdempotent(h http.Handler, client, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", client)
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyKeyReplaysCreation(t *testing.T) {
+	store := NewMemoryUserStore()
+	r := idempotentRouter(t, store)
+	const ann = `{"username":"ann","name":"Ann","email":"ann@example.com"}`
+
+	first := postIdempotent(r, "alice", "key-1", ann)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first: got %d: %s", first.Code, first.Body)
+	}
+	retry := postIdempotent(r, "alice", "key-1", ann)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Fatalf("retry: got %d %s, want the original %s", retry.Code, retry.Body, first.Body)
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" || retry.Header().Get("ETag") != first.Header().Get("ETag") {
+		t.Fatalf("retry headers %v, want the replayed ETag and Idempotent-Replayed", retry.Header())
+	}
+
+	users, total, err := store.List(context.Background(), UserListOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 {
+		t.Fatalf("retry created another user: %v", userNames(users))
+	}
+}
+
+func TestIdempotencyKeyWithDifferentBodyConflicts(t *testing.T) {
+	r := idempotentRouter(t, NewMemoryUserStore())
+
+	if w := postIdempotent(r, "alice", "key-1", `{"username":"ann","name":"Ann","email":"ann@example.com"}`); w.Code != http.StatusCreated {
+		t.Fatalf("first: got %d: %s", w.Code, w.Body)
+	}
+	w := postIdempotent(r, "alice", "key-1", `{"username":"bob","name":"Bob","email":"bob@example.com"}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("reused key with another body: got %d, want 409", w.Code)
+	}
+}
+
+func TestIdempotencyKeysAreScopedPerClient(t *testing.T) {
+	r := idempotentRouter(t, NewMemoryUserStore())
+
+	if w := postIdempotent(r, "alice", "shared", `{"username":"ann","name":"Ann","email":"ann@example.com"}`); w.Code != http.StatusCreated {
+		t.Fatalf("alice: got %d: %s", w.Code, w.Body)
+	}
+	w := postIdempotent(r, "bob", "shared", `{"username":"bob","name":"Bob","email":"bob@example.com"}`)
+	if w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("bob reusing alice's key: got %d %s, want his own user created", w.Code, w.Body)
+	}
+}
+
+func TestIdempotencyRequestsWithoutKeyAreNotReplayed(t *testing.T) {
+	r := idempotentRouter(t, NewMemoryUserStore())
+
+	if w := postIdempotent(r, "alice", "", `{"username":"ann","name":"Ann","email":"ann@example.com"}`); w.Code != http.StatusCreated {
+		t.Fatalf("first: got %d: %s", w.Code, w.Body)
+	}
+	if w := postIdempotent(r, "alice", "", `{"username":"ann","name":"Ann","email":"ann@example.com"}`); w.Code != http.StatusConflict {
+		t.Fatalf("unkeyed retry: got %d, want the handler's duplicate username 409", w.Code)
+	}
+	if w := postIdempotent(r, "alice", strings.Repeat("k", maxIdempotencyKeyLength+1), `{}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("overlong key: got %d, want 400", w.Code)
+	}
+}
+
+func TestIdempotencyKeyFreedWhenHandlerPanics(t *testing.T) {
+	calls := 0
+	r := gin.New()
+	r.Use(Recovery())
+	r.POST("/user", Idempotency(newIdempotencyStore(time.Hour)), func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("store exploded")
+		}
+		c.JSON(http.StatusCreated, gin.H{"id": 1})
+	})
+	const body = `{"username":"ann"}`
+
+	if w := postIdempotent(r, "", "key-1", body); w.Code != http.StatusInternalServerError {
+		t.Fatalf("panicking request: got %d, want 500", w.Code)
+	}
+	retry := postIdempotent(r, "", "key-1", body)
+	if retry.Code != http.StatusCreated || retry.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("retry after a panic: got %d %s, want the handler to run again", retry.Code, retry.Body)
+	}
+	if replay := postIdempotent(r, "", "key-1", body); replay.Code != http.StatusCreated || replay.Header().Get("Idempotent-Replayed") != "true" || calls != 2 {
+		t.Fatalf("replay: got %d after %d calls, want the stored 201", replay.Code, calls)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InFlight returns a middleware counting the requests currently being
+// handled in count, so shutdown can report how many it drained
+func InFlight(count *atomic.Int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		count.Add(1)
+		defer count.Add(-1)
+
+		c.Next()
+	}
+}
+
+// drainRequests shuts server down, waiting for the inFlight requests until
+// ctx is done and then closing the remaining connections. It logs how many
+// requests were drained and, on timeout, how many were abandoned.
+func drainRequests(ctx context.Context, server *http.Server, inFlight *atomic.Int64, timeout time.Duration) {
+	draining := inFlight.Load()
+	slog.Info("Draining in-flight requests", "in_flight", draining, "timeout", timeout)
+	if err := server.Shutdown(ctx); err != nil {
+		abandoned := inFlight.Load()
+		slog.Error("Shutdown timeout elapsed, closing remaining connections",
+			"drained", max(draining-abandoned, 0), "abandoned", abandoned, "error", err)
+		server.Close()
+		return
+	}
+	slog.Info("Drained in-flight requests", "drained", draining)
+}
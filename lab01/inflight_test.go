@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// drainFixture serves /slow, counted by InFlight, which returns once release
+// is closed or the client goes away. It returns the server, its address, the
+// in-flight count and a buffer capturing the default logger until the test
+// ends.
+func drainFixture(t *testing.T, release <-chan struct{}) (*http.Server, string, *atomic.Int64, *bytes.Buffer) {
+	t.Helper()
+	var logs bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	var inFlight atomic.Int64
+	r := gin.New()
+	r.Use(InFlight(&inFlight))
+	r.GET("/slow", func(c *gin.Context) {
+		select {
+		case <-release:
+			c.Status(http.StatusOK)
+		case <-c.Request.Context().Done():
+		}
+	})
+	server := &http.Server{Handler: r}
+	return server, serve(t, server), &inFlight, &logs
+}
+
+// startSlowRequest GETs /slow on addr in the background, sending the status
+// or error on the returned channel, and waits until it's being handled
+func startSlowRequest(t *testing.T, addr string, inFlight *atomic.Int64) <-chan any {
+	t.Helper()
+	result := make(chan any, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			result <- err
+			return
+		}
+		resp.Body.Close()
+		result <- resp.StatusCode
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for inFlight.Load() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("request never reached the handler")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return result
+}
+
+func TestDrainRequestsWaitsForInFlight(t *testing.T) {
+	release := make(chan struct{})
+	server, addr, inFlight, logs := drainFixture(t, release)
+	result := startSlowRequest(t, addr, inFlight)
+
+	drained := make(chan struct{})
+	go func() {
+		drainRequests(context.Background(), server, inFlight, time.Minute)
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		t.Fatal("shutdown returned while a request was in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatal("shutdown didn't return once the request finished")
+	}
+	if got := <-result; got != http.StatusOK {
+		t.Fatalf("drained request got %v, want 200", got)
+	}
+	if inFlight.Load() != 0 || !strings.Contains(logs.String(), `msg="Drained in-flight requests" drained=1`) {
+		t.Fatalf("in flight %d, logs: %s", inFlight.Load(), logs)
+	}
+}
+
+func TestDrainRequestsAbandonsAtDeadline(t *testing.T) {
+	server, addr, inFlight, logs := drainFixture(t, nil)
+	result := startSlowRequest(t, addr, inFlight)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	drainRequests(ctx, server, inFlight, 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("shutdown took %s with a 50ms timeout", elapsed)
+	}
+
+	if got := <-result; got == http.StatusOK {
+		t.Fatal("abandoned request completed")
+	}
+	if !strings.Contains(logs.String(), "drained=0 abandoned=1") {
+		t.Fatalf("logs don't report the abandoned request: %s", logs)
+	}
+}
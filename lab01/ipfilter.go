@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// privateNetworks are the loopback and private ranges internal endpoints
+// accept by default
+var privateNetworks = []string{
+	"127.0.0.0/8",
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::1/128",
+	"fc00::/7",
+}
+
+// IPFilter returns a middleware that rejects clients outside allow or inside
+// deny with 403. Deny takes precedence, and an empty allow list admits any
+// address that isn't denied. Entries are CIDR ranges or single addresses.
+//
+// The client address comes from c.ClientIP, which only honors
+// X-Forwarded-For when the request arrives through a trusted proxy.
+func IPFilter(allow, deny []string) (gin.HandlerFunc, error) {
+	allowed, err := parseCIDRs(allow)
+	if err != nil {
+		return nil, err
+	}
+	denied, err := parseCIDRs(deny)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(c *gin.Context) {
+		ip := net.ParseIP(c.ClientIP())
+		if ip == nil || containsIP(denied, ip) || (len(allowed) > 0 && !containsIP(allowed, ip)) {
+			RespondError(c, http.StatusForbidden, CodeForbidden, "access from this address is not allowed")
+			return
+		}
+		c.Next()
+	}, nil
+}
+
+// parseCIDRs parses CIDR ranges, treating bare addresses as single-host ranges
+func parseCIDRs(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// containsIP reports whether any of networks contains ip
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ipFilterRouter serves /metrics behind IPFilter(allow, deny), trusting
+// client IP headers only from trustedProxies
+func ipFilterRouter(t *testing.T, allow, deny, trustedProxies []string) *gin.Engine {
+	t.Helper()
+	filter, err := IPFilter(allow, deny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		t.Fatal(err)
+	}
+	r.GET("/metrics", filter, func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+// getFrom sends a GET of target on h from remoteAddr, with X-Forwarded-For
+// set when forwardedFor isn't empty
+func getFrom(h http.Handler, target, remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestIPFilter(t *testing.T) {
+	r := ipFilterRouter(t, privateNetworks, []string{"10.9.0.0/16", "192.168.1.50"}, nil)
+	tests := []struct {
+		name, remoteAddr string
+		want             int
+	}{
+		{"allowed private address", "10.1.2.3:5000", http.StatusOK},
+		{"allowed loopback", "127.0.0.1:5000", http.StatusOK},
+		{"allowed IPv6 loopback", "[::1]:5000", http.StatusOK},
+		{"public address", "203.0.113.5:5000", http.StatusForbidden},
+		{"denied range inside the allowed one", "10.9.8.7:5000", http.StatusForbidden},
+		{"denied single address", "192.168.1.50:5000", http.StatusForbidden},
+		{"neighbour of the denied address", "192.168.1.51:5000", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := getFrom(r, "/metrics", tt.remoteAddr, ""); w.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
+
+func TestIPFilterEmptyAllowAdmitsUndenied(t *testing.T) {
+	r := ipFilterRouter(t, nil, []string{"203.0.113.0/24"}, nil)
+	if w := getFrom(r, "/metrics", "198.51.100.7:5000", ""); w.Code != http.StatusOK {
+		t.Fatalf("undenied address: got %d", w.Code)
+	}
+	if w := getFrom(r, "/metrics", "203.0.113.7:5000", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("denied address: got %d", w.Code)
+	}
+}
+
+func TestIPFilterForwardedFor(t *testing.T) {
+	// Without trusted proxies a spoofed header doesn't get a public client in
+	untrusting := ipFilterRouter(t, privateNetworks, nil, nil)
+	if w := getFrom(untrusting, "/metrics", "203.0.113.5:5000", "10.0.0.1"); w.Code != http.StatusForbidden {
+		t.Fatalf("spoofed X-Forwarded-For: got %d, want 403", w.Code)
+	}
+
+	// Through a trusted proxy the forwarded address is the one checked
+	trusting := ipFilterRouter(t, privateNetworks, nil, []string{"10.0.0.0/8"})
+	if w := getFrom(trusting, "/metrics", "10.0.0.2:5000", "203.0.113.5"); w.Code != http.StatusForbidden {
+		t.Fatalf("public client through a proxy: got %d, want 403", w.Code)
+	}
+	if w := getFrom(trusting, "/metrics", "10.0.0.2:5000", "192.168.0.9"); w.Code != http.StatusOK {
+		t.Fatalf("private client through a proxy: got %d, want 200", w.Code)
+	}
+}
+
+func TestIPFilterRejectsMalformedRanges(t *testing.T) {
+	for _, bad := range [][]string{{"10.0.0.0/33"}, {"not-an-ip"}, {"10.0.0/8"}} {
+		if _, err := IPFilter(bad, nil); err == nil {
+			t.Errorf("allow %v was accepted", bad)
+		}
+		if _, err := IPFilter(nil, bad); err == nil {
+			t.Errorf("deny %v was accepted", bad)
+		}
+	}
+}
+
+func TestTrustedProxiesGateForwardedFor(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies string
+		remoteAddr     string
+		want           string
+	}{
+		{"unset ignores the header", "", "10.0.0.2:5000", "10.0.0.2"},
+		{"trusted peer", "10.0.0.0/8, 192.168.1.1", "10.0.0.2:5000", "203.0.113.5"},
+		{"trusted single address", "10.0.0.0/8, 192.168.1.1", "192.168.1.1:5000", "203.0.113.5"},
+		{"untrusted peer", "10.0.0.0/8, 192.168.1.1", "198.51.100.7:5000", "198.51.100.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadTestConfig(t, map[string]string{"TRUSTED_PROXIES": tt.trustedProxies})
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := gin.New()
+			if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+				t.Fatal(err)
+			}
+			r.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+
+			if got := getFrom(r, "/ip", tt.remoteAddr, "203.0.113.5").Body.String(); got != tt.want {
+				t.Fatalf("client IP is %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
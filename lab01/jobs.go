@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Errors returned by JobQueue.Enqueue
+var (
+	ErrQueueClosed = errors.New("job queue is shut down")
+	ErrQueueFull   = errors.New("job queue is full")
+)
+
+// jobQueueDepth reports how many jobs are waiting for a worker
+var jobQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "job_queue_depth",
+	Help: "Number of background jobs waiting to run.",
+})
+
+// Job is a unit of background work
+type Job struct {
+	// Name identifies the job in logs
+	Name string
+	// Run does the work; ctx is canceled if shutdown gives up waiting
+	Run func(ctx context.Context) error
+}
+
+// JobQueue runs jobs on a fixed pool of worker goroutines so slow work
+// doesn't hold up request handlers
+type JobQueue struct {
+	jobs chan Job
+	wg   sync.WaitGroup
+
+	// mu guards closed so Enqueue never sends on a closed channel
+	mu     sync.RWMutex
+	closed bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewJobQueue starts workers goroutines draining a queue of up to size jobs
+func NewJobQueue(workers, size int) *JobQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &JobQueue{
+		jobs:   make(chan Job, size),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	q.wg.Add(workers)
+	for range workers {
+		go q.work()
+	}
+	return q
+}
+
+// Enqueue adds job to the queue without blocking
+func (q *JobQueue) Enqueue(job Job) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case q.jobs <- job:
+		jobQueueDepth.Inc()
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Shutdown stops accepting jobs and waits for queued and running ones to
+// finish. If ctx expires first, running jobs' contexts are canceled and the
+// remaining queue is discarded.
+func (q *JobQueue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// work runs jobs until the queue is closed and drained
+func (q *JobQueue) work() {
+	defer q.wg.Done()
+
+	for job := range q.jobs {
+		jobQueueDepth.Dec()
+
+		// Once shutdown has given up, skip whatever is still queued
+		if q.ctx.Err() != nil {
+			continue
+		}
+		q.run(job)
+	}
+}
+
+// run executes a single job, logging failures and recovering panics so one
+// bad job can't take down a worker
+func (q *JobQueue) run(job Job) {
+	start := time.Now()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			slog.Error("Job panicked", "job", job.Name, "panic", recovered)
+		}
+	}()
+
+	if err := job.Run(q.ctx); err != nil {
+		slog.Error("Job failed", "job", job.Name, "duration", time.Since(start), "error", err)
+		return
+	}
+	slog.Debug("Job finished", "job", job.Name, "duration", time.Since(start))
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestJobQueueRunsJobs(t *testing.T) {
+	q := NewJobQueue(2, 10)
+	var ran atomic.Int32
+	for range 5 {
+		if err := q.Enqueue(Job{Name: "count", Run: func(ctx context.Context) error {
+			ran.Add(1)
+			return nil
+		}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Failing and panicking jobs don't stop the workers
+	q.Enqueue(Job{Name: "fail", Run: func(ctx context.Context) error { return errors.New("smtp down") }})
+	q.Enqueue(Job{Name: "panic", Run: func(ctx context.Context) error { panic("bad job") }})
+	q.Enqueue(Job{Name: "count", Run: func(ctx context.Context) error {
+		ran.Add(1)
+		return nil
+	}})
+
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := ran.Load(); got != 6 {
+		t.Fatalf("%d jobs ran, want 6", got)
+	}
+	if got := testutil.ToFloat64(jobQueueDepth); got != 0 {
+		t.Fatalf("queue depth is %v after draining", got)
+	}
+}
+
+func TestJobQueueShutdownWaitsForJobs(t *testing.T) {
+	q := NewJobQueue(1, 10)
+	var finished atomic.Int32
+	slow := Job{Name: "slow", Run: func(ctx context.Context) error {
+		time.Sleep(50 * time.Millisecond)
+		finished.Add(1)
+		return nil
+	}}
+	q.Enqueue(slow)
+	q.Enqueue(slow)
+
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := finished.Load(); got != 2 {
+		t.Fatalf("Shutdown returned with %d of 2 jobs finished", got)
+	}
+	if err := q.Enqueue(slow); !errors.Is(err, ErrQueueClosed) {
+		t.Fatalf("Enqueue after Shutdown: got %v", err)
+	}
+	// A second Shutdown is harmless
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJobQueueShutdownTimeoutCancelsJobs(t *testing.T) {
+	q := NewJobQueue(1, 10)
+	started := make(chan struct{})
+	var canceled, queuedRan atomic.Bool
+	q.Enqueue(Job{Name: "hang", Run: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		canceled.Store(true)
+		return ctx.Err()
+	}})
+	q.Enqueue(Job{Name: "queued", Run: func(ctx context.Context) error {
+		queuedRan.Store(true)
+		return nil
+	}})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the deadline error", err)
+	}
+	if !canceled.Load() {
+		t.Fatal("running job's context wasn't canceled")
+	}
+	if queuedRan.Load() {
+		t.Fatal("queued job ran after shutdown gave up")
+	}
+}
+
+func TestJobQueueFull(t *testing.T) {
+	q := NewJobQueue(1, 1)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	q.Enqueue(Job{Name: "block", Run: func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}})
+	<-started
+
+	noop := Job{Name: "noop", Run: func(ctx context.Context) error { return nil }}
+	if err := q.Enqueue(noop); err != nil {
+		t.Fatalf("queue with a free slot: %v", err)
+	}
+	if got := testutil.ToFloat64(jobQueueDepth); got != 1 {
+		t.Fatalf("queue depth is %v, want 1", got)
+	}
+	if err := q.Enqueue(noop); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("full queue: got %v", err)
+	}
+
+	close(release)
+	q.Shutdown(context.Background())
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loadShedRetryAfter is how long shed clients are told to wait; load spikes
+// usually clear quickly
+const loadShedRetryAfter = time.Second
+
+// probePaths bypass load shedding so orchestrators don't mistake a busy
+// instance for a dead one
+var probePaths = map[string]bool{
+	"/ping":    true,
+	"/health":  true,
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// LoadShed returns a middleware that lets at most limit requests run at once
+// and rejects the rest immediately with 503, instead of letting them queue
+// up. A limit of 0 disables the cap, though the concurrency gauge is still
+// kept up to date.
+func LoadShed(limit int) gin.HandlerFunc {
+	retryAfter := strconv.Itoa(int(loadShedRetryAfter.Seconds()))
+
+	var slots chan struct{}
+	if limit > 0 {
+		slots = make(chan struct{}, limit)
+	}
+
+	return func(c *gin.Context) {
+		if probePaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			default:
+				c.Header("Retry-After", retryAfter)
+				RespondError(c, http.StatusServiceUnavailable, CodeUnavailable, "server busy")
+				return
+			}
+		}
+
+		concurrentRequests.Inc()
+		defer concurrentRequests.Dec()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// loadShedRouter serves /work, which blocks until release is closed, and
+// /healthz behind LoadShed(limit)
+func loadShedRouter(limit int, release <-chan struct{}) *gin.Engine {
+	r := gin.New()
+	r.Use(LoadShed(limit))
+	r.GET("/work", func(c *gin.Context) {
+		<-release
+		c.Status(http.StatusOK)
+	})
+	r.GET("/healthz", livenessHandler)
+	return r
+}
+
+// waitForConcurrentRequests polls until the concurrency gauge reads n
+func waitForConcurrentRequests(t *testing.T, n float64) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for testutil.ToFloat64(concurrentRequests) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("concurrent requests gauge is %v, want %v", testutil.ToFloat64(concurrentRequests), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLoadShedRejectsRequestsOverLimit(t *testing.T) {
+	const limit = 3
+	release := make(chan struct{})
+	r := loadShedRouter(limit, release)
+
+	var wg sync.WaitGroup
+	codes := make(chan int, limit)
+	for range limit {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- get(r, "/work").Code
+		}()
+	}
+	waitForConcurrentRequests(t, limit)
+
+	shed := get(r, "/work")
+	var body APIError
+	if err := json.Unmarshal(shed.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if shed.Code != http.StatusServiceUnavailable || body.Message != "server busy" || shed.Header().Get("Retry-After") != "1" {
+		t.Fatalf("request %d: got %d %s with Retry-After %q, want a 503 server busy", limit+1, shed.Code, shed.Body, shed.Header().Get("Retry-After"))
+	}
+
+	// Probes bypass the limit
+	if w := get(r, "/healthz"); w.Code != http.StatusOK {
+		t.Fatalf("liveness probe while busy: got %d, want 200", w.Code)
+	}
+
+	close(release)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("admitted request got %d, want 200", code)
+		}
+	}
+	waitForConcurrentRequests(t, 0)
+
+	// Freed slots admit new requests
+	if w := get(r, "/work"); w.Code != http.StatusOK {
+		t.Fatalf("after the spike: got %d, want 200", w.Code)
+	}
+}
+
+func TestLoadShedZeroLimitDisablesCap(t *testing.T) {
+	release := make(chan struct{})
+	r := loadShedRouter(0, release)
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if w := get(r, "/work"); w.Code != http.StatusOK {
+				t.Errorf("got %d with no limit", w.Code)
+			}
+		}()
+	}
+	waitForConcurrentRequests(t, 20)
+	close(release)
+	wg.Wait()
+}
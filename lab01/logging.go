@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// logLevel is the active minimum log level, adjustable at runtime
+var logLevel = new(slog.LevelVar)
+
+// newLogger creates a text or JSON slog logger writing to out at logLevel
+func newLogger(out io.Writer, level, format string) *slog.Logger {
+	logLevel.Set(parseLogLevel(level))
+
+	opts := &slog.HandlerOptions{Level: logLevel}
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(out, opts))
+	}
+	return slog.New(slog.NewTextHandler(out, opts))
+}
+
+// LogFileConfig controls the rotating access log file
+type LogFileConfig struct {
+	// Path is the access log file; request logs only go to stdout when empty
+	Path       string
+	MaxSizeMB  int
+	MaxBackups int
+	MaxAgeDays int
+	// Stdout keeps request logs on stdout as well as in the file
+	Stdout bool
+}
+
+// newAccessLogger returns the logger RequestLogger writes to: logger itself
+// when no file is configured, otherwise a JSON logger writing to a rotating
+// file, mirrored to logger when cfg.Stdout is set. lumberjack serializes
+// writes internally, so concurrent requests can share it. The returned
+// io.Closer closes the file.
+func newAccessLogger(logger *slog.Logger, cfg LogFileConfig) (*slog.Logger, io.Closer) {
+	if cfg.Path == "" {
+		return logger, io.NopCloser(nil)
+	}
+
+	file := &lumberjack.Logger{
+		Filename:   cfg.Path,
+		MaxSize:    cfg.MaxSizeMB,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAgeDays,
+	}
+	handler := slog.Handler(slog.NewJSONHandler(file, &slog.HandlerOptions{Level: logLevel}))
+	if cfg.Stdout {
+		handler = teeHandler{logger.Handler(), handler}
+	}
+	return slog.New(handler), file
+}
+
+// teeHandler sends each record to every handler that's enabled for it
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, h := range t {
+		if h.Enabled(ctx, record.Level) {
+			errs = append(errs, h.Handle(ctx, record.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := make(teeHandler, len(t))
+	for i, h := range t {
+		next[i] = h.WithAttrs(attrs)
+	}
+	return next
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	next := make(teeHandler, len(t))
+	for i, h := range t {
+		next[i] = h.WithGroup(name)
+	}
+	return next
+}
+
+// parseLogLevel converts a validated LOG_LEVEL value into a slog.Level
+func parseLogLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// fatal logs msg at error level and exits with status 1
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// sampled reports whether the request with requestID is among the one in rate
+// requests that get logged. Hashing the ID keeps the decision independent per
+// request, without a shared counter that concurrent requests would contend on.
+func sampled(requestID string, rate int) bool {
+	if rate <= 1 {
+		return true
+	}
+	h := fnv.New64a()
+	h.Write([]byte(requestID))
+	return h.Sum64()%uint64(rate) == 0
+}
+
+// RequestLogger returns a middleware that logs one structured record per
+// request through logger. Requests taking longer than slowThreshold are
+// logged at warn level as "slow request" so they're easy to alert on. Only
+// one in sampleRate() 2xx responses is logged; everything else always is.
+func RequestLogger(logger *slog.Logger, slowThreshold time.Duration, sampleRate func() int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+
+		c.Next()
+
+		latency := time.Since(start)
+		level, msg := slog.LevelInfo, "request"
+		if latency > slowThreshold {
+			level, msg = slog.LevelWarn, "slow request"
+		}
+
+		status := c.Writer.Status()
+		if level == slog.LevelInfo && status >= 200 && status < 300 && !sampled(RequestIDFromContext(c), sampleRate()) {
+			return
+		}
+
+		// Status and size are only known once the handler chain has run
+		logger.LogAttrs(c.Request.Context(), level, msg,
+			slog.String("request_id", RequestIDFromContext(c)),
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", path),
+			slog.Int("status", status),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.Float64("latency_ms", float64(latency.Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+		)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// captureLogger returns a logger built by newLogger writing into a buffer,
+// restoring the global log level afterwards
+func captureLogger(t *testing.T, level, format string) (*slog.Logger, *bytes.Buffer) {
+	t.Helper()
+	prev := logLevel.Level()
+	t.Cleanup(func() { logLevel.Set(prev) })
+
+	var out bytes.Buffer
+	return newLogger(&out, level, format), &out
+}
+
+func TestNewLoggerSuppressesDebugAtInfo(t *testing.T) {
+	logger, out := captureLogger(t, "info", "text")
+
+	logger.Debug("hidden detail")
+	logger.Info("server started", "port", "9000")
+
+	if strings.Contains(out.String(), "hidden detail") {
+		t.Fatalf("debug line logged at info level: %s", out)
+	}
+	if !strings.Contains(out.String(), "server started") || !strings.Contains(out.String(), "port=9000") {
+		t.Fatalf("info line missing: %s", out)
+	}
+}
+
+func TestNewLoggerLevels(t *testing.T) {
+	tests := []struct {
+		level string
+		// logged lists which of debug, info, warn and error get through
+		logged []bool
+	}{
+		{"debug", []bool{true, true, true, true}},
+		{"info", []bool{false, true, true, true}},
+		{"warn", []bool{false, false, true, true}},
+		{"error", []bool{false, false, false, true}},
+	}
+	for _, tt := range tests {
+		logger, out := captureLogger(t, tt.level, "text")
+		logger.Debug("at-debug")
+		logger.Info("at-info")
+		logger.Warn("at-warn")
+		logger.Error("at-error")
+
+		for i, msg := range []string{"at-debug", "at-info", "at-warn", "at-error"} {
+			if strings.Contains(out.String(), msg) != tt.logged[i] {
+				t.Errorf("LOG_LEVEL=%s: %s logged = %v, want %v", tt.level, msg, !tt.logged[i], tt.logged[i])
+			}
+		}
+	}
+}
+
+func TestNewLoggerJSONFormat(t *testing.T) {
+	logger, out := captureLogger(t, "debug", "json")
+	logger.Debug("cache miss", "key", "search:go:10:0")
+
+	var record map[string]any
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	if record["level"] != "DEBUG" || record["msg"] != "cache miss" || record["key"] != "search:go:10:0" {
+		t.Fatalf("record is %v", record)
+	}
+}
+
+func TestLogLevelChangesAtRuntime(t *testing.T) {
+	logger, out := captureLogger(t, "info", "text")
+	logger.Debug("before")
+
+	logLevel.Set(slog.LevelDebug)
+	logger.Debug("after")
+
+	if strings.Contains(out.String(), "before") || !strings.Contains(out.String(), "after") {
+		t.Fatalf("log is %s", out)
+	}
+}
+
+// loggedRouter serves a fast /fast and a /slow that sleeps for delay, behind
+// RequestID and a RequestLogger writing JSON to the returned buffer
+func loggedRouter(t *testing.T, slowThreshold, delay time.Duration, sampleRate int) (*gin.Engine, *bytes.Buffer) {
+	logger, out := captureLogger(t, "info", "json")
+	r := gin.New()
+	r.Use(RequestID(), RequestLogger(logger, slowThreshold, func() int { return sampleRate }))
+	r.GET("/fast", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/slow", func(c *gin.Context) {
+		time.Sleep(delay)
+		c.Status(http.StatusOK)
+	})
+	return r, out
+}
+
+func TestRequestLoggerWarnsOnSlowRequests(t *testing.T) {
+	tests := []struct {
+		target    string
+		wantLevel string
+		wantMsg   string
+	}{
+		{"/fast", "INFO", "request"},
+		{"/slow", "WARN", "slow request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target[1:], func(t *testing.T) {
+			r, out := loggedRouter(t, 20*time.Millisecond, 40*time.Millisecond, 1)
+			w := getWithRequestID(r, tt.target, "req-"+tt.target[1:])
+
+			var record struct {
+				Level     string  `json:"level"`
+				Msg       string  `json:"msg"`
+				RequestID string  `json:"request_id"`
+				Route     string  `json:"route"`
+				LatencyMS float64 `json:"latency_ms"`
+			}
+			if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+				t.Fatalf("want one JSON record, got %q: %v", out, err)
+			}
+			if record.Level != tt.wantLevel || record.Msg != tt.wantMsg {
+				t.Errorf("logged %s %q, want %s %q", record.Level, record.Msg, tt.wantLevel, tt.wantMsg)
+			}
+			if record.RequestID != w.Header().Get(RequestIDHeader) || record.Route != tt.target {
+				t.Errorf("logged request_id %q and route %q, want %q and %q",
+					record.RequestID, record.Route, w.Header().Get(RequestIDHeader), tt.target)
+			}
+			if tt.target == "/slow" && record.LatencyMS < 40 {
+				t.Errorf("logged latency %.1fms for a 40ms request", record.LatencyMS)
+			}
+		})
+	}
+}
+
+func TestRequestLoggerAlwaysLogsSlowRequestsWhenSampling(t *testing.T) {
+	// With a huge sample rate a fast 2xx is almost never logged, but a slow
+	// one always is
+	r, out := loggedRouter(t, 5*time.Millisecond, 10*time.Millisecond, 1<<30)
+	for range 5 {
+		get(r, "/slow")
+	}
+	if got := strings.Count(out.String(), `"slow request"`); got != 5 {
+		t.Fatalf("logged %d of 5 slow requests: %s", got, out)
+	}
+}
+
+func TestAccessLogFile(t *testing.T) {
+	for _, mirror := range []bool{false, true} {
+		t.Run("stdout="+strconv.FormatBool(mirror), func(t *testing.T) {
+			cfg, err := loadTestConfig(t, map[string]string{
+				"LOG_FILE":        filepath.Join(t.TempDir(), "access.log"),
+				"LOG_FILE_STDOUT": strconv.FormatBool(mirror),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			stdout, out := captureLogger(t, "info", "text")
+			logger, closer := newAccessLogger(stdout, cfg.AccessLog)
+
+			r := gin.New()
+			r.Use(RequestID(), RequestLogger(logger, time.Second, func() int { return 1 }))
+			r.GET("/ping", pingHandler)
+			get(r, "/ping")
+			if err := closer.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(cfg.AccessLog.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var record struct {
+				Msg    string `json:"msg"`
+				Route  string `json:"route"`
+				Status int    `json:"status"`
+			}
+			if err := json.Unmarshal(bytes.TrimSpace(data), &record); err != nil {
+				t.Fatalf("want one JSON line in the log file, got %q: %v", data, err)
+			}
+			if record.Msg != "request" || record.Route != "/ping" || record.Status != http.StatusOK {
+				t.Fatalf("logged %+v", record)
+			}
+			if mirrored := strings.Contains(out.String(), "route=/ping"); mirrored != mirror {
+				t.Fatalf("stdout mirrored %t, want %t: %q", mirrored, mirror, out)
+			}
+		})
+	}
+}
+
+func TestAccessLogWithoutFileUsesLogger(t *testing.T) {
+	logger, _ := captureLogger(t, "info", "text")
+	if got, _ := newAccessLogger(logger, LogFileConfig{}); got != logger {
+		t.Fatal("without LOG_FILE request logs should go to the main logger")
+	}
+}
+
+func TestRequestLoggerSamplesSuccessesButKeepsErrors(t *testing.T) {
+	logger, out := captureLogger(t, "info", "json")
+	r := gin.New()
+	r.Use(RequestID(), RequestLogger(logger, time.Minute, func() int { return 10 }))
+	r.GET("/status/:code", func(c *gin.Context) {
+		code, _ := strconv.Atoi(c.Param("code"))
+		c.Status(code)
+	})
+
+	const requests = 500
+	count := func(status int) int {
+		out.Reset()
+		for i := range requests {
+			getWithRequestID(r, "/status/"+strconv.Itoa(status), "req-"+strconv.Itoa(i))
+		}
+		return strings.Count(out.String(), `"status":`+strconv.Itoa(status))
+	}
+
+	for _, status := range []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError} {
+		if got := count(status); got != requests {
+			t.Errorf("logged %d of %d %d responses, want all of them", got, requests, status)
+		}
+	}
+	// One in ten, give or take the hash's spread
+	if got := count(http.StatusOK); got < requests/20 || got > requests/5 {
+		t.Errorf("logged %d of %d 200 responses, want about %d", got, requests, requests/10)
+	}
+}
+
+func TestSampledIsDeterministicPerRequest(t *testing.T) {
+	for i := range 100 {
+		id := "req-" + strconv.Itoa(i)
+		if sampled(id, 7) != sampled(id, 7) {
+			t.Fatalf("%s sampled inconsistently", id)
+		}
+		if !sampled(id, 1) || !sampled(id, 0) {
+			t.Fatalf("%s dropped with sampling off", id)
+		}
+	}
+}
@@ -1,130 +1,612 @@
 package main
 
 import (
-	"log"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"google.golang.org/grpc"
+
+	_ "lab01/docs"
 )
 
 // PingResponse represents the response structure for ping endpoint
 type PingResponse struct {
-	Message string `json:"message"`
-	Status  string `json:"status"`
+	XMLName xml.Name `json:"-" xml:"ping"`
+	Message string   `json:"message" xml:"message"`
+	Status  string   `json:"status" xml:"status"`
 }
 
 // HealthResponse represents the response structure for health check
 type HealthResponse struct {
-	Service string `json:"service"`
-	Status  string `json:"status"`
-	Version string `json:"version"`
+	XMLName       xml.Name `json:"-" xml:"health"`
+	Service       string   `json:"service" xml:"service"`
+	Status        string   `json:"status" xml:"status"`
+	Version       string   `json:"version" xml:"version"`
+	UptimeSeconds int64    `json:"uptime_seconds" xml:"uptime_seconds"`
+	StartedAt     string   `json:"started_at" xml:"started_at"`
 }
 
+// startTime records when the process started serving
+var startTime time.Time
+
+//go:generate go tool swag init --parseDependency=false --outputTypes go,json
+
+//	@title			Go API Lab
+//	@version		1.0.0
+//	@description	RESTful API built with Go and the Gin framework.
+//	@BasePath		/
+
+//	@securityDefinitions.apikey	BearerAuth
+//	@in							header
+//	@name						Authorization
+
+//	@securityDefinitions.basic	AdminAuth
+
 func main() {
-	// Load environment variables from .env file
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using default values")
+	startTime = time.Now()
+	flag.Parse()
+
+	// Failures after startup shut down through the normal path below and
+	// set exitCode, so deferred cleanup runs before the process exits
+	var exitCode int
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
+	// Load and validate configuration, refusing to start with bad settings
+	cfg, err := LoadConfig()
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+
+	// Route all logs, including request logs, through one slog handler
+	logger := newLogger(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	slog.SetDefault(logger)
+
+	// Request logs can also go to a rotating file
+	accessLogger, accessLog := newAccessLogger(logger, cfg.AccessLog)
+	defer accessLog.Close()
+	if cfg.AccessLog.Path != "" {
+		slog.Info("Writing request logs to file", "path", cfg.AccessLog.Path, "stdout", cfg.AccessLog.Stdout)
+	}
+
+	if !cfg.DotEnvLoaded {
+		slog.Info("No .env file found, using default values")
+	}
+	if cfg.ConfigFile != "" {
+		slog.Info("Read settings from config file", "path", cfg.ConfigFile)
+	}
+
+	// Generate a per-process JWT secret when none is configured
+	if len(cfg.JWTSecret) == 0 {
+		slog.Warn("JWT_SECRET not set, generating a random secret; tokens won't survive restarts")
+		cfg.JWTSecret = make([]byte, 32)
+		rand.Read(cfg.JWTSecret)
+	}
+	// Session cookies get their own key, so neither leaks the other
+	if len(cfg.SessionSecret) == 0 {
+		slog.Warn("SESSION_SECRET not set, generating a random secret; sessions won't survive restarts")
+		cfg.SessionSecret = make([]byte, 32)
+		rand.Read(cfg.SessionSecret)
+	}
+	SetConfig(cfg)
+
+	// Export traces over OTLP when an endpoint is configured
+	shutdownTracing, err := initTracing(context.Background(), cfg.OTLPEndpoint)
+	if err != nil {
+		fatal("Failed to initialize tracing", "error", err)
+	}
+
+	// Create the search backends; swap for a real implementation later. The
+	// term searcher serves requests with the new_search flag enabled.
+	var searcher Searcher = NewMemorySearcher(sampleDocuments)
+	var termSearcher Searcher = NewTermSearcher(sampleDocuments)
+
+	// Simulate a slow backend to exercise request cancellation
+	if cfg.SearchDelay > 0 {
+		searcher = NewSlowSearcher(searcher, cfg.SearchDelay)
+		termSearcher = NewSlowSearcher(termSearcher, cfg.SearchDelay)
+		slog.Warn("Delaying every search", "delay", cfg.SearchDelay)
+	}
+
+	// Fail searches fast while the backend keeps failing
+	if cfg.SearchBreakerThreshold > 0 {
+		searcher = NewBreakingSearcher(searcher, "search", cfg.SearchBreakerThreshold, cfg.SearchBreakerCooldown)
+		termSearcher = NewBreakingSearcher(termSearcher, "search-terms", cfg.SearchBreakerThreshold, cfg.SearchBreakerCooldown)
+	}
+
+	// Dependencies reported by the readiness probe
+	var readinessCheckers []ReadinessChecker
+
+	// Cache search results in Redis when configured
+	if cfg.RedisURL != "" {
+		cache, err := NewRedisCache(cfg.RedisURL)
+		if err != nil {
+			fatal("Failed to initialize search cache", "error", err)
+		}
+		defer cache.Close()
+
+		readinessCheckers = append(readinessCheckers, cache)
+
+		searcher = NewCachingSearcher(searcher, cache, "search", cfg.SearchCacheTTL)
+		termSearcher = NewCachingSearcher(termSearcher, cache, "search-terms", cfg.SearchCacheTTL)
+		slog.Info("Caching search results in Redis", "ttl", cfg.SearchCacheTTL)
+	}
+
+	// Identical searches in flight at the same time share one backend call
+	searcher = NewDedupingSearcher(searcher)
+	termSearcher = NewDedupingSearcher(termSearcher)
+
+	// Create the user store, failing fast if the database is unreachable
+	users, closeUsers, err := newUserStore(context.Background(), cfg.DatabaseURL)
+	if err != nil {
+		fatal("Failed to initialize user store", "error", err)
+	}
+	defer closeUsers()
+
+	// Seed an admin so the first admin tokens can be issued by /login
+	if cfg.BootstrapAdminUsername != "" {
+		if err := bootstrapAdmin(context.Background(), users, cfg.BootstrapAdminUsername, cfg.BootstrapAdminPassword, cfg.BcryptCost); err != nil {
+			fatal("Failed to create bootstrap admin", "error", err)
+		}
+	}
+
+	if checker, ok := users.(ReadinessChecker); ok {
+		readinessCheckers = append(readinessCheckers, checker)
+	}
+
+	// Wake long polls on /users/changes whenever a user changes
+	userChanges := NewUserChanges()
+	users = NewNotifyingUserStore(users, userChanges)
+
+	// Upstream services whose health endpoints gate readiness
+	for _, check := range cfg.HTTPChecks {
+		readinessCheckers = append(readinessCheckers, NewHTTPChecker(check))
+		slog.Info("Checking upstream health", "name", check.Name, "url", check.URL, "critical", check.Critical)
+	}
+
+	// Append-only record of user changes
+	auditStore, closeAudit, err := newAuditStore(cfg.AuditLogFile)
+	if err != nil {
+		fatal("Failed to initialize audit log", "error", err)
 	}
+	defer closeAudit()
+	audit := NewAuditLogger(auditStore)
 
-	// Get port from environment variable or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "9000"
+	// Optional IP geolocation database
+	geoIP, err := openGeoIP(cfg.GeoIPDBPath)
+	if err != nil {
+		fatal("Failed to load GeoIP database", "error", err)
+	}
+	if geoIP != nil {
+		defer geoIP.Close()
+	}
+
+	// Deliver user events to the configured webhook in the background
+	var webhooks *WebhookDispatcher
+	if cfg.Webhook.URL != "" {
+		webhooks = NewWebhookDispatcher(cfg.Webhook)
+		slog.Info("Dispatching user events to webhook", "url", cfg.Webhook.URL)
+	}
+
+	// Run slow work such as emails off the request path
+	jobs := NewJobQueue(cfg.JobWorkers, cfg.JobQueueSize)
+
+	// Make sure uploaded files have somewhere to go
+	if err := os.MkdirAll(cfg.Uploads.Dir, 0o755); err != nil {
+		fatal("Failed to create upload directory", "error", err)
+	}
+
+	// Periodic maintenance, started once the server is listening
+	scheduler := NewScheduler()
+	if cfg.Uploads.Retention > 0 {
+		scheduler.RegisterTask("cleanup-uploads", cfg.CleanupInterval, cleanupUploadsTask(cfg.Uploads.Dir, cfg.Uploads.Retention))
+	}
+
+	// Custom binding tags such as "username" must exist before routes bind
+	if err := registerValidators(); err != nil {
+		fatal("Failed to register validators", "error", err)
 	}
 
 	// Set gin mode based on environment
-	ginMode := os.Getenv("GIN_MODE")
-	if ginMode == "release" {
-		gin.SetMode(gin.ReleaseMode)
+	gin.SetMode(cfg.GinMode)
+
+	// Feature flags, reloadable on SIGHUP
+	flags, err := NewFlagStore()
+	if err != nil {
+		fatal("Failed to load feature flags", "error", err)
+	}
+
+	// Create Gin router with request IDs, structured request logs and panic recovery
+	router := gin.New()
+
+	// Only trust X-Forwarded-For from configured proxies; nil trusts none.
+	// ClientIP feeds the rate limiter and IP filter, so log what's in effect.
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		fatal("Invalid TRUSTED_PROXIES", "error", err)
 	}
+	if len(cfg.TrustedProxies) == 0 {
+		slog.Info("No trusted proxies, client IP is the direct peer address")
+	} else {
+		slog.Info("Trusting client IP headers from proxies", "trusted_proxies", cfg.TrustedProxies, "headers", router.RemoteIPHeaders)
+	}
+
+	// Restrict operational endpoints to internal networks
+	internalOnly, err := IPFilter(cfg.InternalAllowCIDRs, cfg.InternalDenyCIDRs)
+	if err != nil {
+		fatal("Invalid internal network ranges", "error", err)
+	}
+
+	// Count in-flight requests first so shutdown sees every one
+	var inFlight atomic.Int64
+	router.Use(InFlight(&inFlight), RequestID(), RequestLogger(accessLogger, cfg.SlowRequestThreshold, func() int {
+		return CurrentConfig().LogSampleRate
+	}), Recovery())
+
+	// Expose the verified client certificate CN when mutual TLS is on
+	if cfg.TLSClientCA != "" {
+		router.Use(ClientCert())
+	}
+
+	// Expose a per-request snapshot of the feature flags
+	router.Use(Flags(flags))
+
+	// Start a span per request named after the route template
+	router.Use(otelgin.Middleware(serviceName), TraceRequestID())
+
+	// Report server-side latency to clients
+	router.Use(ResponseTime())
+
+	// Record Prometheus metrics for every request
+	router.Use(Metrics())
+
+	// Shed load beyond MAX_CONCURRENT_REQUESTS rather than queueing it
+	router.Use(LoadShed(cfg.MaxConcurrentRequests))
+
+	// Answer 503 to everything but liveness while in maintenance mode
+	var maintenance atomic.Bool
+	maintenance.Store(cfg.MaintenanceMode)
+	router.Use(Maintenance(&maintenance))
 
-	// Create Gin router
-	router := gin.Default()
+	// Limit how fast a single client IP can call the API. It's applied per
+	// route below, so probes, admin endpoints and static files are exempt;
+	// every route shares the same per-IP budget.
+	rateLimit := RateLimit(func() (int, int) {
+		current := CurrentConfig()
+		return current.RateLimitRPS, current.RateLimitBurst
+	})
 
 	// Add middleware for CORS (Cross-Origin Resource Sharing)
-	router.Use(func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
+	router.Use(CORS(cfg.CORSAllowedOrigins))
+
+	// Compress large responses for clients that accept gzip
+	router.Use(GzipWithThreshold(cfg.GzipMinSize))
+
+	// Cap request body sizes; uploads get their own, larger limit
+	uploadLimit := cfg.Uploads.MaxBytes + multipartOverhead
+	router.Use(BodyLimit(cfg.MaxBodyBytes, map[string]int64{
+		"/v1/upload": uploadLimit,
+		"/upload":    uploadLimit,
+	}))
+
+	// Log request and response bodies at debug level when asked to, after
+	// decompression and inside the body limit
+	if cfg.LogBodies {
+		router.Use(BodyLogger(accessLogger, cfg.LogBodyMaxBytes))
+		slog.Warn("Logging request and response bodies at debug level", "max_bytes", cfg.LogBodyMaxBytes, "log_level", cfg.LogLevel)
+	}
+
+	// Bound how long any single request may run, except for streams
+	streamingPaths := append([]string{"/events", "/v1/search/stream", "/search/stream", "/v1/search.csv", "/search.csv"}, pprofStreamingPaths...)
+	router.Use(Timeout(cfg.RequestTimeout, streamingPaths...))
+
+	// Health, version and Kubernetes probe endpoints; readiness is held
+	// back until the startup checks below pass
+	var startup StartupGate
+	RegisterRoutes(router, probeRoutes(readinessCheckers, &startup))
+
+	// Operator endpoints, reachable from internal networks with the admin
+	// credentials only
+	if cfg.AdminEnabled() {
+		admin := router.Group(adminPrefix, internalOnly, AdminAuth(cfg.AdminUser, cfg.AdminPassword))
+		RegisterRoutes(admin, adminRoutes(audit))
+
+		// Runtime profiling, only when explicitly enabled
+		if cfg.EnablePprof {
+			registerPprofRoutes(admin.Group("/debug/pprof"))
+			slog.Warn("pprof endpoints enabled", "path", pprofPrefix)
 		}
-		
-		c.Next()
-	})
+	} else {
+		slog.Warn("ADMIN_USER and ADMIN_PASSWORD not set, admin endpoints are disabled", "path", adminPrefix)
+	}
 
-	// Basic ping endpoint - health check
-	router.GET("/ping", func(c *gin.Context) {
-		c.JSON(http.StatusOK, PingResponse{
-			Message: "pong",
-			Status:  "healthy",
-		})
-	})
+	// Server-sent events and long polls; both end when shutdown begins
+	streamCtx, stopStreams := context.WithCancel(context.Background())
 
-	// Enhanced health check endpoint
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, HealthResponse{
-			Service: "Go API with Gin",
-			Status:  "running",
-			Version: "1.0.0",
-		})
-	})
+	// Versioned API, plus the original unversioned paths as deprecated aliases
+	deps := routeDeps{
+		Searcher:           searcher,
+		TermSearcher:       termSearcher,
+		Users:              users,
+		UserChanges:        userChanges,
+		UserChangesTimeout: cfg.UserChangesTimeout,
+		UserBatchMax:       cfg.UserBatchMax,
+		BcryptCost:         cfg.BcryptCost,
+		PostCategories:     cfg.PostCategories,
+		JWTSecret:          cfg.JWTSecret,
+		SessionSecret:      cfg.SessionSecret,
+		Sessions:           NewSessionStore(cfg.SessionTTL),
+		APIKeys:            cfg.APIKeys,
+		Quota:              NewQuotaTracker(cfg.APIKeyMonthlyQuota, cfg.APIKeyQuotaWindow),
+		Uploads:            cfg.Uploads,
+		Webhooks:           webhooks,
+		Jobs:               jobs,
+		Audit:              audit,
+		Idempotency:        newIdempotencyStore(cfg.IdempotencyTTL),
+		GeoIP:              geoIP,
+		SignatureSecret:    cfg.SignatureSecret,
+		SignatureMaxSkew:   cfg.SignatureMaxSkew,
+		Shutdown:           streamCtx,
+	}
+	v1 := v1Routes(deps)
+	RegisterRoutes(router.Group("/v1", rateLimit), v1)
+	RegisterRoutes(router.Group("/", rateLimit, Deprecated()), v1)
+
+	// Real-time WebSocket echo; connections are closed on shutdown
+	wsConnections := newWSHub()
 
-	// Endpoint demonstrating path parameters
-	router.GET("/user/:id", func(c *gin.Context) {
-		userID := c.Param("id")
-		c.JSON(http.StatusOK, gin.H{
-			"user_id": userID,
-			"message": "User retrieved successfully",
-		})
+	RegisterRoutes(router, []RouteDef{
+		// GraphQL over the same stores as REST, with GraphiQL on GET
+		{Methods: []string{http.MethodGet, http.MethodHead, http.MethodPost}, Path: "/graphql", Middleware: chain(rateLimit),
+			Handler: graphQLHandler(deps.Users, deps.Searcher, func() int { return CurrentConfig().SearchMaxLimit })},
+		{Methods: methodsGet, Path: "/ws/echo", Middleware: chain(rateLimit),
+			Handler: wsEchoHandler(wsConnections, newUpgrader(cfg.CORSAllowedOrigins))},
+		{Methods: methodsGet, Path: "/events", Middleware: chain(rateLimit), Handler: eventsHandler(streamCtx, cfg.SSEHeartbeatInterval)},
+
+		// API documentation: Swagger UI and the raw generated spec
+		{Methods: methodsGet, Path: "/swagger/*any", Middleware: chain(rateLimit), Handler: ginSwagger.WrapHandler(swaggerFiles.Handler)},
+		{Methods: methodsGet, Path: "/openapi.json", Middleware: chain(rateLimit), Handler: openAPIHandler},
 	})
 
-	// Endpoint demonstrating query parameters
-	router.GET("/search", func(c *gin.Context) {
-		// Get query parameters
-		query := c.Query("q")           // Required query parameter
-		limit := c.DefaultQuery("limit", "10") // Optional with default
-		page := c.DefaultQuery("page", "1")    // Optional with default
-
-		// Validate required parameter
-		if query == "" {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Query parameter 'q' is required",
-			})
+	// Static assets, registered last so they can't shadow the API
+	if err := registerStaticRoutes(router, cfg.Static); err != nil {
+		fatal("Invalid static file configuration", "error", err)
+	}
+
+	// JSON responses for unknown routes and unsupported methods
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(notFoundHandler)
+	router.NoMethod(methodNotAllowedHandler(router))
+
+	// Create HTTP server with the Gin router as handler and connection
+	// timeouts that guard against slow clients
+	server := newHTTPServer(":"+cfg.Port, router, cfg.ServerTimeouts, streamingPaths)
+	server.RegisterOnShutdown(wsConnections.CloseAll)
+	server.RegisterOnShutdown(stopStreams)
+
+	// Serve TLS from a reloadable certificate so SIGHUP can pick up renewals
+	var certs *CertReloader
+	if cfg.TLSEnabled() {
+		if certs, err = NewCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile); err != nil {
+			fatal("Failed to load TLS certificate", "error", err)
+		}
+		server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.GetCertificate,
+		}
+
+		// Mutual TLS: handshakes without a certificate from a trusted CA fail
+		if cfg.TLSClientCA != "" {
+			clientCAs, err := loadClientCAs(cfg.TLSClientCA)
+			if err != nil {
+				fatal("Failed to load client CAs", "error", err)
+			}
+			server.TLSConfig.ClientCAs = clientCAs
+			server.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+			slog.Info("Requiring client certificates", "ca_file", cfg.TLSClientCA)
+		}
+		slog.Info("Loaded TLS certificate", "subject", certs.Current().Leaf.Subject.String(), "not_after", certs.Current().Leaf.NotAfter)
+	}
+
+	// Bind before serving so a taken port fails fast with a clear message.
+	// With PORT=0 the OS picks the port, so log the one actually bound.
+	listener, port := mustListen(server.Addr, "PORT")
+	scheme := "http"
+	if cfg.TLSEnabled() {
+		scheme = "https"
+	}
+	slog.Info("Server starting", "port", port)
+	slog.Info("Health check available", "url", scheme+"://localhost:"+port+"/ping")
+
+	// Serve in a goroutine so it doesn't block signal handling
+	go func() {
+		var err error
+		if cfg.TLSEnabled() {
+			// The certificate comes from TLSConfig.GetCertificate
+			err = server.ServeTLS(listener, "", "")
+		} else {
+			err = server.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("Failed to start server", "error", err)
+		}
+	}()
+
+	// Report ready once every critical dependency has answered, giving up
+	// after STARTUP_TIMEOUT and shutting down; liveness passes throughout
+	startupFailed := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(streamCtx, cfg.StartupTimeout)
+		defer cancel()
+		if err := waitForDependencies(ctx, readinessCheckers); err != nil {
+			if streamCtx.Err() == nil {
+				startupFailed <- err
+			}
 			return
 		}
+		startup.Open()
+		slog.Info("Startup checks passed, ready for traffic", "elapsed", time.Since(startTime).Round(time.Millisecond))
+	}()
 
-		c.JSON(http.StatusOK, gin.H{
-			"query":   query,
-			"limit":   limit,
-			"page":    page,
-			"results": []string{}, // Placeholder for actual search results
-		})
-	})
+	// Begin scheduled maintenance now that the server is up
+	scheduler.Start()
 
-	// Endpoint combining both path and query parameters
-	router.GET("/user/:id/posts", func(c *gin.Context) {
-		userID := c.Param("id")
-		category := c.DefaultQuery("category", "all")
-		sort := c.DefaultQuery("sort", "date")
-
-		c.JSON(http.StatusOK, gin.H{
-			"user_id":  userID,
-			"category": category,
-			"sort":     sort,
-			"posts":    []string{}, // Placeholder for actual posts
-		})
-	})
+	// Start the HTTP -> HTTPS redirect listener when requested
+	var redirectServer *http.Server
+	if cfg.TLSEnabled() && cfg.TLSRedirectHTTP {
+		redirectServer = &http.Server{
+			Addr:              ":" + cfg.TLSRedirectPort,
+			Handler:           httpsRedirectHandler(port),
+			ReadHeaderTimeout: cfg.ServerTimeouts.ReadHeader,
+		}
+		redirectListener, _ := mustListen(redirectServer.Addr, "TLS_REDIRECT_PORT")
 
-	// Start server
-	log.Printf("Server starting on port %s", port)
-	log.Printf("Health check available at: http://localhost:%s/ping", port)
-	
-	if err := router.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+		go func() {
+			slog.Info("Redirecting HTTP to HTTPS", "port", cfg.TLSRedirectPort)
+			if err := redirectServer.Serve(redirectListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fatal("Failed to start redirect server", "error", err)
+			}
+		}()
 	}
-}
\ No newline at end of file
+
+	// Serve the gRPC mirror of the API on its own port
+	var grpcServer *grpc.Server
+	if cfg.GRPCPort != "" {
+		grpcServer = newGRPCServer(deps.Users, deps.Searcher, func() int { return CurrentConfig().SearchMaxLimit })
+		grpcListener, grpcPort := mustListen(":"+cfg.GRPCPort, "GRPC_PORT")
+
+		go func() {
+			slog.Info("Starting gRPC server", "port", grpcPort)
+			if err := grpcServer.Serve(grpcListener); err != nil {
+				fatal("Failed to start gRPC server", "error", err)
+			}
+		}()
+	}
+
+	// Reload configuration, the TLS certificate and feature flags on SIGHUP,
+	// until shutdown begins. Log level, log sampling, rate limits, maintenance
+	// mode and the search limit apply immediately; other settings need a
+	// restart.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	reloads := &reloader{maintenance: &maintenance, certs: certs, flags: flags}
+	go reloads.watch(hup)
+
+	// Wait for interrupt or termination signal (Ctrl+C, docker stop, kubectl
+	// delete), or for the startup checks to give up
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	select {
+	case <-quit:
+		slog.Info("shutting down gracefully")
+	case err := <-startupFailed:
+		slog.Error("Critical dependency unreachable at startup, shutting down", "timeout", cfg.StartupTimeout, "error", err)
+		exitCode = 1
+	}
+	signal.Stop(hup)
+
+	// Give in-flight requests a deadline to complete
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if redirectServer != nil {
+		if err := redirectServer.Shutdown(ctx); err != nil {
+			slog.Error("Redirect server forced to shutdown", "error", err)
+		}
+	}
+
+	// Let in-flight RPCs finish, cancelling them if the deadline passes
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			slog.Error("gRPC server forced to shutdown", "error", ctx.Err())
+			grpcServer.Stop()
+		}
+	}
+
+	// Stop accepting connections and wait for active requests
+	drainRequests(ctx, server, &inFlight, cfg.ShutdownTimeout)
+
+	// Stop maintenance tasks, then let queued background jobs finish now
+	// that no requests can add more
+	scheduler.Stop()
+	if err := jobs.Shutdown(ctx); err != nil {
+		slog.Error("Background jobs abandoned during shutdown", "error", err)
+	}
+
+	// Deliver webhook events queued before shutdown
+	if err := webhooks.Close(ctx); err != nil {
+		slog.Error("Webhook events dropped during shutdown", "error", err)
+	}
+
+	// Flush any buffered spans
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+
+	slog.Info("Server exited")
+}
+
+// reloader applies a SIGHUP: it re-reads the configuration, then the pieces
+// of state derived from it that can change without a restart
+type reloader struct {
+	maintenance *atomic.Bool
+	// certs is nil when TLS is disabled
+	certs *CertReloader
+	flags *FlagStore
+}
+
+// watch reloads each time a signal arrives on hup, until hup is closed
+func (r *reloader) watch(hup <-chan os.Signal) {
+	for range hup {
+		r.reload()
+	}
+}
+
+// reload re-reads everything, logging and skipping whatever fails to load
+func (r *reloader) reload() {
+	if next, err := ReloadConfig(); err != nil {
+		slog.Error("Config reload failed, keeping previous config", "error", err)
+	} else {
+		logLevel.Set(parseLogLevel(next.LogLevel))
+		r.maintenance.Store(next.MaintenanceMode)
+		slog.Info("Reloaded configuration")
+	}
+
+	if r.certs != nil {
+		if err := r.certs.Reload(); err != nil {
+			slog.Error("TLS certificate reload failed, keeping previous certificate", "error", err)
+		} else {
+			slog.Info("Reloaded TLS certificate", "subject", r.certs.Current().Leaf.Subject.String(), "not_after", r.certs.Current().Leaf.NotAfter)
+		}
+	}
+
+	if err := r.flags.Reload(); err != nil {
+		slog.Error("Feature flag reload failed, keeping previous flags", "error", err)
+		return
+	}
+	slog.Info("Reloaded feature flags", "flags", r.flags.Load())
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+	if err := registerValidators(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maintenanceRetryAfter is how long clients are told to wait during maintenance
+const maintenanceRetryAfter = 30 * time.Second
+
+// Maintenance returns a middleware that rejects every request except the
+// liveness probe with 503 while enabled is set. enabled may be flipped at
+// runtime to enter or leave maintenance without a restart.
+func Maintenance(enabled *atomic.Bool) gin.HandlerFunc {
+	retryAfter := strconv.Itoa(int(maintenanceRetryAfter.Seconds()))
+
+	return func(c *gin.Context) {
+		// Keep liveness reachable so orchestrators don't restart the pod
+		if !enabled.Load() || c.Request.URL.Path == "/healthz" {
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", retryAfter)
+		RespondError(c, http.StatusServiceUnavailable, CodeUnavailable, "service under maintenance")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMaintenanceToggle(t *testing.T) {
+	var enabled atomic.Bool
+	r := gin.New()
+	r.Use(Maintenance(&enabled))
+	r.GET("/ping", pingHandler)
+	r.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	if w := get(r, "/ping"); w.Code != http.StatusOK {
+		t.Fatalf("disabled: got %d", w.Code)
+	}
+
+	enabled.Store(true)
+	w := get(r, "/ping")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("enabled: got %d, want 503", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Fatalf("Retry-After is %q", got)
+	}
+	var body APIError
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Message != "service under maintenance" || body.Code != CodeUnavailable {
+		t.Fatalf("body is %+v", body)
+	}
+	if w := get(r, "/healthz"); w.Code != http.StatusOK {
+		t.Fatalf("liveness during maintenance: got %d", w.Code)
+	}
+
+	enabled.Store(false)
+	if w := get(r, "/ping"); w.Code != http.StatusOK {
+		t.Fatalf("after maintenance: got %d", w.Code)
+	}
+}
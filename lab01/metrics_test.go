@@ -0,0 +1,206 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// metricsRouter serves /ping, /user/:id, a panicking /boom and /metrics
+// behind the recovery and metrics middleware
+func metricsRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(Recovery(), Metrics())
+	r.GET("/ping", pingHandler)
+	r.GET("/user/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.GET("/boom", func(c *gin.Context) { panic("boom") })
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	return r
+}
+
+func TestMetricsCountPing(t *testing.T) {
+	r := metricsRouter()
+	counter := httpRequestsTotal.WithLabelValues(http.MethodGet, "/ping", "200")
+	before := testutil.ToFloat64(counter)
+
+	if w := get(r, "/ping"); w.Code != http.StatusOK {
+		t.Fatalf("ping: got %d", w.Code)
+	}
+	if got := testutil.ToFloat64(counter); got != before+1 {
+		t.Fatalf("counter is %v, want %v", got, before+1)
+	}
+
+	w := get(r, "/metrics")
+	if w.Code != http.StatusOK {
+		t.Fatalf("metrics: got %d", w.Code)
+	}
+	for _, want := range []string{
+		`http_requests_total{method="GET",path="/ping",status="200"}`,
+		`http_request_duration_seconds_count{route="/ping",status_class="2xx"}`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("scrape is missing %s", want)
+		}
+	}
+}
+
+func TestMetricsUseRouteTemplate(t *testing.T) {
+	r := metricsRouter()
+	counter := httpRequestsTotal.WithLabelValues(http.MethodGet, "/user/:id", "204")
+	before := testutil.ToFloat64(counter)
+
+	for _, target := range []string{"/user/1", "/user/2", "/user/3"} {
+		get(r, target)
+	}
+	if got := testutil.ToFloat64(counter); got != before+3 {
+		t.Fatalf("counter is %v, want %v", got, before+3)
+	}
+
+	body := get(r, "/metrics").Body.String()
+	if strings.Contains(body, `path="/user/1"`) {
+		t.Fatal("raw path used as a label")
+	}
+
+	unmatched := httpRequestsTotal.WithLabelValues(http.MethodGet, "unmatched", "404")
+	before = testutil.ToFloat64(unmatched)
+	get(r, "/nowhere/42")
+	if got := testutil.ToFloat64(unmatched); got != before+1 {
+		t.Fatalf("unmatched counter is %v, want %v", got, before+1)
+	}
+}
+
+func TestMetricsCountPanicAsServerError(t *testing.T) {
+	r := metricsRouter()
+	counter := httpRequestsTotal.WithLabelValues(http.MethodGet, "/boom", "500")
+	before := testutil.ToFloat64(counter)
+
+	if w := get(r, "/boom"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("got %d, want 500", w.Code)
+	}
+	if got := testutil.ToFloat64(counter); got != before+1 {
+		t.Fatalf("counter is %v, want %v", got, before+1)
+	}
+	if got := testutil.ToFloat64(httpRequestsInFlight.WithLabelValues("/boom")); got != 0 {
+		t.Fatalf("in-flight gauge is %v after the panic", got)
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	for status, want := range map[int]string{101: "1xx", 200: "2xx", 304: "3xx", 429: "4xx", 503: "5xx"} {
+		if got := statusClass(status); got != want {
+			t.Errorf("statusClass(%d) = %s, want %s", status, got, want)
+		}
+	}
+}
+
+// scrapedValue returns the value of the sample named series in a scrape of
+// /metrics on r, or 0 if it's absent
+func scrapedValue(t *testing.T, r http.Handler, series string) float64 {
+	t.Helper()
+	for line := range strings.Lines(get(r, "/metrics").Body.String()) {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), series+" "); ok {
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatalf("%s has value %q", series, value)
+			}
+			return n
+		}
+	}
+	return 0
+}
+
+func TestRequestDurationHistogram(t *testing.T) {
+	r := metricsRouter()
+	r.GET("/slow", func(c *gin.Context) {
+		time.Sleep(15 * time.Millisecond)
+		c.Status(http.StatusTeapot)
+	})
+
+	bucket := func(le string) float64 {
+		return scrapedValue(t, r, `http_request_duration_seconds_bucket{route="/slow",status_class="4xx",le="`+le+`"}`)
+	}
+	const count = `http_request_duration_seconds_count{route="/slow",status_class="4xx"}`
+	before, fastBefore, slowBefore := scrapedValue(t, r, count), bucket("0.01"), bucket("1")
+
+	get(r, "/slow")
+	get(r, "/slow")
+	if got := scrapedValue(t, r, count); got != before+2 {
+		t.Fatalf("sample count is %v, want %v", got, before+2)
+	}
+	// Both took over 10ms, so they land above the 10ms bucket
+	if bucket("0.01") != fastBefore || bucket("1") != slowBefore+2 {
+		t.Fatalf("10ms bucket holds %v, 1s bucket %v", bucket("0.01"), bucket("1"))
+	}
+	for _, le := range []string{"0.001", "0.005", "0.01", "0.05", "0.1", "0.5", "1", "+Inf"} {
+		if !strings.Contains(get(r, "/metrics").Body.String(), `route="/slow",status_class="4xx",le="`+le+`"`) {
+			t.Errorf("no %s bucket", le)
+		}
+	}
+}
+
+// waitForActiveConnections polls until the active_connections gauge for kind
+// reads n
+func waitForActiveConnections(t *testing.T, kind string, n float64) {
+	t.Helper()
+	gauge := activeConnections.WithLabelValues(kind)
+	deadline := time.Now().Add(2 * time.Second)
+	for testutil.ToFloat64(gauge) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s gauge is %v, want %v", kind, testutil.ToFloat64(gauge), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRequestsInFlightByRoute(t *testing.T) {
+	release := make(chan struct{})
+	r := gin.New()
+	r.Use(Recovery(), Metrics())
+	r.GET("/slow/:id", func(c *gin.Context) {
+		<-release
+		c.Status(http.StatusOK)
+	})
+	r.GET("/boom", func(c *gin.Context) { panic("boom") })
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	slow := httpRequestsInFlight.WithLabelValues("/slow/:id")
+	done := make(chan struct{})
+	go func() {
+		get(r, "/slow/1")
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for testutil.ToFloat64(slow) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("in-flight gauge for /slow/:id is %v while a request is stuck, want 1", testutil.ToFloat64(slow))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := scrapedValue(t, r, `http_requests_in_flight{route="/slow/:id"}`); got != 1 {
+		t.Fatalf("scraped in-flight for /slow/:id is %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(httpRequestsInFlight.WithLabelValues("/boom")); got != 0 {
+		t.Fatalf("idle /boom route has %v in flight", got)
+	}
+
+	close(release)
+	<-done
+	if got := testutil.ToFloat64(slow); got != 0 {
+		t.Fatalf("finished request left the gauge at %v", got)
+	}
+
+	// A panicking handler still leaves the gauge
+	if w := get(r, "/boom"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("/boom: status %d", w.Code)
+	}
+	if got := testutil.ToFloat64(httpRequestsInFlight.WithLabelValues("/boom")); got != 0 {
+		t.Fatalf("panicking request left the gauge at %v", got)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// notFoundHandler responds to requests for routes that don't exist
+func notFoundHandler(c *gin.Context) {
+	RespondErrorWithDetails(c, http.StatusNotFound, CodeNotFound, "not found", map[string]any{
+		"path": c.Request.URL.Path,
+	})
+}
+
+// methodNotAllowedHandler responds to requests using the wrong method for an
+// existing path, listing the valid methods in the Allow header
+func methodNotAllowedHandler(router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var methods []string
+		for _, route := range router.Routes() {
+			if routeMatches(route.Path, c.Request.URL.Path) && !slices.Contains(methods, route.Method) {
+				methods = append(methods, route.Method)
+			}
+		}
+		slices.Sort(methods)
+
+		c.Header("Allow", strings.Join(methods, ", "))
+		RespondError(c, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "method not allowed")
+	}
+}
+
+// routeMatches reports whether path matches a Gin route pattern such as
+// /user/:id or /swagger/*any
+func routeMatches(pattern, path string) bool {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "*") {
+			return true
+		}
+		if i >= len(pathParts) {
+			return false
+		}
+		if !strings.HasPrefix(part, ":") && part != pathParts[i] {
+			return false
+		}
+	}
+
+	return len(patternParts) == len(pathParts)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// notFoundRouter serves /ping and /user/:id with the JSON 404 and 405
+// handlers installed as main does
+func notFoundRouter() *gin.Engine {
+	r := gin.New()
+	r.HandleMethodNotAllowed = true
+	r.NoRoute(notFoundHandler)
+	r.NoMethod(methodNotAllowedHandler(r))
+	r.GET("/ping", pingHandler)
+	r.HEAD("/ping", pingHandler)
+	r.GET("/user/:id", func(c *gin.Context) {})
+	r.PUT("/user/:id", func(c *gin.Context) {})
+	return r
+}
+
+func TestNotFoundHandler(t *testing.T) {
+	w := get(notFoundRouter(), "/nowhere/42")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("got %d, want 404", w.Code)
+	}
+
+	var body APIError
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Message != "not found" || body.Code != CodeNotFound || body.Details["path"] != "/nowhere/42" {
+		t.Fatalf("body is %+v", body)
+	}
+}
+
+func TestMethodNotAllowedHandler(t *testing.T) {
+	tests := []struct {
+		method, target, allow string
+	}{
+		{http.MethodPost, "/ping", "GET, HEAD"},
+		{http.MethodDelete, "/user/7", "GET, PUT"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		notFoundRouter().ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("%s %s: got %d, want 405", tt.method, tt.target, w.Code)
+		}
+		if got := w.Header().Get("Allow"); got != tt.allow {
+			t.Errorf("%s %s: Allow is %q, want %q", tt.method, tt.target, got, tt.allow)
+		}
+
+		var body APIError
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Message != "method not allowed" || body.Code != CodeMethodNotAllowed {
+			t.Fatalf("body is %+v", body)
+		}
+	}
+}
+
+func TestRouteMatches(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"/ping", "/ping", true},
+		{"/ping", "/pong", false},
+		{"/user/:id", "/user/7", true},
+		{"/user/:id", "/user", false},
+		{"/user/:id", "/user/7/posts", false},
+		{"/swagger/*any", "/swagger/index.html", true},
+		{"/swagger/*any", "/swagger/", true},
+		{"/v1/users", "/v1/users/", true},
+	}
+	for _, tt := range tests {
+		if got := routeMatches(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("routeMatches(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
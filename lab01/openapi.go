@@ -0,0 +1,19 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swaggo/swag"
+)
+
+// openAPIHandler serves the raw API specification generated by swag
+func openAPIHandler(c *gin.Context) {
+	doc, err := swag.ReadDoc()
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, CodeInternal, "API specification unavailable")
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(doc))
+}
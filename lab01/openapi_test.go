@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPISpec is the part of the served specification the tests look at
+type openAPISpec struct {
+	Paths map[string]map[string]struct {
+		Parameters []struct {
+			Name string `json:"name"`
+			In   string `json:"in"`
+		} `json:"parameters"`
+	} `json:"paths"`
+}
+
+func TestOpenAPIDocumentsTimezoneOnUserEndpoints(t *testing.T) {
+	r := gin.New()
+	r.GET("/openapi.json", openAPIHandler)
+	w := get(r, "/openapi.json")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d", w.Code)
+	}
+	var spec openAPISpec
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every user endpoint that returns users formats their timestamps in tz
+	var checked int
+	for path, operations := range spec.Paths {
+		if !strings.HasPrefix(path, "/v1/user") || strings.HasSuffix(path, "/posts") {
+			continue
+		}
+		for method, op := range operations {
+			if method == "delete" {
+				continue
+			}
+			checked++
+			var documented bool
+			for _, param := range op.Parameters {
+				documented = documented || param.Name == "tz" && param.In == "query"
+			}
+			if !documented {
+				t.Errorf("%s %s doesn't document the tz query parameter", strings.ToUpper(method), path)
+			}
+		}
+	}
+	if checked == 0 {
+		t.Fatal("no user endpoints in the specification")
+	}
+}
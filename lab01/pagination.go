@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultPageLimit is the page size used when the client doesn't pass limit
+const defaultPageLimit = 10
+
+// maxPageOffset bounds how deep page-based pagination goes, so (page-1)*limit
+// can't overflow; cursors page further
+const maxPageOffset = math.MaxInt32
+
+// Pagination holds validated page-based pagination parameters
+type Pagination struct {
+	Limit  int `json:"limit"`
+	Page   int `json:"page"`
+	Offset int `json:"offset"`
+}
+
+// parsePagination reads the limit and page query parameters, rejecting
+// non-numeric or non-positive values and pages past maxPageOffset, and
+// capping limit at maxLimit
+func parsePagination(c *gin.Context, maxLimit int) (Pagination, error) {
+	limit, err := positiveQueryInt(c, "limit", defaultPageLimit)
+	if err != nil {
+		return Pagination{}, err
+	}
+	page, err := positiveQueryInt(c, "page", 1)
+	if err != nil {
+		return Pagination{}, err
+	}
+
+	limit = min(limit, maxLimit)
+	if page-1 > maxPageOffset/limit {
+		return Pagination{}, fmt.Errorf("Query parameter 'page' must be at most %d", maxPageOffset/limit+1)
+	}
+
+	return Pagination{
+		Limit:  limit,
+		Page:   page,
+		Offset: (page - 1) * limit,
+	}, nil
+}
+
+// errInvalidCursor is returned when a cursor wasn't produced by encodeCursor
+var errInvalidCursor = errors.New("Query parameter 'cursor' is invalid")
+
+// encodeCursor returns an opaque cursor resuming after the result with id
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// decodeCursor returns the result ID encoded in cursor. No result can follow
+// math.MaxInt64, so a cursor for it is invalid too.
+func decodeCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errInvalidCursor
+	}
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id < 0 || id == math.MaxInt64 {
+		return 0, errInvalidCursor
+	}
+	return id, nil
+}
+
+// positiveQueryInt parses an optional query parameter as an integer >= 1
+func positiveQueryInt(c *gin.Context, name string, def int) (int, error) {
+	raw, ok := c.GetQuery(name)
+	if !ok {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("Query parameter '%s' must be an integer", name)
+	}
+	if n < 1 {
+		return 0, fmt.Errorf("Query parameter '%s' must be at least 1", name)
+	}
+	return n, nil
+}
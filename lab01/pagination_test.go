@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// queryContext returns a Gin context for a GET request with query
+func queryContext(query string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/?"+query, nil)
+	return c
+}
+
+func TestParsePagination(t *testing.T) {
+	lastPage := strconv.Itoa(maxPageOffset/100 + 1)
+
+	tests := []struct {
+		name    string
+		query   string
+		want    Pagination
+		wantErr string
+	}{
+		{name: "defaults", query: "", want: Pagination{Limit: 10, Page: 1, Offset: 0}},
+		{name: "explicit", query: "limit=20&page=3", want: Pagination{Limit: 20, Page: 3, Offset: 40}},
+		{name: "limit one", query: "limit=1&page=1", want: Pagination{Limit: 1, Page: 1, Offset: 0}},
+		{name: "limit at max", query: "limit=100", want: Pagination{Limit: 100, Page: 1, Offset: 0}},
+		{name: "limit capped", query: "limit=101&page=2", want: Pagination{Limit: 100, Page: 2, Offset: 100}},
+		{name: "deepest page", query: "limit=100&page=" + lastPage, want: Pagination{Limit: 100, Page: maxPageOffset/100 + 1, Offset: maxPageOffset / 100 * 100}},
+
+		{name: "non-numeric limit", query: "limit=abc", wantErr: "Query parameter 'limit' must be an integer"},
+		{name: "non-numeric page", query: "page=x", wantErr: "Query parameter 'page' must be an integer"},
+		{name: "fractional limit", query: "limit=1.5", wantErr: "Query parameter 'limit' must be an integer"},
+		{name: "empty limit", query: "limit=", wantErr: "Query parameter 'limit' must be an integer"},
+		{name: "zero limit", query: "limit=0", wantErr: "Query parameter 'limit' must be at least 1"},
+		{name: "negative limit", query: "limit=-5", wantErr: "Query parameter 'limit' must be at least 1"},
+		{name: "zero page", query: "page=0", wantErr: "Query parameter 'page' must be at least 1"},
+		{name: "negative page", query: "page=-1", wantErr: "Query parameter 'page' must be at least 1"},
+		{name: "page past max", query: "limit=100&page=" + strconv.Itoa(maxPageOffset/100+2), wantErr: "Query parameter 'page' must be at most " + lastPage},
+		{name: "page overflowing offset", query: "limit=10&page=" + strconv.Itoa(math.MaxInt), wantErr: "Query parameter 'page' must be at most " + strconv.Itoa(maxPageOffset/10+1)},
+		{name: "page out of int range", query: "page=99999999999999999999", wantErr: "Query parameter 'page' must be an integer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePagination(queryContext(tt.query), 100)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			if got.Offset < 0 {
+				t.Fatalf("negative offset %d", got.Offset)
+			}
+		})
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	for _, id := range []int64{0, 1, 42, math.MaxInt64 - 1} {
+		got, err := decodeCursor(encodeCursor(id))
+		if err != nil || got != id {
+			t.Errorf("round trip of %d: got %d, %v", id, got, err)
+		}
+	}
+
+	for _, cursor := range []string{
+		"",
+		"not base64!",
+		encodeCursor(-1),
+		encodeCursor(math.MaxInt64),
+		"OTIyMzM3MjAzNjg1NDc3NTgwOA", // 9223372036854775808
+		"YWJj",                       // abc
+	} {
+		if _, err := decodeCursor(cursor); err != errInvalidCursor {
+			t.Errorf("decodeCursor(%q) = %v, want errInvalidCursor", cursor, err)
+		}
+	}
+}
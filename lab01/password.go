@@ -0,0 +1,27 @@
+package main
+
+import (
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxPasswordBytes is bcrypt's input limit. It counts bytes, so a password
+// of non-ASCII characters can pass a max=72 binding tag and still exceed it.
+const maxPasswordBytes = 72
+
+// HashPassword returns the bcrypt hash of plaintext at cost. Passwords longer
+// than maxPasswordBytes fail with bcrypt.ErrPasswordTooLong rather than being
+// silently truncated.
+func HashPassword(plaintext string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plaintext), cost)
+	return string(hash), err
+}
+
+// VerifyPassword reports whether plaintext is user's password. Users created
+// without a password never match, and neither does a plaintext too long to
+// have been hashed.
+func VerifyPassword(user User, plaintext string) bool {
+	if user.PasswordHash == "" || len(plaintext) > maxPasswordBytes {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(plaintext)) == nil
+}
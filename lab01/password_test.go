@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashPasswordVerifies(t *testing.T) {
+	hash, err := HashPassword("correct-horse", bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == "correct-horse" || !strings.HasPrefix(hash, "$2a$") {
+		t.Fatalf("got %q, want a bcrypt hash", hash)
+	}
+
+	user := User{PasswordHash: hash}
+	if !VerifyPassword(user, "correct-horse") {
+		t.Fatal("correct password rejected")
+	}
+	for _, wrong := range []string{"", "correct-hors", "correct-horse ", "CORRECT-HORSE"} {
+		if VerifyPassword(user, wrong) {
+			t.Fatalf("wrong password %q accepted", wrong)
+		}
+	}
+}
+
+func TestHashPasswordSaltsEachHash(t *testing.T) {
+	first, _ := HashPassword("correct-horse", bcrypt.MinCost)
+	second, _ := HashPassword("correct-horse", bcrypt.MinCost)
+	if first == second {
+		t.Fatal("hashing twice gave the same hash")
+	}
+}
+
+func TestHashPasswordCost(t *testing.T) {
+	for _, cost := range []int{bcrypt.MinCost, bcrypt.MinCost + 2} {
+		hash, err := HashPassword("correct-horse", cost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := bcrypt.Cost([]byte(hash))
+		if err != nil || got != cost {
+			t.Fatalf("hash cost %d (%v), want %d", got, err, cost)
+		}
+	}
+}
+
+func TestHashPasswordLengthLimit(t *testing.T) {
+	if _, err := HashPassword(strings.Repeat("a", maxPasswordBytes), bcrypt.MinCost); err != nil {
+		t.Fatalf("%d bytes: %v", maxPasswordBytes, err)
+	}
+	if _, err := HashPassword(strings.Repeat("a", maxPasswordBytes+1), bcrypt.MinCost); !errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		t.Fatalf("%d bytes: got %v, want ErrPasswordTooLong", maxPasswordBytes+1, err)
+	}
+}
+
+func TestVerifyPasswordWithoutHash(t *testing.T) {
+	if VerifyPassword(User{}, "") || VerifyPassword(User{}, "anything") {
+		t.Fatal("user without a password matched")
+	}
+}
+
+func TestVerifyPasswordTooLong(t *testing.T) {
+	password := strings.Repeat("a", maxPasswordBytes)
+	hash, _ := HashPassword(password, bcrypt.MinCost)
+	user := User{PasswordHash: hash}
+	if VerifyPassword(user, password+"a") {
+		t.Fatal("password past the byte limit matched the hash of its prefix")
+	}
+}
+
+// createUserRouter serves POST /user into store at the cheapest cost
+func createUserRouter(t *testing.T, store UserStore) *gin.Engine {
+	jobs := NewJobQueue(1, 10)
+	t.Cleanup(func() { jobs.Shutdown(context.Background()) })
+
+	r := gin.New()
+	r.POST("/user", createUserHandler(store, nil, jobs, NewAuditLogger(&MemoryAuditStore{}), bcrypt.MinCost))
+	return r
+}
+
+func TestCreateUserPasswordLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		want     int
+	}{
+		{"none", "", http.StatusCreated},
+		{"eight bytes", "abcdefgh", http.StatusCreated},
+		{"too short", "abcdefg", http.StatusBadRequest},
+		{"72 bytes", strings.Repeat("a", 72), http.StatusCreated},
+		{"73 bytes", strings.Repeat("a", 73), http.StatusBadRequest},
+		// 36 characters, within max=72, but 72 bytes
+		{"72 bytes of two-byte characters", strings.Repeat("é", 36), http.StatusCreated},
+		// 37 characters, within max=72, but 74 bytes
+		{"74 bytes of two-byte characters", strings.Repeat("é", 37), http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryUserStore()
+			body, _ := json.Marshal(map[string]string{
+				"username": "user" + string(rune('a'+i)),
+				"name":     "User",
+				"email":    "user@example.com",
+				"password": tt.password,
+			})
+			w := post(createUserRouter(t, store), "/user", string(body))
+			if w.Code != tt.want {
+				t.Fatalf("got %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusBadRequest {
+				return
+			}
+
+			var resp APIError
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := resp.Details["password"]; !ok {
+				t.Fatalf("details %v don't name the password", resp.Details)
+			}
+			if _, total, _ := store.List(context.Background(), UserListOptions{Limit: 10}); total != 0 {
+				t.Fatal("rejected user was stored")
+			}
+		})
+	}
+}
+
+func TestCreateUserStoresOnlyTheHash(t *testing.T) {
+	store := NewMemoryUserStore()
+	w := post(createUserRouter(t, store), "/user",
+		`{"username":"alice","name":"Alice","email":"alice@example.com","password":"correct-horse"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "correct-horse") || strings.Contains(w.Body.String(), "$2a$") {
+		t.Fatalf("response leaks the password or hash: %s", w.Body)
+	}
+
+	user, err := store.GetByUsername(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyPassword(user, "correct-horse") {
+		t.Fatal("stored hash doesn't verify")
+	}
+	if cost, _ := bcrypt.Cost([]byte(user.PasswordHash)); cost != bcrypt.MinCost {
+		t.Fatalf("hashed at cost %d, want %d", cost, bcrypt.MinCost)
+	}
+}
+
+func TestCreateUserRejectsTakenUsername(t *testing.T) {
+	r := createUserRouter(t, NewMemoryUserStore())
+	body := `{"username":"alice","name":"Alice","email":"alice@example.com"}`
+	if w := post(r, "/user", body); w.Code != http.StatusCreated {
+		t.Fatalf("first: got %d: %s", w.Code, w.Body)
+	}
+	if w := post(r, "/user", body); w.Code != http.StatusConflict {
+		t.Fatalf("second: got %d, want 409: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrationFiles holds the SQL migrations applied at startup, in file name order
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// dbConnectTimeout bounds the startup connection check, which makes up to
+// dbConnectAttempts attempts
+const (
+	dbConnectTimeout  = 5 * time.Second
+	dbConnectAttempts = 5
+)
+
+// PostgresUserStore is a UserStore backed by PostgreSQL
+type PostgresUserStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresUserStore connects to databaseURL, verifies the connection and
+// applies pending migrations
+func NewPostgresUserStore(ctx context.Context, databaseURL string) (*PostgresUserStore, error) {
+	pool, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse DATABASE_URL: %w", err)
+	}
+
+	// The database may still be starting, as under docker compose, so keep
+	// trying until the connect timeout
+	pingCtx, cancel := context.WithTimeout(ctx, dbConnectTimeout)
+	defer cancel()
+	if err := Retry(pingCtx, dbConnectAttempts, pool.Ping); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("connect to database: %w", err)
+	}
+
+	if err := migrate(ctx, pool); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("migrate database: %w", err)
+	}
+
+	return &PostgresUserStore{pool: pool}, nil
+}
+
+// Close releases all pooled connections
+func (s *PostgresUserStore) Close() {
+	s.pool.Close()
+}
+
+// Name identifies the store in readiness responses
+func (s *PostgresUserStore) Name() string {
+	return "database"
+}
+
+// Check reports whether the database is reachable
+func (s *PostgresUserStore) Check(ctx context.Context) error {
+	return s.pool.Ping(ctx)
+}
+
+// Critical reports that the service can't work without the database
+func (s *PostgresUserStore) Critical() bool {
+	return true
+}
+
+// userColumns are selected, in scanUser order, by every user query
+const userColumns = `id, username, name, email, phone, role, password_hash, version, created_at, updated_at, deleted_at`
+
+// scanUser reads a row selected with userColumns
+func scanUser(row pgx.Row) (User, error) {
+	var user User
+	err := row.Scan(&user.ID, &user.Username, &user.Name, &user.Email, &user.Phone, &user.Role, &user.PasswordHash, &user.Version, &user.CreatedAt, &user.UpdatedAt, &user.DeletedAt)
+	return user, err
+}
+
+func (s *PostgresUserStore) Create(ctx context.Context, user User) (User, error) {
+	created, err := scanUser(s.pool.QueryRow(ctx,
+		`INSERT INTO users (username, name, email, phone, role, password_hash)
+		 VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5, ''), 'user'), $6)
+		 RETURNING `+userColumns,
+		user.Username, user.Name, user.Email, user.Phone, user.Role, user.PasswordHash,
+	))
+	return created, usernameError(err)
+}
+
+// uniqueViolation is the SQLSTATE for a unique constraint violation
+const uniqueViolation = "23505"
+
+// usernameError maps a violation of the unique index on live usernames to
+// ErrUsernameTaken
+func usernameError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == "users_username_live_key" {
+		return ErrUsernameTaken
+	}
+	return err
+}
+
+func (s *PostgresUserStore) Get(ctx context.Context, id int64) (User, error) {
+	user, err := scanUser(s.pool.QueryRow(ctx,
+		`SELECT `+userColumns+` FROM users WHERE id = $1`,
+		id,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return User{}, ErrUserNotFound
+	}
+	return user, err
+}
+
+func (s *PostgresUserStore) GetByUsername(ctx context.Context, username string) (User, error) {
+	user, err := scanUser(s.pool.QueryRow(ctx,
+		`SELECT `+userColumns+` FROM users WHERE username = $1 AND deleted_at IS NULL`,
+		username,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return User{}, ErrUserNotFound
+	}
+	return user, err
+}
+
+func (s *PostgresUserStore) GetMany(ctx context.Context, ids []int64) (map[int64]User, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT `+userColumns+` FROM users WHERE id = ANY($1) AND deleted_at IS NULL`,
+		ids,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make(map[int64]User, len(ids))
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users[user.ID] = user
+	}
+	return users, rows.Err()
+}
+
+// userSortColumns maps each of userSortFields to its column, so ORDER BY is
+// never built from client input
+var userSortColumns = map[string]string{
+	"name":       "name",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// likeEscaper escapes LIKE wildcards so a name filter matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (s *PostgresUserStore) List(ctx context.Context, opts UserListOptions) ([]User, int, error) {
+	column, ok := userSortColumns[opts.SortBy]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown sort field %q", opts.SortBy)
+	}
+	direction := "ASC"
+	if opts.Descending {
+		direction = "DESC"
+	}
+
+	where := `name ILIKE '%' || $1 || '%' AND ($2 OR deleted_at IS NULL) AND ($3::timestamptz IS NULL OR updated_at > $3)`
+	pattern := likeEscaper.Replace(opts.Name)
+	var updatedAfter *time.Time
+	if !opts.UpdatedAfter.IsZero() {
+		updatedAfter = &opts.UpdatedAfter
+	}
+
+	var total int
+	if err := s.pool.QueryRow(ctx,
+		`SELECT count(*) FROM users WHERE `+where,
+		pattern, opts.IncludeDeleted, updatedAfter,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.pool.Query(ctx,
+		`SELECT `+userColumns+` FROM users WHERE `+where+
+			` ORDER BY `+column+` `+direction+`, id `+direction+` LIMIT $4 OFFSET $5`,
+		pattern, opts.IncludeDeleted, updatedAfter, opts.Limit, opts.Offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
+
+func (s *PostgresUserStore) LastModified(ctx context.Context) (time.Time, error) {
+	// Deletes are soft and bump updated_at, so the maximum covers them too
+	var lastModified *time.Time
+	if err := s.pool.QueryRow(ctx, `SELECT max(updated_at) FROM users`).Scan(&lastModified); err != nil {
+		return time.Time{}, err
+	}
+	if lastModified == nil {
+		return time.Time{}, nil
+	}
+	return *lastModified, nil
+}
+
+func (s *PostgresUserStore) Update(ctx context.Context, user User) (User, error) {
+	// The version check and increment happen in one statement so concurrent
+	// updates can't both succeed
+	updated, err := scanUser(s.pool.QueryRow(ctx,
+		`UPDATE users SET name = $2, email = $3, phone = $4, version = version + 1, updated_at = now()
+		 WHERE id = $1 AND deleted_at IS NULL AND ($5 = 0 OR version = $5)
+		 RETURNING `+userColumns,
+		user.ID, user.Name, user.Email, user.Phone, user.Version,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Distinguish a missing user from a stale version
+		existing, err := s.Get(ctx, user.ID)
+		if err != nil {
+			return User{}, err
+		}
+		if existing.DeletedAt != nil {
+			return User{}, ErrUserNotFound
+		}
+		return User{}, ErrVersionConflict
+	}
+	return updated, err
+}
+
+func (s *PostgresUserStore) Delete(ctx context.Context, id int64) error {
+	tag, err := s.pool.Exec(ctx,
+		`UPDATE users SET deleted_at = now(), version = version + 1, updated_at = now()
+		 WHERE id = $1 AND deleted_at IS NULL`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *PostgresUserStore) Restore(ctx context.Context, id int64) (User, error) {
+	// Restoring a user that isn't deleted leaves it unchanged
+	user, err := scanUser(s.pool.QueryRow(ctx,
+		`UPDATE users SET deleted_at = NULL, version = version + 1, updated_at = now()
+		 WHERE id = $1 AND deleted_at IS NOT NULL
+		 RETURNING `+userColumns,
+		id,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s.Get(ctx, id)
+	}
+	return user, usernameError(err)
+}
+
+// migrate applies embedded migrations that haven't been recorded yet
+func migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	if _, err := pool.Exec(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    TEXT PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`,
+	); err != nil {
+		return err
+	}
+
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var applied bool
+		if err := pool.QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, name,
+		).Scan(&applied); err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+
+		sql, err := migrationFiles.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		// Apply each migration and record it atomically
+		err = pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, string(sql)); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, name)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		slog.Info("Applied migration", "name", name)
+	}
+
+	return nil
+}
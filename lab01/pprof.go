@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http/pprof"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pprofPrefix is where runtime profiling endpoints are mounted, under the
+// admin group
+const pprofPrefix = adminPrefix + "/debug/pprof"
+
+// pprofStreamingPaths are profiling routes that sample for a caller-chosen
+// duration and so must be exempt from the request timeout
+var pprofStreamingPaths = []string{pprofPrefix + "/profile", pprofPrefix + "/trace"}
+
+// registerPprofRoutes mounts the net/http/pprof handlers on rg
+func registerPprofRoutes(rg *gin.RouterGroup) {
+	rg.GET("/", gin.WrapF(pprof.Index))
+	rg.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+	rg.GET("/profile", gin.WrapF(pprof.Profile))
+	rg.GET("/symbol", gin.WrapF(pprof.Symbol))
+	rg.POST("/symbol", gin.WrapF(pprof.Symbol))
+	rg.GET("/trace", gin.WrapF(pprof.Trace))
+
+	// Named profiles: heap, goroutine, allocs, block, mutex, threadcreate
+	rg.GET("/:name", func(c *gin.Context) {
+		pprof.Handler(c.Param("name")).ServeHTTP(c.Writer, c.Request)
+	})
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// quotaBuckets is how many slices a quota window is counted in. A request
+// leaves the window with the rest of its slice, at most window/quotaBuckets
+// late.
+const quotaBuckets = 720
+
+// Usage is an API client's request count in the current quota window
+type Usage struct {
+	Client string `json:"client"`
+	Used   int    `json:"used"`
+	// Limit and Remaining are 0 when the quota is unlimited
+	Limit     int `json:"limit"`
+	Remaining int `json:"remaining"`
+	// ResetsAt is when the oldest counted requests leave the window, freeing
+	// their share of the quota; it's empty while nothing is counted
+	ResetsAt string `json:"resets_at,omitempty"`
+}
+
+// clientUsage counts one client's requests in a ring of buckets, each
+// bucketWidth long. latest is the number of the newest bucket, counting from
+// the Unix epoch, and total the sum of counts.
+type clientUsage struct {
+	counts []int
+	latest int64
+	total  int
+}
+
+// QuotaTracker counts requests per API client over a rolling window, in
+// memory. Unlike calendar months, a rolling window can't be used twice over
+// across its boundary. Counts are lost on restart, which is fine for the lab.
+type QuotaTracker struct {
+	mu          sync.Mutex
+	limit       int
+	bucketWidth time.Duration
+	clients     map[string]*clientUsage
+}
+
+// NewQuotaTracker returns a tracker allowing limit requests per client in
+// any window-long period; 0 means unlimited
+func NewQuotaTracker(limit int, window time.Duration) *QuotaTracker {
+	return &QuotaTracker{
+		limit:       limit,
+		bucketWidth: max(window/quotaBuckets, 1),
+		clients:     make(map[string]*clientUsage),
+	}
+}
+
+// Allow counts a request by client, unless that would exceed the quota
+func (q *QuotaTracker) Allow(client string) (Usage, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	usage := q.current(client)
+	if q.limit > 0 && usage.total >= q.limit {
+		return q.report(client, usage), false
+	}
+	usage.counts[usage.latest%quotaBuckets]++
+	usage.total++
+	return q.report(client, usage), true
+}
+
+// Usage returns client's usage without counting a request
+func (q *QuotaTracker) Usage(client string) Usage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.report(client, q.current(client))
+}
+
+// current returns client's counter with the buckets that have left the
+// window emptied. q.mu must be held.
+func (q *QuotaTracker) current(client string) *clientUsage {
+	now := time.Now().UnixNano() / int64(q.bucketWidth)
+
+	usage, ok := q.clients[client]
+	if !ok {
+		usage = &clientUsage{counts: make([]int, quotaBuckets), latest: now}
+		q.clients[client] = usage
+	}
+	// Each step reuses the oldest bucket for the next slice of time; after a
+	// full window every bucket is empty, so there's no need to go further
+	for steps := 0; usage.latest < now && steps < quotaBuckets; steps++ {
+		usage.latest++
+		bucket := usage.latest % quotaBuckets
+		usage.total -= usage.counts[bucket]
+		usage.counts[bucket] = 0
+	}
+	usage.latest = now
+	return usage
+}
+
+// report converts a counter to a Usage. q.mu must be held.
+func (q *QuotaTracker) report(client string, usage *clientUsage) Usage {
+	report := Usage{
+		Client: client,
+		Used:   usage.total,
+		Limit:  q.limit,
+	}
+	if q.limit > 0 {
+		report.Remaining = max(q.limit-usage.total, 0)
+	}
+	// The oldest non-empty bucket leaves the window once the newest bucket
+	// wraps around onto it
+	for age := quotaBuckets - 1; age >= 0; age-- {
+		bucket := usage.latest - int64(age)
+		if usage.counts[bucket%quotaBuckets] > 0 {
+			expires := time.Unix(0, (bucket+quotaBuckets)*int64(q.bucketWidth))
+			report.ResetsAt = expires.UTC().Format(time.RFC3339)
+			break
+		}
+	}
+	return report
+}
+
+// Quota returns a middleware counting each request against the rolling
+// window quota of the client set by APIKeyAuth, aborting with 429 once it's used
+// up. It must run after APIKeyAuth.
+func Quota(tracker *QuotaTracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		usage, ok := tracker.Allow(APIClientFromContext(c))
+		if usage.Limit > 0 {
+			c.Header("X-Quota-Limit", strconv.Itoa(usage.Limit))
+			c.Header("X-Quota-Remaining", strconv.Itoa(usage.Remaining))
+		}
+		if !ok {
+			RespondError(c, http.StatusTooManyRequests, CodeQuotaExceeded, "quota exceeded")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// usageHandler reports the calling API client's quota usage. It isn't
+// metered, so clients can still read their usage once the quota is used up.
+//
+//	@Summary	Get API key usage
+//	@Tags		auth
+//	@Produce	json
+//	@Param		X-API-Key	header		string	true	"API key"
+//	@Success	200			{object}	Usage
+//	@Failure	401			{object}	APIError
+//	@Router		/v1/usage [get]
+func usageHandler(tracker *QuotaTracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, tracker.Usage(APIClientFromContext(c)))
+	}
+}
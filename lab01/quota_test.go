@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestQuotaTrackerCountsUntilLimit(t *testing.T) {
+	tracker := NewQuotaTracker(3, time.Hour)
+	for i := 1; i <= 3; i++ {
+		usage, ok := tracker.Allow("billing")
+		if !ok || usage.Used != i || usage.Remaining != 3-i {
+			t.Fatalf("request %d: got %+v allowed=%v", i, usage, ok)
+		}
+	}
+
+	usage, ok := tracker.Allow("billing")
+	if ok || usage.Used != 3 || usage.Remaining != 0 {
+		t.Fatalf("over quota: got %+v allowed=%v, want denied at 3 used", usage, ok)
+	}
+
+	// Other clients have their own count, and reading usage doesn't count
+	if usage, ok := tracker.Allow("reporting"); !ok || usage.Used != 1 {
+		t.Fatalf("second client: got %+v allowed=%v", usage, ok)
+	}
+	if usage := tracker.Usage("reporting"); usage.Used != 1 {
+		t.Fatalf("Usage counted a request: %+v", usage)
+	}
+}
+
+func TestQuotaTrackerUnlimited(t *testing.T) {
+	tracker := NewQuotaTracker(0, time.Hour)
+	for range 100 {
+		if _, ok := tracker.Allow("billing"); !ok {
+			t.Fatal("unlimited quota denied a request")
+		}
+	}
+	if usage := tracker.Usage("billing"); usage.Used != 100 || usage.Limit != 0 || usage.Remaining != 0 {
+		t.Fatalf("got %+v", usage)
+	}
+}
+
+func TestQuotaTrackerRollingWindow(t *testing.T) {
+	const window = 300 * time.Millisecond
+	tracker := NewQuotaTracker(2, window)
+
+	start := time.Now()
+	first, _ := tracker.Allow("billing")
+	time.Sleep(window / 2)
+	tracker.Allow("billing")
+	if _, ok := tracker.Allow("billing"); ok {
+		t.Fatal("third request inside the window was allowed")
+	}
+
+	// resets_at is when the first request leaves the window
+	resetsAt, err := time.Parse(time.RFC3339, first.ResetsAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := start.Add(window); resetsAt.Before(want.Add(-time.Second)) || resetsAt.After(want.Add(time.Second)) {
+		t.Fatalf("resets at %s, want about %s", resetsAt, want)
+	}
+
+	// Once the first request is a window old only its share frees up, unlike
+	// a calendar reset that would free both at once
+	time.Sleep(time.Until(start.Add(window + window/10)))
+	if usage, ok := tracker.Allow("billing"); !ok || usage.Used != 2 {
+		t.Fatalf("after the first request left the window: got %+v allowed=%v", usage, ok)
+	}
+	if _, ok := tracker.Allow("billing"); ok {
+		t.Fatal("the second request's share was freed before it aged out")
+	}
+
+	// After a whole window without requests nothing is counted
+	time.Sleep(window + window/10)
+	if usage := tracker.Usage("billing"); usage.Used != 0 || usage.Remaining != 2 || usage.ResetsAt != "" {
+		t.Fatalf("after an idle window: got %+v", usage)
+	}
+}
+
+func TestMachineSearchHitsQuota(t *testing.T) {
+	searcher := &fakeSearcher{}
+	r := v1Router(t, routeDeps{Searcher: searcher, APIKeys: testAPIKeys, Quota: NewQuotaTracker(2, time.Hour)})
+
+	for i, wantRemaining := range []string{"1", "0"} {
+		w := getWithKey(r, "/v1/machine/search?q=go", "s3cr3t-key-1")
+		if w.Code != http.StatusOK {
+			t.Fatalf("search %d: got %d: %s", i+1, w.Code, w.Body)
+		}
+		if got := w.Header().Get("X-Quota-Remaining"); got != wantRemaining || w.Header().Get("X-Quota-Limit") != "2" {
+			t.Fatalf("search %d: X-Quota-Remaining %q, want %q", i+1, got, wantRemaining)
+		}
+	}
+
+	w := getWithKey(r, "/v1/machine/search?q=go", "s3cr3t-key-1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third search: got %d, want 429", w.Code)
+	}
+	var resp APIError
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != CodeQuotaExceeded || resp.Message != "quota exceeded" {
+		t.Fatalf("got %+v", resp)
+	}
+	if searcher.calls != 2 {
+		t.Fatalf("searched %d times, want 2: the denied request reached the backend", searcher.calls)
+	}
+
+	// Usage can still be read once the quota is used up
+	w = getWithKey(r, "/v1/usage", "s3cr3t-key-1")
+	var usage Usage
+	if err := json.Unmarshal(w.Body.Bytes(), &usage); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || usage.Used != 2 || usage.Remaining != 0 {
+		t.Fatalf("usage over quota: got %d %+v, want 200 with nothing remaining", w.Code, usage)
+	}
+
+	// The quota is per key
+	if w := getWithKey(r, "/v1/machine/search?q=go", "s3cr3t-key-2"); w.Code != http.StatusOK {
+		t.Fatalf("other key: got %d, want 200", w.Code)
+	}
+}
+
+func TestUsageCountsAcrossRequests(t *testing.T) {
+	r := v1Router(t, routeDeps{APIKeys: testAPIKeys, Quota: NewQuotaTracker(10, time.Hour)})
+	getWithKey(r, "/v1/machine/search?q=go", "s3cr3t-key-1")
+	getWithKey(r, "/v1/machine/search?q=go", "s3cr3t-key-1")
+
+	w := getWithKey(r, "/v1/usage", "s3cr3t-key-1")
+	var usage Usage
+	if err := json.Unmarshal(w.Body.Bytes(), &usage); err != nil {
+		t.Fatal(err)
+	}
+	// Two searches; reading the usage doesn't count
+	if usage.Client != "billing-service" || usage.Used != 2 || usage.Limit != 10 || usage.Remaining != 8 {
+		t.Fatalf("got %+v", usage)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// limiterIdleTTL is how long a client's limiter is kept after its last request
+const limiterIdleTTL = 3 * time.Minute
+
+// clientLimiter pairs a token bucket with the last time it was used
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// ipRateLimiter keeps one token bucket per client IP
+type ipRateLimiter struct {
+	mu      sync.Mutex
+	clients map[string]*clientLimiter
+	idleTTL time.Duration
+}
+
+func newIPRateLimiter(idleTTL time.Duration) *ipRateLimiter {
+	return &ipRateLimiter{
+		clients: make(map[string]*clientLimiter),
+		idleTTL: idleTTL,
+	}
+}
+
+// get returns the limiter for ip, creating it on first use and applying the
+// current rps and burst if they've changed since it was last used
+func (l *ipRateLimiter) get(ip string, rps, burst int) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	client, ok := l.clients[ip]
+	if !ok {
+		client = &clientLimiter{limiter: rate.NewLimiter(rate.Limit(rps), burst)}
+		l.clients[ip] = client
+	}
+	client.lastSeen = time.Now()
+
+	if client.limiter.Limit() != rate.Limit(rps) {
+		client.limiter.SetLimit(rate.Limit(rps))
+	}
+	if client.limiter.Burst() != burst {
+		client.limiter.SetBurst(burst)
+	}
+
+	return client.limiter
+}
+
+// cleanup removes limiters that have been idle longer than idleTTL
+func (l *ipRateLimiter) cleanup() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for ip, client := range l.clients {
+		if time.Since(client.lastSeen) > l.idleTTL {
+			delete(l.clients, ip)
+		}
+	}
+}
+
+// RateLimit returns a middleware allowing each client IP rps requests per second
+// with bursts of up to burst requests. limits is called per request, so new
+// values take effect without a restart.
+func RateLimit(limits func() (rps, burst int)) gin.HandlerFunc {
+	limiters := newIPRateLimiter(limiterIdleTTL)
+
+	// Garbage-collect idle limiters so the map doesn't grow unbounded
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			limiters.cleanup()
+		}
+	}()
+
+	return func(c *gin.Context) {
+		rps, burst := limits()
+		reservation := limiters.get(c.ClientIP(), rps, burst).Reserve()
+		if delay := reservation.Delay(); delay > 0 {
+			// Don't consume a token for a request we're rejecting
+			reservation.Cancel()
+
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			RespondError(c, http.StatusTooManyRequests, CodeRateLimited, "rate limit exceeded")
+			return
+		}
+
+		c.Next()
+	}
+}
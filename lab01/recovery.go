@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Recovery returns a middleware that recovers from panics, logs the stack
+// trace with the request ID and responds with a JSON 500. Outside release
+// mode the panic message is included in the response for debugging.
+func Recovery() gin.HandlerFunc {
+	showDetails := gin.Mode() != gin.ReleaseMode
+
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+
+			requestID := RequestIDFromContext(c)
+			slog.Error("panic recovered",
+				"request_id", requestID,
+				"panic", fmt.Sprint(recovered),
+				"stack", string(debug.Stack()),
+			)
+
+			// A response (e.g. a timeout) may already have been sent
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+
+			var details map[string]any
+			if showDetails {
+				details = map[string]any{"panic": fmt.Sprint(recovered)}
+			}
+			RespondErrorWithDetails(c, http.StatusInternalServerError, CodeInternal, "internal server error", details)
+		}()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// panicRouter serves /panic, which panics with a secret, and /late, which
+// panics after writing its response, behind RequestID and Recovery
+func panicRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(RequestID(), Recovery())
+	r.GET("/panic", func(c *gin.Context) { panic("db password is hunter2") })
+	r.GET("/late", func(c *gin.Context) {
+		c.String(http.StatusAccepted, "done")
+		panic("after the response")
+	})
+	return r
+}
+
+// getWithRequestID sends a GET to target on h with an X-Request-ID header
+func getWithRequestID(h http.Handler, target, requestID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set(RequestIDHeader, requestID)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestRecoveryRespondsWithJSON(t *testing.T) {
+	w := getWithRequestID(panicRouter(), "/panic", "req-123")
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("got %d, want 500", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Fatalf("Content-Type is %q", ct)
+	}
+
+	var body APIError
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Message != "internal server error" || body.Code != CodeInternal || body.RequestID != "req-123" {
+		t.Fatalf("body is %+v", body)
+	}
+	if body.Details["panic"] != "db password is hunter2" {
+		t.Fatalf("debug details are %v", body.Details)
+	}
+}
+
+func TestRecoveryHidesPanicInReleaseMode(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	r := panicRouter()
+	gin.SetMode(gin.TestMode)
+
+	w := getWithRequestID(r, "/panic", "req-123")
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("got %d, want 500", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "hunter2") {
+		t.Fatalf("panic message leaked: %s", w.Body)
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := body["details"]; ok || body["request_id"] != "req-123" {
+		t.Fatalf("body is %v", body)
+	}
+}
+
+func TestRecoveryLogsStackWithRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	defer slog.SetDefault(prev)
+
+	getWithRequestID(panicRouter(), "/panic", "req-456")
+
+	var entry struct {
+		Msg       string
+		RequestID string `json:"request_id"`
+		Panic     string
+		Stack     string
+	}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("%v: %s", err, logs.String())
+	}
+	if entry.Msg != "panic recovered" || entry.RequestID != "req-456" || entry.Panic != "db password is hunter2" {
+		t.Fatalf("log entry is %+v", entry)
+	}
+	if !strings.Contains(entry.Stack, "recovery_test.go") {
+		t.Fatalf("stack doesn't reach the panicking handler: %s", entry.Stack)
+	}
+}
+
+func TestRecoveryKeepsResponseAlreadyWritten(t *testing.T) {
+	w := getWithRequestID(panicRouter(), "/late", "req-789")
+	if w.Code != http.StatusAccepted || w.Body.String() != "done" {
+		t.Fatalf("got %d %q", w.Code, w.Body)
+	}
+}
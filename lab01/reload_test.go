@@ -0,0 +1,121 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// writeDotEnv replaces .env in the working directory with contents
+func writeDotEnv(t *testing.T, contents string) {
+	t.Helper()
+	if err := os.WriteFile(".env", []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// reloadFixture loads the configuration from a .env holding contents in a
+// fresh working directory and returns a reloader over it. Variables applied
+// from .env, the current config and the log level are restored afterwards.
+func reloadFixture(t *testing.T, contents string) *reloader {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	prevConfig, prevLevel := CurrentConfig(), logLevel.Level()
+	t.Cleanup(func() {
+		writeDotEnv(t, "")
+		loadDotEnv()
+		SetConfig(prevConfig)
+		logLevel.Set(prevLevel)
+	})
+
+	writeDotEnv(t, contents)
+	if _, err := ReloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	flags, err := NewFlagStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &reloader{maintenance: &atomic.Bool{}, flags: flags}
+}
+
+func TestSIGHUPAppliesNewConfig(t *testing.T) {
+	r := reloadFixture(t, "LOG_LEVEL=info\nSEARCH_MAX_LIMIT=100\nFEATURE_FLAGS={\"new_search\":false}\n")
+	logLevel.Set(slog.LevelInfo)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer func() {
+		signal.Stop(hup)
+		close(hup)
+	}()
+	go r.watch(hup)
+
+	writeDotEnv(t, "LOG_LEVEL=debug\nSEARCH_MAX_LIMIT=5\nMAINTENANCE_MODE=true\nFEATURE_FLAGS={\"new_search\":true}\n")
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flags are reloaded last, so once they change everything else has too
+	deadline := time.Now().Add(2 * time.Second)
+	for !r.flags.Load().Enabled("new_search") {
+		if time.Now().After(deadline) {
+			t.Fatal("flags not reloaded after SIGHUP")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := CurrentConfig().SearchMaxLimit; got != 5 {
+		t.Fatalf("SEARCH_MAX_LIMIT is %d, want 5", got)
+	}
+	if got := logLevel.Level(); got != slog.LevelDebug {
+		t.Fatalf("log level is %s, want DEBUG", got)
+	}
+	if !r.maintenance.Load() {
+		t.Fatal("maintenance mode not switched on")
+	}
+}
+
+func TestReloadKeepsPreviousConfigWhenInvalid(t *testing.T) {
+	r := reloadFixture(t, "LOG_LEVEL=info\nSEARCH_MAX_LIMIT=100\n")
+	logLevel.Set(slog.LevelInfo)
+	previous := CurrentConfig()
+
+	writeDotEnv(t, "LOG_LEVEL=debug\nSEARCH_MAX_LIMIT=0\n")
+	r.reload()
+	if CurrentConfig() != previous {
+		t.Fatal("invalid config replaced the previous one")
+	}
+	if logLevel.Level() != slog.LevelInfo {
+		t.Fatalf("log level changed to %s by an invalid config", logLevel.Level())
+	}
+}
+
+func TestReloadReadsFlagSourceFromNewConfig(t *testing.T) {
+	r := reloadFixture(t, "FEATURE_FLAGS={\"new_search\":false}\n")
+	if r.flags.Load().Enabled("new_search") {
+		t.Fatal("new_search on at startup")
+	}
+
+	// Switching from inline flags to a file takes effect on reload
+	file := filepath.Join(t.TempDir(), "flags.json")
+	if err := os.WriteFile(file, []byte(`{"new_search":true,"beta":true}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	writeDotEnv(t, "FEATURE_FLAGS_FILE="+file+"\n")
+	r.reload()
+	if flags := r.flags.Load(); !flags.Enabled("new_search") || !flags.Enabled("beta") {
+		t.Fatalf("got %v, want the flags from %s", flags, file)
+	}
+
+	// A file that can't be read keeps the flags already loaded
+	writeDotEnv(t, "FEATURE_FLAGS_FILE="+filepath.Join(t.TempDir(), "missing.json")+"\n")
+	r.reload()
+	if !r.flags.Load().Enabled("beta") {
+		t.Fatal("unreadable flag file cleared the flags")
+	}
+}
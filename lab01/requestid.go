@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the header used to propagate the correlation ID
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID returns a middleware that reuses an incoming X-Request-ID header
+// or generates a UUID v4, then stores it in the context and response header
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" {
+			id = uuid.NewString()
+		}
+
+		SetRequestID(c, id)
+		c.Header(RequestIDHeader, id)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseTimeHeader carries the server-side request duration in milliseconds
+const ResponseTimeHeader = "X-Response-Time"
+
+// ResponseTime returns a middleware that reports how long the request took in
+// the X-Response-Time header. The header is set just before the response
+// headers are sent, since it can't be added once the body is written.
+func ResponseTime() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writer := &responseTimeWriter{ResponseWriter: c.Writer, start: time.Now()}
+		c.Writer = writer
+
+		c.Next()
+
+		// Handlers that never wrote a body still get the header
+		writer.setHeader()
+	}
+}
+
+// responseTimeWriter sets the response time header on the first write
+type responseTimeWriter struct {
+	gin.ResponseWriter
+	start time.Time
+}
+
+func (w *responseTimeWriter) setHeader() {
+	if w.ResponseWriter.Written() {
+		return
+	}
+	elapsed := float64(time.Since(w.start).Microseconds()) / 1000
+	w.Header().Set(ResponseTimeHeader, strconv.FormatFloat(elapsed, 'f', 3, 64))
+}
+
+func (w *responseTimeWriter) WriteHeaderNow() {
+	w.setHeader()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *responseTimeWriter) Write(data []byte) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseTimeWriter) WriteString(s string) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *responseTimeWriter) Flush() {
+	w.setHeader()
+	w.ResponseWriter.Flush()
+}
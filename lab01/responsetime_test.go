@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseTimeRouter serves /ping, a slow /slow that writes a body, and an
+// /empty handler that never writes, behind ResponseTime
+func responseTimeRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(ResponseTime())
+	r.GET("/ping", pingHandler)
+	r.GET("/slow", func(c *gin.Context) {
+		time.Sleep(20 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+	r.GET("/empty", func(c *gin.Context) {})
+	return r
+}
+
+// responseTime parses the X-Response-Time value in header as milliseconds
+func responseTime(t *testing.T, header http.Header) float64 {
+	t.Helper()
+	value := header.Get(ResponseTimeHeader)
+	ms, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		t.Fatalf("%s %q isn't a number: %v", ResponseTimeHeader, value, err)
+	}
+	if ms < 0 {
+		t.Fatalf("%s is negative: %v", ResponseTimeHeader, ms)
+	}
+	return ms
+}
+
+func TestResponseTimeHeader(t *testing.T) {
+	r := responseTimeRouter()
+
+	w := get(r, "/ping")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d", w.Code)
+	}
+	// Result holds the headers as they were sent with the body, not as
+	// they are now
+	responseTime(t, w.Result().Header)
+
+	if ms := responseTime(t, get(r, "/slow").Result().Header); ms < 20 {
+		t.Fatalf("slow handler took %vms by the header, want at least 20", ms)
+	}
+	responseTime(t, get(r, "/empty").Result().Header)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff is an exponential backoff with full jitter: the wait before retry
+// n is a random duration between zero and min(Base*2^n, Max), which keeps
+// clients that failed together from retrying in lockstep
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// defaultBackoff suits downstream calls made while a request waits
+var defaultBackoff = Backoff{Base: 100 * time.Millisecond, Max: 2 * time.Second}
+
+// permanentError marks an error that retrying won't fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so Retry returns it at once instead of trying again,
+// for failures such as a 4xx response that the next attempt would repeat
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Retry calls fn up to attempts times with the default backoff between
+// failures. See Backoff.Retry.
+func Retry(ctx context.Context, attempts int, fn func(context.Context) error) error {
+	return defaultBackoff.Retry(ctx, attempts, fn)
+}
+
+// Retry calls fn until it succeeds, returns a Permanent error or has been
+// called attempts times, waiting between attempts, and returns fn's last
+// error unwrapped from Permanent. It stops as soon as ctx is done, returning
+// ctx's error wrapped with the last failure if that happens while waiting,
+// so a canceled request doesn't keep retrying.
+func (b Backoff) Retry(ctx context.Context, attempts int, fn func(context.Context) error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if ctx.Err() != nil || attempt+1 >= attempts {
+			return err
+		}
+
+		timer := time.NewTimer(b.delay(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w: last attempt: %w", ctx.Err(), err)
+		}
+	}
+}
+
+// delay returns the jittered wait after the given zero-based attempt
+func (b Backoff) delay(attempt int) time.Duration {
+	ceiling := b.Base
+	for range attempt {
+		// Stop doubling at Max, before the duration can overflow
+		if ceiling >= b.Max/2 {
+			ceiling = b.Max
+			break
+		}
+		ceiling *= 2
+	}
+	ceiling = min(ceiling, b.Max)
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling + 1)
+}
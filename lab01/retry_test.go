@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelayStaysUnderCeiling(t *testing.T) {
+	b := Backoff{Base: 100 * time.Millisecond, Max: time.Second}
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{2, 400 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{4, time.Second},
+		{100, time.Second},
+	}
+	for _, tt := range tests {
+		var longest time.Duration
+		for range 1000 {
+			d := b.delay(tt.attempt)
+			if d < 0 || d > tt.ceiling {
+				t.Fatalf("attempt %d: delay %s outside [0, %s]", tt.attempt, d, tt.ceiling)
+			}
+			longest = max(longest, d)
+		}
+		// Full jitter spreads over the whole range, not just near zero
+		if longest < tt.ceiling/2 {
+			t.Errorf("attempt %d: longest of 1000 delays %s, want close to %s", tt.attempt, longest, tt.ceiling)
+		}
+	}
+}
+
+func TestRetryStopsAfterAttempts(t *testing.T) {
+	b := Backoff{Base: time.Millisecond, Max: time.Millisecond}
+	fail := errors.New("transient")
+	calls := 0
+	err := b.Retry(context.Background(), 3, func(context.Context) error {
+		calls++
+		return fail
+	})
+	if err != fail || calls != 3 {
+		t.Fatalf("got %v after %d calls, want the last error after 3", err, calls)
+	}
+}
+
+func TestRetryStopsOnSuccess(t *testing.T) {
+	b := Backoff{Base: time.Millisecond, Max: time.Millisecond}
+	calls := 0
+	err := b.Retry(context.Background(), 5, func(context.Context) error {
+		calls++
+		if calls < 2 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Fatalf("got %v after %d calls, want success after 2", err, calls)
+	}
+}
+
+func TestRetryDoesNotRetryPermanentErrors(t *testing.T) {
+	notFound := errors.New("404")
+	calls := 0
+	err := Retry(context.Background(), 5, func(context.Context) error {
+		calls++
+		return Permanent(notFound)
+	})
+	if err != notFound || calls != 1 {
+		t.Fatalf("got %v after %d calls, want the unwrapped error after 1", err, calls)
+	}
+	if Permanent(nil) != nil {
+		t.Fatal("Permanent(nil) should be nil")
+	}
+}
+
+func TestRetryWaitsBetweenAttempts(t *testing.T) {
+	// With Base equal to Max every wait is at most 20ms, so 4 attempts
+	// finish within 60ms plus scheduling slack
+	b := Backoff{Base: 20 * time.Millisecond, Max: 20 * time.Millisecond}
+	start := time.Now()
+	b.Retry(context.Background(), 4, func(context.Context) error { return errors.New("transient") })
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("took %s, want at most about 60ms", elapsed)
+	}
+}
+
+func TestRetryAbortsWhenContextIsCanceled(t *testing.T) {
+	b := Backoff{Base: time.Hour, Max: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	last := errors.New("transient")
+	calls := 0
+
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	err := b.Retry(ctx, 10, func(context.Context) error {
+		calls++
+		return last
+	})
+
+	if time.Since(start) > time.Second {
+		t.Fatal("kept waiting after the context was canceled")
+	}
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, last) {
+		t.Fatalf("got %v, want context.Canceled wrapping the last error", err)
+	}
+	if calls != 1 {
+		t.Fatalf("%d calls, want 1", calls)
+	}
+}
+
+func TestRetryStopsWhenAttemptSeesCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	Retry(ctx, 5, func(context.Context) error {
+		calls++
+		cancel()
+		return errors.New("canceled mid-attempt")
+	})
+	if calls != 1 {
+		t.Fatalf("%d calls, want 1", calls)
+	}
+}
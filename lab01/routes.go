@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oschwald/geoip2-golang"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// routeDeps holds the dependencies API handlers are constructed with
+type routeDeps struct {
+	Searcher           Searcher
+	TermSearcher       Searcher
+	Users              UserStore
+	UserChanges        *UserChanges
+	UserChangesTimeout time.Duration
+	UserBatchMax       int
+	BcryptCost         int
+	PostCategories     []string
+	JWTSecret          []byte
+	SessionSecret      []byte
+	Sessions           *SessionStore
+	APIKeys            map[string]string
+	Quota              *QuotaTracker
+	Uploads            UploadConfig
+	Webhooks           *WebhookDispatcher
+	Jobs               *JobQueue
+	Audit              *AuditLogger
+	Idempotency        *idempotencyStore
+	GeoIP              *geoip2.Reader
+	SignatureSecret    []byte
+	SignatureMaxSkew   time.Duration
+	// Shutdown is canceled when the server starts shutting down
+	Shutdown context.Context
+}
+
+// RouteDef declares an endpoint together with the middleware that runs for
+// it alone, after the global and group middleware
+type RouteDef struct {
+	Methods    []string
+	Path       string
+	Middleware []gin.HandlerFunc
+	Handler    gin.HandlerFunc
+}
+
+// Shorthands for the method sets routes are registered with
+var (
+	methodsGet       = []string{http.MethodGet}
+	methodsGetOrHead = []string{http.MethodGet, http.MethodHead}
+	methodsPost      = []string{http.MethodPost}
+	methodsPut       = []string{http.MethodPut}
+	methodsPatch     = []string{http.MethodPatch}
+	methodsDelete    = []string{http.MethodDelete}
+)
+
+// RegisterRoutes registers every method of each def on r, with the def's
+// middleware in front of its handler
+func RegisterRoutes(r gin.IRoutes, defs []RouteDef) {
+	for _, def := range defs {
+		handlers := append(slices.Clone(def.Middleware), def.Handler)
+		for _, method := range def.Methods {
+			r.Handle(method, def.Path, handlers...)
+		}
+	}
+}
+
+// chain is a readable way to list a route's middleware
+func chain(middleware ...gin.HandlerFunc) []gin.HandlerFunc {
+	return middleware
+}
+
+// probeRoutes are the health, version and Kubernetes probe endpoints. They
+// also answer HEAD for monitoring tools; net/http discards the body but
+// keeps the headers and Content-Length.
+func probeRoutes(readinessCheckers []ReadinessChecker, startup *StartupGate) []RouteDef {
+	tz := Timezone()
+	return []RouteDef{
+		// Basic ping endpoint - health check
+		{Methods: methodsGetOrHead, Path: "/ping", Handler: pingHandler},
+		// Enhanced health check endpoint
+		{Methods: methodsGetOrHead, Path: "/health", Middleware: chain(tz), Handler: healthHandler},
+		// Build information injected via -ldflags
+		{Methods: methodsGet, Path: "/buildinfo", Handler: buildInfoHandler},
+		// Just the version, cacheable and free of health checks for frequent polling
+		{Methods: methodsGetOrHead, Path: "/version", Handler: versionHandler},
+		// Kubernetes probes: liveness always succeeds while the process is
+		// up, readiness fails during startup and whenever a critical
+		// dependency is unreachable
+		{Methods: methodsGetOrHead, Path: "/healthz", Middleware: chain(tz), Handler: livenessHandler},
+		{Methods: methodsGetOrHead, Path: "/readyz", Handler: readinessHandler(readinessCheckers, startup)},
+	}
+}
+
+// adminRoutes are the operator endpoints, registered on a group that
+// already restricts access
+func adminRoutes(audit *AuditLogger) []RouteDef {
+	return []RouteDef{
+		// Prometheus scrape endpoint
+		{Methods: methodsGet, Path: "/metrics", Handler: gin.WrapH(promhttp.Handler())},
+		// Current feature flags
+		{Methods: methodsGet, Path: "/flags", Handler: flagsHandler},
+		// Audit trail of user changes
+		{Methods: methodsGet, Path: "/audit", Handler: auditHandler(audit, func() int { return CurrentConfig().SearchMaxLimit })},
+	}
+}
+
+// v1Routes are the version 1 API endpoints
+func v1Routes(deps routeDeps) []RouteDef {
+	maxLimit := func() int { return CurrentConfig().SearchMaxLimit }
+
+	// Mutating user endpoints require a bearer token, and destructive ones
+	// the admin role
+	auth := AuthRequired(deps.JWTSecret)
+	admin := RequireRole(RoleAdmin)
+	tz := Timezone()
+
+	defs := []RouteDef{
+		// Login checking a stored user's password, issuing bearer tokens for
+		// AuthRequired routes and session cookies for SessionAuth ones
+		{Methods: methodsPost, Path: "/login", Handler: loginHandler(deps.Users, deps.JWTSecret, deps.Sessions, deps.SessionSecret, deps.BcryptCost)},
+		{Methods: methodsPost, Path: "/logout", Handler: logoutHandler(deps.Sessions, deps.SessionSecret)},
+		{Methods: methodsGet, Path: "/session", Middleware: chain(SessionAuth(deps.Sessions, deps.SessionSecret)), Handler: sessionHandler},
+
+		// User resource
+		{Methods: methodsPost, Path: "/user", Middleware: chain(tz, auth, ValidateSchema("create_user.json"), Idempotency(deps.Idempotency)),
+			Handler: createUserHandler(deps.Users, deps.Webhooks, deps.Jobs, deps.Audit, deps.BcryptCost)},
+		{Methods: methodsGet, Path: "/user/:id", Middleware: chain(tz), Handler: getUserHandler(deps.Users)},
+		{Methods: methodsGet, Path: "/users", Middleware: chain(tz), Handler: listUsersHandler(deps.Users, maxLimit)},
+		{Methods: methodsGet, Path: "/users/changes", Middleware: chain(tz),
+			Handler: userChangesHandler(deps.Users, deps.UserChanges, deps.Shutdown, deps.UserChangesTimeout, maxLimit)},
+		{Methods: methodsPost, Path: "/users/batch", Middleware: chain(tz), Handler: batchGetUsersHandler(deps.Users, deps.UserBatchMax)},
+		{Methods: methodsPut, Path: "/user/:id", Middleware: chain(tz, auth), Handler: updateUserHandler(deps.Users, deps.Webhooks, deps.Audit)},
+		{Methods: methodsPatch, Path: "/user/:id", Middleware: chain(tz, auth), Handler: patchUserHandler(deps.Users, deps.Webhooks, deps.Audit)},
+		{Methods: methodsDelete, Path: "/user/:id", Middleware: chain(auth, admin), Handler: deleteUserHandler(deps.Users, deps.Audit)},
+		{Methods: methodsPost, Path: "/user/:id/restore", Middleware: chain(tz, auth, admin), Handler: restoreUserHandler(deps.Users, deps.Audit)},
+
+		// Endpoint combining both path and query parameters
+		{Methods: methodsGet, Path: "/user/:id/posts", Handler: getUserPostsHandler(deps.PostCategories)},
+
+		// Multipart file upload
+		{Methods: methodsPost, Path: "/upload", Middleware: chain(auth), Handler: uploadHandler(deps.Uploads)},
+
+		// Endpoint demonstrating query parameters
+		{Methods: methodsGet, Path: "/search", Handler: searchHandler(deps.Searcher, deps.TermSearcher, maxLimit)},
+		{Methods: methodsGet, Path: "/search/stream", Handler: searchStreamHandler(deps.Searcher)},
+		{Methods: methodsGet, Path: "/search.csv", Handler: searchExportHandler(deps.Searcher)},
+	}
+
+	// Endpoints for API key clients, metered against their monthly quota
+	// except for reading the usage itself. Machine clients search under
+	// /machine so /search stays public.
+	if len(deps.APIKeys) > 0 {
+		apiKey := APIKeyAuth(deps.APIKeys)
+		defs = append(defs,
+			RouteDef{Methods: methodsGet, Path: "/machine/search", Middleware: chain(apiKey, Quota(deps.Quota)),
+				Handler: machineSearchHandler(deps.Searcher, deps.TermSearcher, maxLimit)},
+			RouteDef{Methods: methodsGet, Path: "/usage", Middleware: chain(apiKey), Handler: usageHandler(deps.Quota)},
+		)
+	}
+
+	// Server-to-server endpoints authenticated by an HMAC body signature
+	if len(deps.SignatureSecret) > 0 {
+		defs = append(defs, RouteDef{Methods: methodsPost, Path: "/internal/events",
+			Middleware: chain(VerifySignature(deps.SignatureSecret, deps.SignatureMaxSkew)), Handler: internalEventsHandler})
+	}
+
+	// IP geolocation, when a GeoIP database was found at startup
+	if deps.GeoIP != nil {
+		defs = append(defs, RouteDef{Methods: methodsGet, Path: "/geoip/:ip", Handler: geoIPHandler(deps.GeoIP)})
+	}
+
+	return defs
+}
+
+// Deprecated returns a middleware marking responses from unversioned routes
+// as deprecated and pointing clients at the /v1 equivalent
+func Deprecated() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		c.Header("Link", "</v1"+c.Request.URL.Path+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tag returns a middleware appending name to the X-Chain response header,
+// recording which middleware ran and in what order
+func tag(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("X-Chain", name)
+		c.Next()
+	}
+}
+
+// named returns a handler answering with name
+func named(name string) gin.HandlerFunc {
+	return func(c *gin.Context) { c.String(http.StatusOK, name) }
+}
+
+func TestRegisterRoutesAppliesMiddlewarePerRoute(t *testing.T) {
+	shared := chain(tag("limit"))
+
+	r := gin.New()
+	RegisterRoutes(r, []RouteDef{
+		{Methods: methodsGet, Path: "/ping", Handler: named("ping")},
+		{Methods: methodsGetOrHead, Path: "/search", Middleware: shared, Handler: named("search")},
+		{Methods: methodsGet, Path: "/admin", Middleware: chain(tag("limit"), tag("auth")), Handler: named("admin")},
+		{Methods: methodsGet, Path: "/export", Middleware: shared, Handler: named("export")},
+	})
+
+	tests := []struct {
+		method string
+		target string
+		want   []string
+	}{
+		{http.MethodGet, "/ping", nil},
+		{http.MethodGet, "/search", []string{"limit"}},
+		{http.MethodHead, "/search", []string{"limit"}},
+		{http.MethodGet, "/admin", []string{"limit", "auth"}},
+		{http.MethodGet, "/export", []string{"limit"}},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s %s: status %d", tt.method, tt.target, w.Code)
+		}
+		if got := w.Header().Values("X-Chain"); !slices.Equal(got, tt.want) {
+			t.Errorf("%s %s: ran %v, want %v", tt.method, tt.target, got, tt.want)
+		}
+		if want := strings.TrimPrefix(tt.target, "/"); tt.method == http.MethodGet && w.Body.String() != want {
+			t.Errorf("%s %s: served by %q, want %q", tt.method, tt.target, w.Body, want)
+		}
+	}
+
+	// Only the declared methods are registered
+	if w := post(r, "/ping", ""); w.Code != http.StatusNotFound {
+		t.Errorf("POST /ping: status %d, want 404", w.Code)
+	}
+}
+
+func TestV1RoutesRequireAuthOnlyWhereDeclared(t *testing.T) {
+	r := v1Router(t, routeDeps{JWTSecret: testJWTSecret})
+
+	tests := []struct {
+		method string
+		target string
+		body   string
+		auth   bool
+	}{
+		{http.MethodPost, "/v1/user", `{"username":"ann","name":"Ann","email":"ann@example.com"}`, true},
+		{http.MethodPut, "/v1/user/1", `{}`, true},
+		{http.MethodPatch, "/v1/user/1", `{}`, true},
+		{http.MethodDelete, "/v1/user/1", "", true},
+		{http.MethodPost, "/v1/user/1/restore", "", true},
+		{http.MethodPost, "/v1/upload", "", true},
+		{http.MethodGet, "/v1/user/1", "", false},
+		{http.MethodGet, "/v1/users", "", false},
+		{http.MethodGet, "/v1/search?q=go", "", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if got := w.Code == http.StatusUnauthorized; got != tt.auth {
+			t.Errorf("%s %s: status %d, want auth required %t", tt.method, tt.target, w.Code, tt.auth)
+		}
+	}
+}
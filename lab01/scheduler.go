@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// scheduledTask is a function run on a fixed interval
+type scheduledTask struct {
+	name     string
+	interval time.Duration
+	fn       func(ctx context.Context) error
+}
+
+// Scheduler runs registered tasks periodically on their own tickers. Tasks
+// registered before Start begin when it's called; later ones begin at once.
+type Scheduler struct {
+	mu      sync.Mutex
+	tasks   []scheduledTask
+	started bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler with no tasks
+func NewScheduler() *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{ctx: ctx, cancel: cancel}
+}
+
+// RegisterTask runs fn every interval, first after one interval has elapsed.
+// A run that's still going when the next tick arrives delays it rather than
+// overlapping.
+func (s *Scheduler) RegisterTask(name string, interval time.Duration, fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task := scheduledTask{name: name, interval: interval, fn: fn}
+	s.tasks = append(s.tasks, task)
+	if s.started {
+		s.launch(task)
+	}
+}
+
+// Start begins running every registered task
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return
+	}
+	s.started = true
+	for _, task := range s.tasks {
+		s.launch(task)
+	}
+}
+
+// Stop cancels running tasks and waits for them to return
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// launch starts task's ticker loop; s.mu must be held
+func (s *Scheduler) launch(task scheduledTask) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(task.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				runTask(s.ctx, task)
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// runTask runs task once, logging its duration and outcome
+func runTask(ctx context.Context, task scheduledTask) {
+	start := time.Now()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			slog.Error("Scheduled task panicked", "task", task.name, "panic", recovered)
+		}
+	}()
+
+	if err := task.fn(ctx); err != nil {
+		slog.Error("Scheduled task failed", "task", task.name, "duration", time.Since(start), "error", err)
+		return
+	}
+	slog.Info("Scheduled task finished", "task", task.name, "duration", time.Since(start))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerRunsTasks(t *testing.T) {
+	s := NewScheduler()
+	t.Cleanup(s.Stop)
+
+	before := make(chan struct{}, 1)
+	s.RegisterTask("before-start", 10*time.Millisecond, func(context.Context) error {
+		select {
+		case before <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	s.Start()
+
+	after := make(chan struct{}, 1)
+	s.RegisterTask("after-start", 10*time.Millisecond, func(context.Context) error {
+		select {
+		case after <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+
+	for name, ran := range map[string]chan struct{}{"before-start": before, "after-start": after} {
+		select {
+		case <-ran:
+		case <-time.After(time.Second):
+			t.Errorf("task %s never ran", name)
+		}
+	}
+}
+
+func TestSchedulerKeepsRunningAfterFailures(t *testing.T) {
+	s := NewScheduler()
+	t.Cleanup(s.Stop)
+
+	var runs atomic.Int32
+	s.RegisterTask("flaky", 5*time.Millisecond, func(context.Context) error {
+		if runs.Add(1)%2 == 0 {
+			panic("boom")
+		}
+		return errors.New("failed")
+	})
+	s.Start()
+
+	deadline := time.Now().Add(time.Second)
+	for runs.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("task ran %d times, want it to keep running after errors and panics", runs.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSchedulerStopCancelsAndWaits(t *testing.T) {
+	s := NewScheduler()
+
+	started := make(chan struct{})
+	var finished atomic.Bool
+	var runs atomic.Int32
+	s.RegisterTask("long", 5*time.Millisecond, func(ctx context.Context) error {
+		if runs.Add(1) == 1 {
+			close(started)
+		}
+		<-ctx.Done()
+		finished.Store(true)
+		return ctx.Err()
+	})
+	s.Start()
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("task never ran")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop didn't return after canceling the running task")
+	}
+	if !finished.Load() {
+		t.Error("Stop returned before the running task did")
+	}
+
+	stoppedAt := runs.Load()
+	time.Sleep(20 * time.Millisecond)
+	if got := runs.Load(); got != stoppedAt {
+		t.Errorf("task ran %d more times after Stop", got-stoppedAt)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// schemaFiles holds the JSON Schemas request bodies can be validated against
+//
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// SchemaViolation is one way a request body fails its schema
+type SchemaViolation struct {
+	// Path is the JSON pointer to the offending value; "" is the whole body
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// compileSchema compiles the embedded schema named name, with format
+// keywords such as "email" enforced rather than treated as annotations
+func compileSchema(name string) (*jsonschema.Schema, error) {
+	data, err := schemaFiles.ReadFile("schemas/" + name)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat()
+	if err := compiler.AddResource(name, doc); err != nil {
+		return nil, err
+	}
+	return compiler.Compile(name)
+}
+
+// ValidateSchema returns a middleware that rejects request bodies not matching
+// the embedded schema called name with a 400 listing every violation. The
+// body is left in place for the handler to bind. It panics if the schema
+// doesn't compile, since that's a programming error.
+func ValidateSchema(name string) gin.HandlerFunc {
+	schema, err := compileSchema(name)
+	if err != nil {
+		panic("compile schema " + name + ": " + err.Error())
+	}
+
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			if isBodyTooLarge(err) {
+				respondBodyTooLarge(c)
+				return
+			}
+			RespondError(c, http.StatusBadRequest, CodeInvalidRequest, "Failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+		if err != nil {
+			RespondError(c, http.StatusBadRequest, CodeInvalidRequest, "Request body must be valid JSON")
+			return
+		}
+
+		err = schema.Validate(instance)
+		var validationErr *jsonschema.ValidationError
+		if errors.As(err, &validationErr) {
+			RespondErrorWithDetails(c, http.StatusBadRequest, CodeInvalidRequest, "Request body does not match schema",
+				map[string]any{"violations": schemaViolations(validationErr)})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// schemaViolations flattens a validation error into its leaf failures, which
+// are the ones that say what's actually wrong
+func schemaViolations(err *jsonschema.ValidationError) []SchemaViolation {
+	var violations []SchemaViolation
+	var collect func(unit jsonschema.OutputUnit)
+	collect = func(unit jsonschema.OutputUnit) {
+		if unit.Error != nil && len(unit.Errors) == 0 {
+			violations = append(violations, SchemaViolation{Path: unit.InstanceLocation, Message: unit.Error.String()})
+		}
+		for _, cause := range unit.Errors {
+			collect(cause)
+		}
+	}
+	collect(*err.BasicOutput())
+	return violations
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// schemaRouter serves POST /user behind ValidateSchema("create_user.json"),
+// echoing the body the handler reads
+func schemaRouter() *gin.Engine {
+	r := gin.New()
+	r.POST("/user", ValidateSchema("create_user.json"), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Data(http.StatusOK, "application/json", body)
+	})
+	return r
+}
+
+func TestValidateSchemaListsViolations(t *testing.T) {
+	w := post(schemaRouter(), "/user", `{"username":"1ann","email":"not-an-email","phone":"555","nickname":"A"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400: %s", w.Code, w.Body)
+	}
+
+	var body struct {
+		Code    string `json:"code"`
+		Details struct {
+			Violations []SchemaViolation `json:"violations"`
+		} `json:"details"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, violation := range body.Details.Violations {
+		if violation.Message == "" {
+			t.Errorf("violation at %q has no message", violation.Path)
+		}
+		paths = append(paths, violation.Path)
+	}
+	// The missing name and unknown nickname are reported against the body
+	for _, want := range []string{"", "/username", "/email", "/phone"} {
+		if !slices.Contains(paths, want) {
+			t.Errorf("no violation at %q in %v", want, paths)
+		}
+	}
+	if body.Code != CodeInvalidRequest {
+		t.Errorf("code is %q", body.Code)
+	}
+}
+
+func TestValidateSchemaPassesValidBodyThrough(t *testing.T) {
+	const valid = `{"username":"ann","name":"Ann","email":"ann@example.com","phone":"+15551234567"}`
+	w := post(schemaRouter(), "/user", valid)
+	if w.Code != http.StatusOK || w.Body.String() != valid {
+		t.Fatalf("got %d %s, want the body passed to the handler untouched", w.Code, w.Body)
+	}
+}
+
+func TestValidateSchemaRejectsInvalidJSON(t *testing.T) {
+	for _, body := range []string{`{"username":`, ``, `not json`} {
+		if w := post(schemaRouter(), "/user", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%q: status %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func TestValidateSchemaPanicsOnUnknownSchema(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("ValidateSchema accepted a schema that doesn't exist")
+		}
+	}()
+	ValidateSchema("missing.json")
+}
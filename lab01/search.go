@@ -0,0 +1,165 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Result is a single search hit
+type Result struct {
+	ID      int64  `json:"id"`
+	Title   string `json:"title"`
+	Snippet string `json:"snippet"`
+}
+
+// Searcher finds results matching a query
+type Searcher interface {
+	// Search returns up to limit results starting at offset, plus the total
+	// number of matches across all pages
+	Search(ctx context.Context, query string, limit, offset int) ([]Result, int, error)
+	// SearchAfter returns up to limit results whose ID is greater than
+	// afterID, plus the total number of matches across all pages
+	SearchAfter(ctx context.Context, query string, limit int, afterID int64) ([]Result, int, error)
+}
+
+// MemorySearcher is an in-memory Searcher over a fixed set of documents
+type MemorySearcher struct {
+	documents []Result
+	// allTerms matches each whitespace-separated term independently
+	allTerms bool
+}
+
+// NewMemorySearcher creates a searcher over documents, which must be sorted
+// by ID, matching the query as a single phrase
+func NewMemorySearcher(documents []Result) *MemorySearcher {
+	return &MemorySearcher{documents: documents}
+}
+
+// NewTermSearcher creates a searcher over documents, which must be sorted by
+// ID, matching documents that contain every term of the query in any order
+func NewTermSearcher(documents []Result) *MemorySearcher {
+	return &MemorySearcher{documents: documents, allTerms: true}
+}
+
+// Search performs a case-insensitive substring match on title and snippet.
+// A negative offset is treated as zero.
+func (s *MemorySearcher) Search(ctx context.Context, query string, limit, offset int) ([]Result, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	matches := s.match(query)
+	total := len(matches)
+	offset = max(offset, 0)
+	if offset >= total {
+		return []Result{}, total, nil
+	}
+
+	end := min(offset+limit, total)
+	return matches[offset:end], total, nil
+}
+
+// SearchAfter performs the same match as Search, resuming after afterID
+func (s *MemorySearcher) SearchAfter(ctx context.Context, query string, limit int, afterID int64) ([]Result, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	matches := s.match(query)
+	total := len(matches)
+
+	// Documents are sorted by ID, so the first match past afterID starts the page
+	start, _ := slices.BinarySearchFunc(matches, afterID+1, func(doc Result, id int64) int {
+		return cmp.Compare(doc.ID, id)
+	})
+
+	end := min(start+limit, total)
+	return matches[start:end], total, nil
+}
+
+// match returns every document whose title or snippet contains query (or,
+// for a term searcher, each of its terms), ignoring case, in ID order
+func (s *MemorySearcher) match(query string) []Result {
+	terms := []string{strings.ToLower(query)}
+	if s.allTerms {
+		terms = strings.Fields(terms[0])
+	}
+
+	matches := []Result{}
+	for _, doc := range s.documents {
+		text := strings.ToLower(doc.Title + "\n" + doc.Snippet)
+		if containsAll(text, terms) {
+			matches = append(matches, doc)
+		}
+	}
+	return matches
+}
+
+// containsAll reports whether text contains every one of terms
+func containsAll(text string, terms []string) bool {
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// SlowSearcher delays every search on another Searcher, simulating a slow
+// backend. The delay ends early, with the context's error, if the caller
+// gives up first.
+type SlowSearcher struct {
+	next  Searcher
+	delay time.Duration
+}
+
+// NewSlowSearcher wraps next so each search takes at least delay
+func NewSlowSearcher(next Searcher, delay time.Duration) *SlowSearcher {
+	return &SlowSearcher{next: next, delay: delay}
+}
+
+func (s *SlowSearcher) Search(ctx context.Context, query string, limit, offset int) ([]Result, int, error) {
+	if err := s.wait(ctx); err != nil {
+		return nil, 0, err
+	}
+	return s.next.Search(ctx, query, limit, offset)
+}
+
+func (s *SlowSearcher) SearchAfter(ctx context.Context, query string, limit int, afterID int64) ([]Result, int, error) {
+	if err := s.wait(ctx); err != nil {
+		return nil, 0, err
+	}
+	return s.next.SearchAfter(ctx, query, limit, afterID)
+}
+
+// wait sleeps for the configured delay or until ctx is done
+func (s *SlowSearcher) wait(ctx context.Context) error {
+	timer := time.NewTimer(s.delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sampleDocuments seeds the in-memory searcher for the lab
+var sampleDocuments = []Result{
+	{ID: 1, Title: "Getting started with Go", Snippet: "Install the Go toolchain and write your first program."},
+	{ID: 2, Title: "Go modules", Snippet: "Manage dependencies with go.mod and go.sum."},
+	{ID: 3, Title: "Building APIs with Gin", Snippet: "Routing, middleware and JSON rendering in the Gin framework."},
+	{ID: 4, Title: "Gin middleware", Snippet: "Write reusable middleware for logging, auth and CORS."},
+	{ID: 5, Title: "Environment configuration", Snippet: "Load settings from .env files with godotenv."},
+	{ID: 6, Title: "Docker multi-stage builds", Snippet: "Produce small images by compiling Go in a builder stage."},
+	{ID: 7, Title: "Docker Compose", Snippet: "Run the API with healthchecks using docker compose."},
+	{ID: 8, Title: "Graceful shutdown in Go", Snippet: "Drain in-flight requests on SIGTERM with http.Server.Shutdown."},
+	{ID: 9, Title: "Prometheus metrics", Snippet: "Expose counters and histograms for scraping."},
+	{ID: 10, Title: "JWT authentication", Snippet: "Protect Gin routes with signed bearer tokens."},
+	{ID: 11, Title: "Rate limiting", Snippet: "Token bucket limiting per client IP with golang.org/x/time/rate."},
+	{ID: 12, Title: "Context cancellation in Go", Snippet: "Stop work when a request is canceled or times out."},
+}
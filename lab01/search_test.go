@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeSearcher records the arguments of its last search and returns canned
+// results or err
+type fakeSearcher struct {
+	results []Result
+	total   int
+	err     error
+
+	calls   int
+	query   string
+	limit   int
+	offset  int
+	afterID int64
+}
+
+func (f *fakeSearcher) Search(ctx context.Context, query string, limit, offset int) ([]Result, int, error) {
+	f.calls++
+	f.query, f.limit, f.offset = query, limit, offset
+	return f.results, f.total, f.err
+}
+
+func (f *fakeSearcher) SearchAfter(ctx context.Context, query string, limit int, afterID int64) ([]Result, int, error) {
+	f.calls++
+	f.query, f.limit, f.afterID = query, limit, afterID
+	return f.results, f.total, f.err
+}
+
+// searchRouter serves searchHandler over searcher at /search
+func searchRouter(searcher Searcher) *gin.Engine {
+	r := gin.New()
+	r.GET("/search", searchHandler(searcher, searcher, func() int { return 100 }))
+	return r
+}
+
+// get performs a GET of target on h and returns the recorded response
+func get(h http.Handler, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+func TestSearchHandlerPassesQueryThrough(t *testing.T) {
+	fake := &fakeSearcher{results: []Result{{ID: 7, Title: "Go"}}, total: 31}
+
+	w := get(searchRouter(fake), "/search?q=Go+Gin&limit=5&page=3")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if fake.query != "Go Gin" || fake.limit != 5 || fake.offset != 10 {
+		t.Fatalf("searcher got query %q, limit %d, offset %d; want \"Go Gin\", 5, 10", fake.query, fake.limit, fake.offset)
+	}
+
+	var resp SearchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Query != "Go Gin" || resp.Total != 31 || len(resp.Results) != 1 || resp.Results[0].ID != 7 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if resp.Limit != 5 || resp.Page != 3 || resp.Offset != 10 {
+		t.Fatalf("unexpected pagination %+v", resp.Pagination)
+	}
+}
+
+func TestSearchHandlerRejectsBadRequestsWithoutSearching(t *testing.T) {
+	for _, target := range []string{"/search", "/search?q=", "/search?q=go&limit=abc", "/search?q=go&format=pdf"} {
+		fake := &fakeSearcher{}
+		if w := get(searchRouter(fake), target); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, w.Code)
+		}
+		if fake.calls != 0 {
+			t.Errorf("%s: searcher was called", target)
+		}
+	}
+}
+
+func TestSearchHandlerMapsSearcherErrors(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errors.New("backend exploded"), http.StatusInternalServerError},
+		{ErrSearchUnavailable, http.StatusServiceUnavailable},
+		{context.DeadlineExceeded, http.StatusServiceUnavailable},
+		{context.Canceled, statusClientClosedRequest},
+	}
+	for _, tt := range tests {
+		w := get(searchRouter(&fakeSearcher{err: tt.err}), "/search?q=go")
+		if w.Code != tt.want {
+			t.Errorf("%v: status %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
+
+func TestSearchHandlerStopsWhenClientCancels(t *testing.T) {
+	slow := NewSlowSearcher(NewMemorySearcher(goDocuments(10)), 10*time.Second)
+	r := searchRouter(slow)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	w := httptest.NewRecorder()
+	start := time.Now()
+	r.ServeHTTP(w, httptest.NewRequestWithContext(ctx, http.MethodGet, "/search?q=go", nil))
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("handler took %s to notice the client left", elapsed)
+	}
+	if w.Code != statusClientClosedRequest {
+		t.Fatalf("status %d, want %d", w.Code, statusClientClosedRequest)
+	}
+}
+
+func TestSlowSearcherDelaysSearches(t *testing.T) {
+	slow := NewSlowSearcher(NewMemorySearcher(goDocuments(10)), 30*time.Millisecond)
+
+	start := time.Now()
+	results, _, err := slow.Search(context.Background(), "go", 5, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Fatalf("search returned after %s, want at least 30ms", elapsed)
+	}
+	if len(results) != 5 {
+		t.Fatalf("got %d results, want 5 from the wrapped searcher", len(results))
+	}
+}
+
+func TestMemorySearcherPages(t *testing.T) {
+	searcher := NewMemorySearcher([]Result{
+		{ID: 1, Title: "Go basics"},
+		{ID: 2, Title: "Rust"},
+		{ID: 3, Title: "Advanced go"},
+		{ID: 4, Title: "Gin", Snippet: "A Go web framework"},
+	})
+
+	tests := []struct {
+		name          string
+		limit, offset int
+		want          []int64
+	}{
+		{"first page", 2, 0, []int64{1, 3}},
+		{"second page", 2, 2, []int64{4}},
+		{"past the end", 2, 10, nil},
+		{"negative offset", 2, -10, []int64{1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, total, err := searcher.Search(context.Background(), "GO", tt.limit, tt.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != 3 {
+				t.Fatalf("total %d, want 3", total)
+			}
+			if len(results) != len(tt.want) {
+				t.Fatalf("got %v, want IDs %v", results, tt.want)
+			}
+			for i, id := range tt.want {
+				if results[i].ID != id {
+					t.Fatalf("got %v, want IDs %v", results, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchCursorFetchesNextPage(t *testing.T) {
+	docs := make([]Result, 5)
+	for i := range docs {
+		docs[i] = Result{ID: int64(i + 1), Title: "Go"}
+	}
+	router := searchRouter(NewMemorySearcher(docs))
+
+	var seen []int64
+	target := "/search?q=go&limit=2"
+	for range 3 {
+		w := get(router, target)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", target, w.Code, w.Body)
+		}
+		var resp SearchResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range resp.Results {
+			seen = append(seen, r.ID)
+		}
+		if resp.NextCursor == "" {
+			break
+		}
+		target = "/search?q=go&limit=2&cursor=" + resp.NextCursor
+	}
+
+	want := []int64{1, 2, 3, 4, 5}
+	if len(seen) != len(want) {
+		t.Fatalf("paged through %v, want %v", seen, want)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Fatalf("paged through %v, want %v", seen, want)
+		}
+	}
+}
+
+func TestSearchCursorIgnoresPageWithWarning(t *testing.T) {
+	fake := &fakeSearcher{}
+	w := get(searchRouter(fake), "/search?q=go&page=3&cursor="+encodeCursor(9))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if fake.afterID != 9 || fake.offset != 0 {
+		t.Fatalf("searcher got afterID %d, offset %d; want 9, 0", fake.afterID, fake.offset)
+	}
+	if w.Header().Get("Warning") == "" {
+		t.Fatal("missing Warning header")
+	}
+}
+
+func TestSearchRejectsOverflowingCursor(t *testing.T) {
+	fake := &fakeSearcher{}
+	w := get(searchRouter(fake), "/search?q=go&cursor="+encodeCursor(math.MaxInt64))
+	if w.Code != http.StatusBadRequest || fake.calls != 0 {
+		t.Fatalf("status %d after %d searches, want 400 without searching", w.Code, fake.calls)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// errPortInUse reports that another process is already bound to the address
+var errPortInUse = errors.New("address already in use")
+
+// ServerTimeouts bounds how long the HTTP server waits on each connection phase
+type ServerTimeouts struct {
+	Read       time.Duration
+	ReadHeader time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+}
+
+// newHTTPServer creates a server for handler on addr with the given timeouts.
+// Requests to streamPaths have their write deadline lifted, since streams are
+// expected to outlive WriteTimeout.
+func newHTTPServer(addr string, handler http.Handler, timeouts ServerTimeouts, streamPaths []string) *http.Server {
+	slog.Info("HTTP server timeouts",
+		"read", timeouts.Read,
+		"read_header", timeouts.ReadHeader,
+		"write", timeouts.Write,
+		"idle", timeouts.Idle,
+	)
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           withoutWriteDeadline(handler, streamPaths),
+		ReadTimeout:       timeouts.Read,
+		ReadHeaderTimeout: timeouts.ReadHeader,
+		WriteTimeout:      timeouts.Write,
+		IdleTimeout:       timeouts.Idle,
+	}
+}
+
+// listen opens a TCP listener on addr, reporting a taken port as
+// errPortInUse so callers can say something more useful than the raw
+// syscall error
+func listen(addr string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if errors.Is(err, syscall.EADDRINUSE) {
+		return nil, fmt.Errorf("%w: %s", errPortInUse, addr)
+	}
+	return ln, err
+}
+
+// mustListen is listen for startup: it exits with an actionable message when
+// envVar's port is taken. It returns the listener and the port actually
+// bound, which differs from the requested one when that was 0.
+func mustListen(addr, envVar string) (net.Listener, string) {
+	ln, err := listen(addr)
+	if errors.Is(err, errPortInUse) {
+		fatal("Port is already in use; set "+envVar+" to a free port or stop the process using it", "addr", addr)
+	}
+	if err != nil {
+		fatal("Failed to listen", "addr", addr, "error", err)
+	}
+	return ln, strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+}
+
+// withoutWriteDeadline clears the connection write deadline for requests to
+// paths before passing them to next
+func withoutWriteDeadline(next http.Handler, paths []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(paths, r.URL.Path) {
+			if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+				slog.Warn("Failed to clear write deadline for stream", "path", r.URL.Path, "error", err)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
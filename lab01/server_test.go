@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve runs server on a free local port until the test ends and returns
+// its address
+func serve(t *testing.T, server *http.Server) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(ln)
+	t.Cleanup(func() { server.Close() })
+	return ln.Addr().String()
+}
+
+func TestNewHTTPServerTimeouts(t *testing.T) {
+	timeouts := ServerTimeouts{Read: 15 * time.Second, ReadHeader: 5 * time.Second, Write: 45 * time.Second, Idle: time.Minute}
+	server := newHTTPServer(":9000", http.NotFoundHandler(), timeouts, nil)
+
+	if server.Addr != ":9000" || server.ReadTimeout != timeouts.Read || server.ReadHeaderTimeout != timeouts.ReadHeader ||
+		server.WriteTimeout != timeouts.Write || server.IdleTimeout != timeouts.Idle {
+		t.Fatalf("server is %+v", server)
+	}
+}
+
+func TestReadHeaderTimeoutDropsSlowClients(t *testing.T) {
+	timeouts := ServerTimeouts{Read: time.Second, ReadHeader: 50 * time.Millisecond, Write: time.Second, Idle: time.Second}
+	addr := serve(t, newHTTPServer("", http.NotFoundHandler(), timeouts, nil))
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// A slowloris client sends part of the headers and then stalls
+	if _, err := io.WriteString(conn, "GET /ping HTTP/1.1\r\nHost: localhost\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	start := time.Now()
+	// The server may answer 408 before closing, but it must close
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Fatalf("connection still open: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("connection dropped after %s", elapsed)
+	}
+}
+
+func TestStreamPathsOutliveWriteTimeout(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(150 * time.Millisecond)
+		io.WriteString(w, "late")
+	})
+	timeouts := ServerTimeouts{Read: time.Second, ReadHeader: time.Second, Write: 50 * time.Millisecond, Idle: time.Second}
+	addr := serve(t, newHTTPServer("", slow, timeouts, []string{"/events"}))
+
+	resp, err := http.Get("http://" + addr + "/events")
+	if err != nil {
+		t.Fatalf("stream path: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "late" {
+		t.Fatalf("stream path body is %q", body)
+	}
+
+	// Elsewhere the write deadline has passed by the time the handler writes
+	if resp, err := http.Get("http://" + addr + "/ping"); err == nil {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err == nil && string(body) == "late" {
+			t.Fatal("response written after WriteTimeout")
+		}
+	}
+}
+
+func TestListenReportsPortInUse(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+
+	if _, err := listen(taken.Addr().String()); !errors.Is(err, errPortInUse) {
+		t.Fatalf("got %v, want errPortInUse", err)
+	}
+}
+
+func TestMustListenPicksFreePortForZero(t *testing.T) {
+	ln, port := mustListen("127.0.0.1:0", "PORT")
+	defer ln.Close()
+	if port == "0" || port != strconv.Itoa(ln.Addr().(*net.TCPAddr).Port) {
+		t.Fatalf("reported port %s for listener on %s", port, ln.Addr())
+	}
+}
+
+func TestMustListenExitsWithFriendlyMessageWhenPortInUse(t *testing.T) {
+	// mustListen exits the process, so run it in a child test binary
+	if addr := os.Getenv("MUST_LISTEN_ADDR"); addr != "" {
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+		mustListen(addr, "PORT")
+		return
+	}
+
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestMustListenExitsWithFriendlyMessageWhenPortInUse$")
+	cmd.Env = append(os.Environ(), "MUST_LISTEN_ADDR="+taken.Addr().String())
+	out, err := cmd.CombinedOutput()
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Fatalf("got %v, want exit status 1; output: %s", err, out)
+	}
+	if !strings.Contains(string(out), "Port is already in use; set PORT to a free port") {
+		t.Fatalf("output lacks the friendly message: %s", out)
+	}
+}
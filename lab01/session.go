@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionCookieName is the cookie carrying the signed session ID
+const sessionCookieName = "session"
+
+// Session is a browser login, identified by a random ID kept in a cookie
+type Session struct {
+	ID        string
+	UserID    string
+	Role      string
+	ExpiresAt time.Time
+}
+
+// SessionStore keeps sessions in memory until they expire or are deleted.
+// Sessions don't survive a restart, and each instance has its own.
+type SessionStore struct {
+	mu         sync.Mutex
+	sessions   map[string]Session
+	ttl        time.Duration
+	lastPruned time.Time
+}
+
+// NewSessionStore returns a store whose sessions last ttl
+func NewSessionStore(ttl time.Duration) *SessionStore {
+	return &SessionStore{sessions: make(map[string]Session), ttl: ttl, lastPruned: time.Now()}
+}
+
+// Create starts a session for userID with role under a new random ID
+func (s *SessionStore) Create(userID, role string) Session {
+	id := make([]byte, 32)
+	rand.Read(id)
+	session := Session{
+		ID:        base64.RawURLEncoding.EncodeToString(id),
+		UserID:    userID,
+		Role:      role,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastPruned) > s.ttl {
+		for key, existing := range s.sessions {
+			if now.After(existing.ExpiresAt) {
+				delete(s.sessions, key)
+			}
+		}
+		s.lastPruned = now
+	}
+
+	s.sessions[session.ID] = session
+	return session
+}
+
+// Get returns the live session with id
+func (s *SessionStore) Get(id string) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || time.Now().After(session.ExpiresAt) {
+		return Session{}, false
+	}
+	return session, true
+}
+
+// Delete ends the session with id, if there is one
+func (s *SessionStore) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+}
+
+// signSessionID returns the cookie value for id: the ID followed by "." and
+// the base64url HMAC-SHA256 of it, keyed with secret
+func signSessionID(secret []byte, id string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id))
+	return id + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// sessionIDFromCookie returns the session ID in the request's session
+// cookie, and false when there's none or its signature doesn't match
+func sessionIDFromCookie(c *gin.Context, secret []byte) (string, bool) {
+	value, err := c.Cookie(sessionCookieName)
+	if err != nil {
+		return "", false
+	}
+	id, _, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(value), []byte(signSessionID(secret, id))) {
+		return "", false
+	}
+	return id, true
+}
+
+// setSessionCookie sends the cookie for session. It's HttpOnly so scripts
+// can't read it, Secure so it's only sent over HTTPS (and to localhost), and
+// SameSite=Lax so other sites can't make POSTs with it.
+func setSessionCookie(c *gin.Context, secret []byte, session Session) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    signSessionID(secret, session.ID),
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearSessionCookie tells the browser to drop the session cookie
+func clearSessionCookie(c *gin.Context) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// SessionAuth returns a middleware that loads the session named by the
+// session cookie and stores it, with its user and role, in the context, so
+// RequireRole works after it as it does after AuthRequired
+func SessionAuth(store *SessionStore, secret []byte) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := sessionIDFromCookie(c, secret)
+		if !ok {
+			RespondError(c, http.StatusUnauthorized, CodeUnauthorized, "missing or invalid session")
+			return
+		}
+		session, ok := store.Get(id)
+		if !ok {
+			RespondError(c, http.StatusUnauthorized, CodeUnauthorized, "session has ended")
+			return
+		}
+
+		SetSession(c, session)
+		SetUserID(c, session.UserID)
+		SetRole(c, session.Role)
+		c.Next()
+	}
+}
+
+// SessionResponse represents the response structure for the session endpoint
+type SessionResponse struct {
+	UserID    string `json:"user_id"`
+	Role      string `json:"role"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// sessionHandler returns the session loaded by SessionAuth
+//
+//	@Summary	Get the current session
+//	@Tags		auth
+//	@Produce	json
+//	@Success	200	{object}	SessionResponse
+//	@Failure	401	{object}	APIError
+//	@Router		/v1/session [get]
+func sessionHandler(c *gin.Context) {
+	session, _ := GetSession(c)
+	c.JSON(http.StatusOK, SessionResponse{
+		UserID:    session.UserID,
+		Role:      session.Role,
+		ExpiresAt: session.ExpiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// logoutHandler ends the session named by the session cookie and clears the
+// cookie. It succeeds without a session too, so logging out twice is fine.
+//
+//	@Summary	End the current session
+//	@Tags		auth
+//	@Success	204	"Session ended and cookie cleared"
+//	@Router		/v1/logout [post]
+func logoutHandler(store *SessionStore, secret []byte) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if id, ok := sessionIDFromCookie(c, secret); ok {
+			store.Delete(id)
+		}
+		clearSessionCookie(c)
+		c.Status(http.StatusNoContent)
+	}
+}
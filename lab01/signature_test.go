@@ -0,0 +1,154 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var testSignatureSecret = []byte("test-signature-secret")
+
+// signatureRouter serves POST /events behind VerifySignature, echoing the
+// body the handler reads
+func signatureRouter() *gin.Engine {
+	r := gin.New()
+	r.POST("/events", VerifySignature(testSignatureSecret, time.Minute), func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.String(http.StatusOK, "%s", body)
+	})
+	return r
+}
+
+// signedPost builds a POST of body to /events carrying the given signature
+// headers; empty values are left out
+func signedPost(body, signature, timestamp, nonce string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body))
+	for header, value := range map[string]string{SignatureHeader: signature, TimestampHeader: timestamp, NonceHeader: nonce} {
+		if value != "" {
+			req.Header.Set(header, value)
+		}
+	}
+	return req
+}
+
+func TestVerifySignature(t *testing.T) {
+	const body = `{"type":"user.created"}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		timestamp string
+		want      int
+	}{
+		{"valid", body, signRequest(testSignatureSecret, now, "n1", []byte(body)), now, http.StatusOK},
+		{"tampered body", `{"type":"user.deleted"}`, signRequest(testSignatureSecret, now, "n1", []byte(body)), now, http.StatusUnauthorized},
+		{"wrong secret", body, signRequest([]byte("other"), now, "n1", []byte(body)), now, http.StatusUnauthorized},
+		{"missing signature", body, "", now, http.StatusUnauthorized},
+		{"malformed signature", body, "sha256=not-hex", now, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			signatureRouter().ServeHTTP(w, signedPost(tt.body, tt.signature, tt.timestamp, "n1"))
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want == http.StatusOK && w.Body.String() != tt.body {
+				t.Fatalf("handler read %q, want the original body %q", w.Body, tt.body)
+			}
+		})
+	}
+}
+
+func TestVerifySignatureRequiresFreshTimestampAndNonce(t *testing.T) {
+	const body = `{"type":"user.created"}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10)
+	future := strconv.FormatInt(time.Now().Add(2*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		nonce     string
+		want      int
+	}{
+		{"fresh", now, "n1", http.StatusOK},
+		{"missing timestamp", "", "n1", http.StatusUnauthorized},
+		{"missing nonce", now, "", http.StatusUnauthorized},
+		{"malformed timestamp", "yesterday", "n1", http.StatusUnauthorized},
+		{"stale timestamp", stale, "n1", http.StatusUnauthorized},
+		{"future timestamp", future, "n1", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			signature := signRequest(testSignatureSecret, tt.timestamp, tt.nonce, []byte(body))
+			signatureRouter().ServeHTTP(w, signedPost(body, signature, tt.timestamp, tt.nonce))
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestVerifySignatureRejectsReplays(t *testing.T) {
+	const body = `{"type":"user.created"}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	r := signatureRouter()
+
+	send := func(nonce string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, signedPost(body, signRequest(testSignatureSecret, now, nonce, []byte(body)), now, nonce))
+		return w.Code
+	}
+	if got := send("once"); got != http.StatusOK {
+		t.Fatalf("first request: status %d", got)
+	}
+	if got := send("once"); got != http.StatusUnauthorized {
+		t.Fatalf("replayed request: status %d, want 401", got)
+	}
+	if got := send("twice"); got != http.StatusOK {
+		t.Fatalf("fresh nonce: status %d", got)
+	}
+}
+
+func TestVerifySignatureForgedRequestDoesNotBurnNonce(t *testing.T) {
+	const body = `{"type":"user.created"}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	r := signatureRouter()
+
+	forged := httptest.NewRecorder()
+	r.ServeHTTP(forged, signedPost(body, signRequest([]byte("guess"), now, "n1", []byte(body)), now, "n1"))
+	if forged.Code != http.StatusUnauthorized {
+		t.Fatalf("forged request: status %d, want 401", forged.Code)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, signedPost(body, signRequest(testSignatureSecret, now, "n1", []byte(body)), now, "n1"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("genuine request after a forgery with its nonce: status %d", w.Code)
+	}
+}
+
+func TestNonceCacheExpires(t *testing.T) {
+	cache := newNonceCache(20 * time.Millisecond)
+	if !cache.add("n") || cache.add("n") {
+		t.Fatal("nonce not recorded")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if !cache.add("n") {
+		t.Fatal("nonce still rejected after its TTL")
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// staticFiles holds the frontend assets baked into the binary, served unless
+// STATIC_DIR points at a directory on disk
+//
+//go:embed static
+var staticFiles embed.FS
+
+// StaticConfig controls serving frontend files
+type StaticConfig struct {
+	// Prefix is the URL path files are served under, e.g. /static
+	Prefix string
+	// Dir serves files from this directory instead of the embedded copy, so
+	// edits show up without a rebuild during development
+	Dir string
+	// MaxAge is sent in Cache-Control so browsers can reuse assets
+	MaxAge time.Duration
+}
+
+// registerStaticRoutes serves the embedded files, or cfg.Dir when set, under
+// cfg.Prefix. It does nothing if cfg.Dir doesn't exist, and fails if the
+// prefix would shadow a route that's already registered, so call it after
+// the API routes.
+func registerStaticRoutes(router *gin.Engine, cfg StaticConfig) error {
+	fsys, etag, source, err := staticSource(cfg.Dir)
+	if err != nil {
+		return err
+	}
+	if fsys == nil {
+		slog.Info("Static directory not found, static files disabled", "dir", cfg.Dir)
+		return nil
+	}
+
+	for _, route := range router.Routes() {
+		if route.Path == cfg.Prefix || strings.HasPrefix(route.Path, cfg.Prefix+"/") {
+			return fmt.Errorf("STATIC_PREFIX %q overlaps route %s %s", cfg.Prefix, route.Method, route.Path)
+		}
+	}
+
+	group := router.Group(cfg.Prefix, StaticCache(fsys, etag, cfg.MaxAge))
+	group.StaticFS("/", indexOnlyFS{http.FS(fsys)})
+	slog.Info("Serving static files", "prefix", cfg.Prefix, "source", source)
+	return nil
+}
+
+// staticSource returns the files to serve and how to tag them: dir from disk
+// when it's set, otherwise the embedded files. fsys is nil when dir doesn't
+// exist.
+func staticSource(dir string) (fsys fs.FS, etag func(name string, info fs.FileInfo) string, source string, err error) {
+	if dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			return nil, nil, "", nil
+		}
+		return os.DirFS(dir), func(_ string, info fs.FileInfo) string { return fileETag(info) }, dir, nil
+	}
+
+	embedded, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		return nil, nil, "", err
+	}
+	etags, err := contentETags(embedded)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("hash embedded static files: %w", err)
+	}
+	return embedded, func(name string, _ fs.FileInfo) string { return etags[name] }, "embedded", nil
+}
+
+// StaticCache returns a middleware setting Cache-Control and the ETag from
+// etag for the file in fsys being served. http.FileServer answers a matching
+// If-None-Match with 304.
+func StaticCache(fsys fs.FS, etag func(name string, info fs.FileInfo) string, maxAge time.Duration) gin.HandlerFunc {
+	cacheControl := "public, max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		name := strings.TrimPrefix(path.Clean("/"+c.Param("filepath")), "/")
+		if name == "" {
+			name = "."
+		}
+		info, err := fs.Stat(fsys, name)
+		if err == nil && info.IsDir() {
+			name = path.Join(name, "index.html")
+			info, err = fs.Stat(fsys, name)
+		}
+		if err == nil && info.Mode().IsRegular() {
+			c.Header("Cache-Control", cacheControl)
+			c.Header("ETag", etag(name, info))
+		}
+		c.Next()
+	}
+}
+
+// indexOnlyFS opens directories only when they contain an index.html, so
+// directory listings are never served. gin.Dir's own listing switch makes
+// gin pre-write a 404 status, which breaks redirects and 304s.
+type indexOnlyFS struct {
+	fs http.FileSystem
+}
+
+// Open implements http.FileSystem. gin checks the raw filepath parameter,
+// which keeps a directory's trailing slash that http.FS rejects, so the name
+// is cleaned first.
+func (f indexOnlyFS) Open(name string) (http.File, error) {
+	name = path.Clean("/" + name)
+	file, err := f.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		index, err := f.fs.Open(path.Join(name, "index.html"))
+		if err != nil {
+			file.Close()
+			return nil, os.ErrNotExist
+		}
+		index.Close()
+	}
+	return file, nil
+}
+
+// fileETag identifies a file version by its modification time and size
+func fileETag(info fs.FileInfo) string {
+	return `"` + strconv.FormatInt(info.ModTime().UnixNano(), 36) + "-" + strconv.FormatInt(info.Size(), 36) + `"`
+}
+
+// contentETags tags every file in fsys by a hash of its contents. Embedded
+// files have no modification time, and can only change with the binary, so
+// hashing them once at startup is enough.
+func contentETags(fsys fs.FS) (map[string]string, error) {
+	etags := make(map[string]string)
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		etags[name] = `"` + hex.EncodeToString(sum[:8]) + `"`
+		return nil
+	})
+	return etags, err
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// staticDir writes files into a temporary directory and returns it
+func staticDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// staticRouter serves cfg behind a /ping API route
+func staticRouter(t *testing.T, cfg StaticConfig) *gin.Engine {
+	t.Helper()
+	r := gin.New()
+	r.GET("/ping", pingHandler)
+	if err := registerStaticRoutes(r, cfg); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestStaticFilesServedWithCacheHeaders(t *testing.T) {
+	dir := staticDir(t, map[string]string{"app.js": "console.log('hi')", "docs/index.html": "<h1>docs</h1>"})
+	r := staticRouter(t, StaticConfig{Prefix: "/static", Dir: dir, MaxAge: time.Hour})
+
+	for target, want := range map[string]string{"/static/app.js": "console.log('hi')", "/static/docs/": "<h1>docs</h1>"} {
+		w := get(r, target)
+		if w.Code != http.StatusOK || w.Body.String() != want {
+			t.Fatalf("%s: status %d, body %q", target, w.Code, w.Body)
+		}
+		if got := w.Header().Get("Cache-Control"); got != "public, max-age=3600" {
+			t.Errorf("%s: Cache-Control is %q", target, got)
+		}
+		etag := w.Header().Get("ETag")
+		if etag == "" {
+			t.Fatalf("%s: no ETag", target)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("If-None-Match", etag)
+		revalidated := httptest.NewRecorder()
+		r.ServeHTTP(revalidated, req)
+		if revalidated.Code != http.StatusNotModified {
+			t.Errorf("%s: revalidating with its ETag got %d, want 304", target, revalidated.Code)
+		}
+	}
+}
+
+func TestStaticEmbeddedFiles(t *testing.T) {
+	want, err := staticFiles.ReadFile("static/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := staticRouter(t, StaticConfig{Prefix: "/static", MaxAge: time.Minute})
+
+	w := get(r, "/static/")
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), want) {
+		t.Fatalf("status %d, want the embedded index.html: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type is %q", got)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "public, max-age=60" {
+		t.Errorf("Cache-Control is %q", got)
+	}
+
+	// Embedded files have no modification time, so the ETag hashes the contents
+	sum := sha256.Sum256(want)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	if got := w.Header().Get("ETag"); got != etag {
+		t.Fatalf("ETag is %q, want %q", got, etag)
+	}
+	if w := conditionalRequest(r, http.MethodGet, "/static/", "If-None-Match", etag, ""); w.Code != http.StatusNotModified {
+		t.Fatalf("revalidating with its ETag got %d, want 304", w.Code)
+	}
+}
+
+func TestStaticDirOverridesEmbeddedFiles(t *testing.T) {
+	dir := staticDir(t, map[string]string{"index.html": "<h1>on disk</h1>"})
+	r := staticRouter(t, StaticConfig{Prefix: "/static", Dir: dir})
+
+	if w := get(r, "/static/"); w.Code != http.StatusOK || w.Body.String() != "<h1>on disk</h1>" {
+		t.Fatalf("status %d, body %q, want the file from STATIC_DIR", w.Code, w.Body)
+	}
+}
+
+func TestStaticDirectoriesWithoutIndexAreNotListed(t *testing.T) {
+	dir := staticDir(t, map[string]string{"img/logo.svg": "<svg/>"})
+	r := staticRouter(t, StaticConfig{Prefix: "/static", Dir: dir})
+
+	if w := get(r, "/static/img/"); w.Code != http.StatusNotFound {
+		t.Fatalf("directory listing: status %d, want 404", w.Code)
+	}
+	if w := get(r, "/static/missing.css"); w.Code != http.StatusNotFound {
+		t.Fatalf("missing file: status %d, want 404", w.Code)
+	}
+}
+
+func TestStaticMissingDirIsDisabled(t *testing.T) {
+	r := staticRouter(t, StaticConfig{Prefix: "/static", Dir: filepath.Join(t.TempDir(), "missing")})
+
+	if w := get(r, "/static/app.js"); w.Code != http.StatusNotFound {
+		t.Fatalf("status %d, want 404", w.Code)
+	}
+	if w := get(r, "/ping"); w.Code != http.StatusOK {
+		t.Fatalf("/ping: status %d", w.Code)
+	}
+}
+
+func TestStaticPrefixMustNotShadowRoutes(t *testing.T) {
+	r := gin.New()
+	r.GET("/ping", pingHandler)
+	r.GET("/ping/deep", pingHandler)
+
+	for _, prefix := range []string{"/ping", "/ping/deep"} {
+		if err := registerStaticRoutes(r, StaticConfig{Prefix: prefix, Dir: staticDir(t, nil)}); err == nil {
+			t.Errorf("prefix %s shadowing an API route was accepted", prefix)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout returns a middleware that gives each request a deadline of d. Handlers
+// see the deadline through c.Request.Context(); if they haven't finished when it
+// passes, the client gets a 503 and anything the handler writes later is dropped.
+//
+// Responses are buffered until the handler returns, so long-lived
+// connections such as WebSocket upgrades and the routes in exemptPaths
+// (e.g. streaming endpoints) are passed through untouched.
+func Timeout(d time.Duration, exemptPaths ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.IsWebsocket() || slices.Contains(exemptPaths, c.FullPath()) {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		writer := newTimeoutWriter(c.Writer, RequestIDFromContext(c))
+		c.Writer = writer
+
+		done := make(chan struct{})
+		var panicked any
+		go func() {
+			defer func() {
+				panicked = recover()
+				close(done)
+			}()
+			c.Next()
+		}()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				writer.timeout()
+			}
+			// Wait for the handler so the pooled gin.Context isn't reused under it
+			<-done
+		}
+
+		c.Writer = writer.ResponseWriter
+
+		// Re-panic on this goroutine so the recovery middleware can handle it
+		if panicked != nil {
+			panic(panicked)
+		}
+
+		writer.commit()
+	}
+}
+
+// timeoutWriter buffers a handler's response so it can be discarded in favour
+// of a 503 when the deadline passes
+type timeoutWriter struct {
+	gin.ResponseWriter
+
+	mu       sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	status   int
+	timedOut bool
+
+	requestID string
+}
+
+func newTimeoutWriter(w gin.ResponseWriter, requestID string) *timeoutWriter {
+	// Keep headers already set by earlier middleware such as X-Request-ID
+	return &timeoutWriter{ResponseWriter: w, header: w.Header().Clone(), requestID: requestID}
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.timedOut && w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(data)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.status == 0 {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.status != 0
+}
+
+// Flush is a no-op; the body is sent once the handler finishes
+func (w *timeoutWriter) Flush() {}
+
+// timeout sends the 503 response and discards anything written afterwards
+func (w *timeoutWriter) timeout() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.timedOut = true
+
+	body, _ := json.Marshal(APIError{
+		Code:      CodeTimeout,
+		Message:   "request timeout",
+		RequestID: w.requestID,
+	})
+	w.ResponseWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.ResponseWriter.WriteHeader(http.StatusServiceUnavailable)
+	w.ResponseWriter.Write(body)
+	w.ResponseWriter.Flush()
+}
+
+// commit copies the buffered response to the underlying writer
+func (w *timeoutWriter) commit() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut {
+		return
+	}
+
+	dst := w.ResponseWriter.Header()
+	for key, values := range w.header {
+		dst[key] = values
+	}
+
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.body.Len() > 0 {
+		w.ResponseWriter.Write(w.body.Bytes())
+	}
+}
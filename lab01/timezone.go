@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"time"
+	// Embed the IANA database so tz works in images without tzdata installed
+	_ "time/tzdata"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timezone returns a middleware that reads the optional tz query parameter,
+// an IANA time zone name such as America/New_York, and stores its location
+// for handlers to format timestamps in. Unknown names are rejected with 400.
+func Timezone() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, ok := c.GetQuery("tz")
+		if !ok {
+			c.Next()
+			return
+		}
+
+		// LoadLocation also accepts "" and "Local", which aren't IANA names
+		loc, err := time.LoadLocation(name)
+		if err != nil || name == "" || name == "Local" {
+			RespondError(c, http.StatusBadRequest, CodeInvalidQuery,
+				"Query parameter 'tz' must be an IANA time zone name such as America/New_York")
+			return
+		}
+
+		SetLocation(c, loc)
+		c.Next()
+	}
+}
+
+// In returns a copy of u with its timestamps expressed in loc
+func (u User) In(loc *time.Location) User {
+	u.CreatedAt = u.CreatedAt.In(loc)
+	u.UpdatedAt = u.UpdatedAt.In(loc)
+	if u.DeletedAt != nil {
+		deletedAt := u.DeletedAt.In(loc)
+		u.DeletedAt = &deletedAt
+	}
+	return u
+}
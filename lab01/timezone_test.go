@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// timezoneRouter serves /user/:id over a store holding one user, and
+// /health, behind the Timezone middleware
+func timezoneRouter(t *testing.T) (*gin.Engine, User) {
+	store := NewMemoryUserStore()
+	user := seedUsers(t, store, "ann")[0]
+	r := gin.New()
+	r.Use(Timezone())
+	r.GET("/user/:id", getUserHandler(store))
+	r.GET("/health", healthHandler)
+	return r, user
+}
+
+func TestTimezoneRendersTimestampsWithOffset(t *testing.T) {
+	prevStart := startTime
+	startTime = time.Now()
+	t.Cleanup(func() { startTime = prevStart })
+	r, user := timezoneRouter(t)
+
+	tests := []struct {
+		tz     string
+		suffix string
+	}{
+		{"", "Z"},
+		{"?tz=UTC", "Z"},
+		// Kolkata has no daylight saving, so its offset never changes
+		{"?tz=Asia/Kolkata", "+05:30"},
+	}
+	for _, tt := range tests {
+		w := get(r, "/user/1"+tt.tz)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", tt.tz, w.Code, w.Body)
+		}
+		var body struct {
+			CreatedAt string `json:"created_at"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(body.CreatedAt, tt.suffix) {
+			t.Errorf("%s: created_at is %q, want the %s offset", tt.tz, body.CreatedAt, tt.suffix)
+		}
+		// Only the rendering changes, not the instant
+		createdAt, err := time.Parse(time.RFC3339Nano, body.CreatedAt)
+		if err != nil || !createdAt.Equal(user.CreatedAt) {
+			t.Errorf("%s: created_at %q is not the stored %s", tt.tz, body.CreatedAt, user.CreatedAt)
+		}
+
+		var health HealthResponse
+		if err := json.Unmarshal(get(r, "/health"+tt.tz).Body.Bytes(), &health); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(health.StartedAt, tt.suffix) {
+			t.Errorf("%s: started_at is %q, want the %s offset", tt.tz, health.StartedAt, tt.suffix)
+		}
+	}
+}
+
+func TestTimezoneRejectsUnknownZones(t *testing.T) {
+	r, _ := timezoneRouter(t)
+
+	for _, tz := range []string{"Not/AZone", "", "Local", "Europe%2FAtlantis"} {
+		for _, path := range []string{"/user/1", "/health"} {
+			w := get(r, path+"?tz="+tz)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s?tz=%s: status %d, want 400", path, tz, w.Code)
+				continue
+			}
+			var body APIError
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != CodeInvalidQuery || !strings.Contains(body.Message, "IANA time zone") {
+				t.Errorf("%s?tz=%s: got %+v", path, tz, body)
+			}
+		}
+	}
+}
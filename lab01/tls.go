@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CertReloader serves a TLS certificate that can be swapped while the server
+// runs, so renewed certificates don't need a restart
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+}
+
+// NewCertReloader loads the initial certificate from certFile and keyFile
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the certificate files again and swaps them in if the pair is
+// valid and currently in date; the previous certificate is kept otherwise
+func (r *CertReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS key pair: %w", err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("parse TLS certificate: %w", err)
+	}
+	if now := time.Now(); now.After(leaf.NotAfter) || now.Before(leaf.NotBefore) {
+		return fmt.Errorf("TLS certificate is only valid from %s to %s",
+			leaf.NotBefore.Format(time.RFC3339), leaf.NotAfter.Format(time.RFC3339))
+	}
+	cert.Leaf = leaf
+
+	r.cert.Store(&cert)
+	return nil
+}
+
+// Current returns the certificate being served
+func (r *CertReloader) Current() *tls.Certificate {
+	return r.cert.Load()
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// loadClientCAs reads the PEM bundle of CAs trusted to sign client certificates
+func loadClientCAs(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read TLS_CLIENT_CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("TLS_CLIENT_CA %s contains no PEM certificates", path)
+	}
+	return pool, nil
+}
+
+// ClientCert exposes the subject CN of a verified client certificate to
+// handlers. Verification itself happens during the TLS handshake, so this
+// only reads the result.
+func ClientCert() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if state := c.Request.TLS; state != nil && len(state.VerifiedChains) > 0 {
+			SetClientCN(c, state.VerifiedChains[0][0].Subject.CommonName)
+		}
+		c.Next()
+	}
+}
+
+// httpsRedirectHandler permanently redirects every request to the same
+// host and path on the HTTPS port
+func httpsRedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testCert is a generated certificate with its key
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert issues a certificate for cn valid from notBefore to notAfter,
+// signed by parent or self-signed when parent is nil. isCA makes it able to
+// sign others.
+func newTestCert(t *testing.T, cn string, parent *testCert, isCA bool, notBefore, notAfter time.Time) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+// validTestCert issues a certificate for cn that's valid for the next hour
+func validTestCert(t *testing.T, cn string, parent *testCert, isCA bool) *testCert {
+	return newTestCert(t, cn, parent, isCA, time.Now().Add(-time.Minute), time.Now().Add(time.Hour))
+}
+
+// certPEM encodes the certificate
+func (c *testCert) certPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})
+}
+
+// write saves the certificate and key as PEM files in dir, returning their
+// paths
+func (c *testCert) write(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, c.certPEM(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// tlsKeyPair returns the certificate and key for use as a client certificate
+func (c *testCert) tlsKeyPair() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key, Leaf: c.cert}
+}
+
+// serveTLS serves handler over TLS with config on a free local port until
+// the test ends and returns its address. httptest.Server isn't used because
+// its own certificate would take precedence over GetCertificate.
+func serveTLS(t *testing.T, config *tls.Config, handler http.Handler) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: handler}
+	go server.Serve(ln)
+	t.Cleanup(func() { server.Close() })
+	return ln.Addr().String()
+}
+
+// servedCN handshakes with addr and returns the CN of the certificate served
+func servedCN(t *testing.T, addr string) string {
+	t.Helper()
+	conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+}
+
+func TestCertReloaderSwapsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := validTestCert(t, "first", nil, false).write(t, dir)
+	certs, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := serveTLS(t, &tls.Config{GetCertificate: certs.GetCertificate}, http.NotFoundHandler())
+
+	if cn := servedCN(t, addr); cn != "first" {
+		t.Fatalf("serving %q, want first", cn)
+	}
+
+	validTestCert(t, "second", nil, false).write(t, dir)
+	if err := certs.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if cn := servedCN(t, addr); cn != "second" {
+		t.Fatalf("after reload serving %q, want second", cn)
+	}
+
+	// Certificates that are expired, not yet valid or unreadable are
+	// rejected, and the current one is kept
+	invalid := map[string]func(){
+		"expired": func() {
+			newTestCert(t, "expired", nil, false, time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour)).write(t, dir)
+		},
+		"not yet valid": func() {
+			newTestCert(t, "future", nil, false, time.Now().Add(time.Hour), time.Now().Add(2*time.Hour)).write(t, dir)
+		},
+		"corrupt": func() {
+			if err := os.WriteFile(certFile, []byte("not a certificate"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		},
+	}
+	for name, write := range invalid {
+		write()
+		if err := certs.Reload(); err == nil {
+			t.Errorf("%s certificate reloaded without an error", name)
+		}
+		if cn := servedCN(t, addr); cn != "second" {
+			t.Errorf("after a %s certificate serving %q, want second", name, cn)
+		}
+	}
+}
+
+func TestNewCertReloaderRequiresValidPair(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewCertReloader(filepath.Join(dir, "missing.pem"), filepath.Join(dir, "missing-key.pem")); err == nil {
+		t.Fatal("missing files loaded without an error")
+	}
+
+	certFile, _ := validTestCert(t, "cert", nil, false).write(t, dir)
+	_, otherKey := validTestCert(t, "other", nil, false).write(t, t.TempDir())
+	if _, err := NewCertReloader(certFile, otherKey); err == nil {
+		t.Fatal("mismatched key loaded without an error")
+	}
+}
+
+// writePEM writes certs as a PEM bundle in a temporary file and returns it
+func writePEM(t *testing.T, certs ...*testCert) string {
+	t.Helper()
+	var bundle []byte
+	for _, cert := range certs {
+		bundle = append(bundle, cert.certPEM()...)
+	}
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, bundle, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestClientCertAuthentication(t *testing.T) {
+	ca := validTestCert(t, "lab01 test CA", nil, true)
+	clientCAs, err := loadClientCAs(writePEM(t, ca))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The server is configured the way main does when TLS_CLIENT_CA is set
+	serverCert := validTestCert(t, "localhost", nil, false)
+	r := gin.New()
+	r.GET("/whoami", ClientCert(), func(c *gin.Context) { c.String(http.StatusOK, ClientCNFromContext(c)) })
+	addr := serveTLS(t, &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{serverCert.tlsKeyPair()},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}, r)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCert.cert)
+	// whoami requests /whoami presenting clientCerts
+	whoami := func(clientCerts ...tls.Certificate) (string, error) {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "localhost", Certificates: clientCerts},
+		}}
+		defer client.CloseIdleConnections()
+		resp, err := client.Get("https://" + addr + "/whoami")
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	cn, err := whoami(validTestCert(t, "billing-service", ca, false).tlsKeyPair())
+	if err != nil || cn != "billing-service" {
+		t.Fatalf("trusted client: got %q, %v; want its CN in the context", cn, err)
+	}
+
+	untrustedCA := validTestCert(t, "other CA", nil, true)
+	if _, err := whoami(validTestCert(t, "intruder", untrustedCA, false).tlsKeyPair()); err == nil {
+		t.Fatal("client certificate from an untrusted CA was accepted")
+	}
+	if _, err := whoami(validTestCert(t, "self-signed", nil, false).tlsKeyPair()); err == nil {
+		t.Fatal("self-signed client certificate was accepted")
+	}
+	if _, err := whoami(); err == nil {
+		t.Fatal("request without a client certificate was accepted")
+	}
+}
+
+func TestClientCertWithoutVerifiedChain(t *testing.T) {
+	r := gin.New()
+	r.GET("/whoami", ClientCert(), func(c *gin.Context) { c.String(http.StatusOK, ClientCNFromContext(c)) })
+
+	if w := get(r, "/whoami"); w.Code != http.StatusOK || w.Body.String() != "" {
+		t.Fatalf("plain HTTP request: got %d %q, want no client CN", w.Code, w.Body)
+	}
+}
+
+func TestLoadClientCAs(t *testing.T) {
+	if _, err := loadClientCAs(filepath.Join(t.TempDir(), "missing.pem")); err == nil {
+		t.Fatal("missing bundle loaded without an error")
+	}
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(empty, []byte("no certificates here"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadClientCAs(empty); err == nil {
+		t.Fatal("bundle without certificates loaded without an error")
+	}
+}
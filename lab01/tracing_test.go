@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// tracedRouter serves /ping and /user/:id with the tracing middleware main
+// installs, exporting spans to an in-memory exporter
+func tracedRouter(t *testing.T) (*gin.Engine, *tracetest.InMemoryExporter) {
+	t.Helper()
+	if _, err := initTracing(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	r := gin.New()
+	r.Use(RequestID(), otelgin.Middleware(serviceName, otelgin.WithTracerProvider(provider)), TraceRequestID())
+	r.GET("/ping", pingHandler)
+	r.GET("/user/:id", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+	return r, exporter
+}
+
+// spanAttribute returns the value of the first of keys set on span
+func spanAttribute(span tracetest.SpanStub, keys ...attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		for _, key := range keys {
+			if kv.Key == key {
+				return kv.Value, true
+			}
+		}
+	}
+	return attribute.Value{}, false
+}
+
+// statusKeys are the status code attribute names of the old and new HTTP
+// semantic conventions; otelgin emits one depending on OTEL_SEMCONV_STABILITY_OPT_IN
+var statusKeys = []attribute.Key{"http.status_code", "http.response.status_code"}
+
+func TestTracingRecordsPingSpan(t *testing.T) {
+	r, exporter := tracedRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set(RequestIDHeader, "req-123")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "/ping" {
+		t.Fatalf("span is named %q", span.Name)
+	}
+	if id, _ := spanAttribute(span, "request.id"); id.AsString() != "req-123" {
+		t.Fatalf("request.id is %q", id.AsString())
+	}
+	if status, ok := spanAttribute(span, statusKeys...); !ok || status.AsInt64() != http.StatusOK {
+		t.Fatalf("status attribute is %v", status.AsInt64())
+	}
+}
+
+func TestTracingNamesSpansByRouteTemplate(t *testing.T) {
+	r, exporter := tracedRouter(t)
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user/42", nil))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "/user/:id" {
+		t.Fatalf("spans are %v", spans)
+	}
+	if status, _ := spanAttribute(spans[0], statusKeys...); status.AsInt64() != http.StatusNotFound {
+		t.Fatalf("status attribute is %v", status.AsInt64())
+	}
+}
+
+func TestTracingContinuesIncomingTrace(t *testing.T) {
+	r, exporter := tracedRouter(t)
+
+	const traceID, parentID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+	if got := spans[0].SpanContext.TraceID().String(); got != traceID {
+		t.Fatalf("trace ID is %s, want %s", got, traceID)
+	}
+	if got := spans[0].Parent.SpanID().String(); got != parentID || !spans[0].Parent.IsRemote() {
+		t.Fatalf("parent is %s, want remote %s", got, parentID)
+	}
+}
+
+func TestInitTracingWithoutEndpointIsNoop(t *testing.T) {
+	before := otel.GetTracerProvider()
+	shutdown, err := initTracing(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otel.GetTracerProvider() != before {
+		t.Fatal("tracer provider replaced without an endpoint")
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
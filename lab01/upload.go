@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// multipartOverhead allows for form boundaries and headers around the file
+const multipartOverhead = 1 << 20
+
+// unsafeFilenameChars matches anything not allowed in a stored file name
+var unsafeFilenameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// UploadConfig controls where and what may be uploaded
+type UploadConfig struct {
+	Dir               string
+	MaxBytes          int64
+	AllowedExtensions []string
+	// Retention is how long uploads are kept; zero keeps them forever
+	Retention time.Duration
+}
+
+// UploadResponse represents the response structure for the upload endpoint
+type UploadResponse struct {
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+}
+
+// uploadHandler stores a multipart "file" field in the upload directory
+//
+//	@Summary	Upload a file
+//	@Tags		files
+//	@Accept		multipart/form-data
+//	@Produce	json
+//	@Security	BearerAuth
+//	@Param		file	formData	file	true	"File to upload"
+//	@Success	201		{object}	UploadResponse
+//	@Failure	400		{object}	APIError
+//	@Failure	413		{object}	APIError
+//	@Router		/v1/upload [post]
+func uploadHandler(cfg UploadConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Stop reading early instead of buffering an arbitrarily large body
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, cfg.MaxBytes+multipartOverhead)
+
+		file, err := c.FormFile("file")
+		if err != nil {
+			if isBodyTooLarge(err) {
+				respondFileTooLarge(c, cfg.MaxBytes)
+				return
+			}
+			RespondError(c, http.StatusBadRequest, CodeInvalidRequest, "Form field 'file' is required")
+			return
+		}
+
+		if file.Size > cfg.MaxBytes {
+			respondFileTooLarge(c, cfg.MaxBytes)
+			return
+		}
+
+		ext := strings.ToLower(filepath.Ext(file.Filename))
+		if !slices.Contains(cfg.AllowedExtensions, ext) {
+			RespondErrorWithDetails(c, http.StatusBadRequest, CodeInvalidRequest,
+				fmt.Sprintf("File extension '%s' is not allowed", ext),
+				map[string]any{"allowed": cfg.AllowedExtensions},
+			)
+			return
+		}
+
+		filename := storedFilename(file.Filename)
+		if err := c.SaveUploadedFile(file, filepath.Join(cfg.Dir, filename)); err != nil {
+			RespondError(c, http.StatusInternalServerError, CodeInternal, "Failed to store file")
+			return
+		}
+
+		c.JSON(http.StatusCreated, UploadResponse{
+			Filename: filename,
+			Size:     file.Size,
+		})
+	}
+}
+
+// storedFilename builds a unique, path-safe name that keeps the original
+// base name for readability
+func storedFilename(original string) string {
+	base := filepath.Base(original)
+	ext := strings.ToLower(filepath.Ext(base))
+	name := unsafeFilenameChars.ReplaceAllString(strings.TrimSuffix(base, filepath.Ext(base)), "_")
+	name = strings.Trim(name, "._")
+	if name == "" {
+		name = "file"
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+
+	return uuid.NewString() + "-" + name + ext
+}
+
+func respondFileTooLarge(c *gin.Context, maxBytes int64) {
+	RespondError(c, http.StatusRequestEntityTooLarge, CodePayloadTooLarge,
+		fmt.Sprintf("File exceeds the maximum size of %d bytes", maxBytes))
+}
+
+// cleanupUploadsTask returns a scheduled task deleting uploads in dir that
+// were last modified more than retention ago
+func cleanupUploadsTask(dir string, retention time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+
+		cutoff := time.Now().Add(-retention)
+		removed := 0
+		for _, entry := range entries {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			info, err := entry.Info()
+			if err != nil || !info.Mode().IsRegular() || info.ModTime().After(cutoff) {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+				return err
+			}
+			removed++
+		}
+
+		if removed > 0 {
+			slog.Info("Removed expired uploads", "count", removed)
+		}
+		return nil
+	}
+}
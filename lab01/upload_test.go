@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uploadRouter serves POST /upload into dir, allowing up to maxBytes of
+// .txt and .png files
+func uploadRouter(dir string, maxBytes int64) *gin.Engine {
+	r := gin.New()
+	r.POST("/upload", uploadHandler(UploadConfig{Dir: dir, MaxBytes: maxBytes, AllowedExtensions: []string{".txt", ".png"}}))
+	return r
+}
+
+// postFile uploads contents as the form field named field on h
+func postFile(t *testing.T, h http.Handler, field, filename string, contents []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile(field, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(contents)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestUploadStoresFile(t *testing.T) {
+	dir := t.TempDir()
+	contents := []byte("hello, upload")
+
+	w := postFile(t, uploadRouter(dir, 1024), "file", "notes.txt", contents)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	var resp UploadResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Size != int64(len(contents)) || !strings.HasSuffix(resp.Filename, "-notes.txt") {
+		t.Fatalf("response is %+v", resp)
+	}
+
+	stored, err := os.ReadFile(filepath.Join(dir, resp.Filename))
+	if err != nil || !bytes.Equal(stored, contents) {
+		t.Fatalf("stored %q, %v", stored, err)
+	}
+
+	// The same name uploaded again doesn't overwrite the first file
+	again := postFile(t, uploadRouter(dir, 1024), "file", "notes.txt", contents)
+	var second UploadResponse
+	json.Unmarshal(again.Body.Bytes(), &second)
+	if second.Filename == resp.Filename {
+		t.Fatalf("both uploads stored as %s", resp.Filename)
+	}
+}
+
+func TestUploadRejectsTooLargeFile(t *testing.T) {
+	dir := t.TempDir()
+	r := uploadRouter(dir, 1024)
+
+	// Just over the limit, read in full but too big to keep
+	for _, size := range []int{1025, 2 * multipartOverhead} {
+		w := postFile(t, r, "file", "big.txt", bytes.Repeat([]byte("a"), size))
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("%d bytes: got %d, want 413", size, w.Code)
+		}
+		var body APIError
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Code != CodePayloadTooLarge || !strings.Contains(body.Message, "1024 bytes") {
+			t.Fatalf("%d bytes: body is %+v", size, body)
+		}
+	}
+	if w := postFile(t, r, "file", "ok.txt", bytes.Repeat([]byte("a"), 1024)); w.Code != http.StatusCreated {
+		t.Fatalf("file at the limit: got %d", w.Code)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("upload directory holds %d files, want 1", len(entries))
+	}
+}
+
+func TestUploadRejectsBadRequests(t *testing.T) {
+	dir := t.TempDir()
+	r := uploadRouter(dir, 1024)
+
+	w := postFile(t, r, "file", "script.sh", []byte("echo hi"))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "'.sh' is not allowed") {
+		t.Fatalf("disallowed extension: got %d: %s", w.Code, w.Body)
+	}
+	if w := postFile(t, r, "attachment", "notes.txt", []byte("hi")); w.Code != http.StatusBadRequest {
+		t.Fatalf("wrong field: got %d", w.Code)
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("rejected uploads were stored: %v", entries)
+	}
+}
+
+func TestStoredFilename(t *testing.T) {
+	tests := map[string]string{
+		"notes.txt":            "-notes.txt",
+		"../../etc/passwd.txt": "-passwd.txt",
+		"My Photo (1).PNG":     "-My_Photo_1.png",
+		"..png":                "-file.png",
+		"résumé.pdf":           "-r_sum.pdf",
+		`C:\Users\ann\cv.txt`:  "-C_Users_ann_cv.txt",
+	}
+	for original, suffix := range tests {
+		got := storedFilename(original)
+		if !strings.HasSuffix(got, suffix) || strings.ContainsAny(got, `/\ `) {
+			t.Errorf("storedFilename(%q) = %q, want a ...%s", original, got, suffix)
+		}
+	}
+	if storedFilename("a.txt") == storedFilename("a.txt") {
+		t.Fatal("stored names collide")
+	}
+}
@@ -0,0 +1,609 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateUserRequest represents the request body for creating a user
+type CreateUserRequest struct {
+	Username string `json:"username" binding:"required,username"`
+	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	// Phone is optional, in E.164 format
+	Phone string `json:"phone" binding:"omitempty,phone"`
+	// Password is optional; only its bcrypt hash is stored
+	Password string `json:"password" binding:"omitempty,min=8,max=72"`
+}
+
+// UserRequest represents the request body for replacing a user
+type UserRequest struct {
+	Name  string `json:"name" binding:"required"`
+	Email string `json:"email" binding:"required,email"`
+	// Phone is cleared when omitted
+	Phone string `json:"phone" binding:"omitempty,phone"`
+}
+
+// UserPatchRequest is a sparse update; nil fields are left unchanged
+type UserPatchRequest struct {
+	Name  *string `json:"name" binding:"omitnil,min=1"`
+	Email *string `json:"email" binding:"omitnil,email"`
+	// Phone is cleared by null or ""
+	Phone NullableString `json:"phone" binding:"omitempty,phone" swaggertype:"string"`
+}
+
+// NullableString is a request field that tells an absent value apart from an
+// explicit null, which a *string can't: both leave the pointer nil
+type NullableString struct {
+	// Set is true when the field was present, even as null
+	Set bool
+	// Value is the string given, or "" for null
+	Value string
+}
+
+func (n *NullableString) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if string(data) == "null" {
+		n.Value = ""
+		return nil
+	}
+	return json.Unmarshal(data, &n.Value)
+}
+
+// BatchUsersRequest lists the IDs of users to fetch in one call
+type BatchUsersRequest struct {
+	IDs []int64 `json:"ids" binding:"required,min=1,dive,min=1"`
+}
+
+// UserListResponse is a page of users
+type UserListResponse struct {
+	Pagination
+	Total int    `json:"total"`
+	Users []User `json:"users"`
+}
+
+// createUserHandler stores a new user, hashing the password, if one is
+// given, with bcrypt at cost
+//
+//	@Summary	Create a user
+//	@Tags		users
+//	@Accept		json
+//	@Produce	json
+//	@Security	BearerAuth
+//	@Param		Idempotency-Key	header		string				false	"Replays the original response when a request is retried"
+//	@Param		body			body		CreateUserRequest	true	"User to create"
+//	@Param		tz				query		string				false	"IANA time zone for timestamps, e.g. America/New_York"	default(UTC)
+//	@Success	201				{object}	User
+//	@Failure	400				{object}	APIError
+//	@Failure	401				{object}	APIError
+//	@Failure	409				{object}	APIError
+//	@Failure	413				{object}	APIError
+//	@Router		/v1/user [post]
+func createUserHandler(store UserStore, webhooks *WebhookDispatcher, jobs *JobQueue, audit *AuditLogger, cost int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateUserRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+
+		// max=72 counts characters, bcrypt's limit is in bytes
+		if len(req.Password) > maxPasswordBytes {
+			RespondErrorWithDetails(c, http.StatusBadRequest, CodeInvalidRequest, "Request body failed validation",
+				map[string]any{"password": validationMessages["max"]})
+			return
+		}
+
+		user := User{Username: req.Username, Name: req.Name, Email: req.Email, Phone: req.Phone}
+		if req.Password != "" {
+			hash, err := HashPassword(req.Password, cost)
+			if err != nil {
+				RespondError(c, http.StatusInternalServerError, CodeInternal, "Failed to create user")
+				return
+			}
+			user.PasswordHash = hash
+		}
+
+		user, err := store.Create(c.Request.Context(), user)
+		if errors.Is(err, ErrUsernameTaken) {
+			respondUserError(c, err)
+			return
+		}
+		if err != nil {
+			RespondError(c, http.StatusInternalServerError, CodeInternal, "Failed to create user")
+			return
+		}
+
+		audit.Record(c, AuditCreate, "user", strconv.FormatInt(user.ID, 10))
+		webhooks.Dispatch("user.created", user)
+		if err := jobs.Enqueue(welcomeEmailJob(user)); err != nil {
+			slog.Warn("Failed to queue welcome email", "user_id", user.ID, "error", err)
+		}
+
+		c.Header("ETag", versionETag(user.Version, LocationFromContext(c)))
+		RespondData(c, http.StatusCreated, user.In(LocationFromContext(c)))
+	}
+}
+
+// getUserHandler demonstrates path parameters. Responses carry an ETag, and
+// a matching If-None-Match returns 304 without a body. Soft-deleted users are
+// only returned with include_deleted=true.
+//
+//	@Summary	Get a user by ID
+//	@Tags		users
+//	@Produce	json
+//	@Param		id				path		int		true	"User ID"
+//	@Param		include_deleted	query		bool	false	"Return the user even if soft-deleted"
+//	@Param		If-None-Match	header		string	false	"ETag from a previous response"
+//	@Param		tz				query		string	false	"IANA time zone for timestamps, e.g. America/New_York"	default(UTC)
+//	@Success	200				{object}	User
+//	@Header		200				{string}	ETag	"Current user version"
+//	@Success	304				"Not modified"
+//	@Failure	400				{object}	APIError
+//	@Failure	404				{object}	APIError
+//	@Router		/v1/user/{id} [get]
+func getUserHandler(store UserStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := parseIDParam(c, "id")
+		if !ok {
+			return
+		}
+		includeDeleted, ok := parseIncludeDeleted(c)
+		if !ok {
+			return
+		}
+
+		user, err := store.Get(c.Request.Context(), id)
+		if err != nil {
+			respondUserError(c, err)
+			return
+		}
+		if user.DeletedAt != nil && !includeDeleted {
+			respondUserNotFound(c)
+			return
+		}
+
+		etag := versionETag(user.Version, LocationFromContext(c))
+		c.Header("ETag", etag)
+
+		if match := c.GetHeader("If-None-Match"); match != "" && etagMatches(match, etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
+		RespondData(c, http.StatusOK, user.In(LocationFromContext(c)))
+	}
+}
+
+// listUsersHandler lists users, optionally filtered by a name substring and
+// sorted by name, creation or update time. Responses carry Last-Modified, the time of
+// the most recent change to any user, and If-Modified-Since at or after it
+// returns 304.
+//
+//	@Summary	List users
+//	@Tags		users
+//	@Produce	json
+//	@Param		name				query		string	false	"Case-insensitive name substring"
+//	@Param		sort				query		string	false	"Sort field"	Enums(name, created_at, updated_at)	default(created_at)
+//	@Param		order				query		string	false	"Sort order"	Enums(asc, desc)					default(asc)
+//	@Param		limit				query		int		false	"Page size"		default(10)
+//	@Param		page				query		int		false	"Page number"	default(1)
+//	@Param		include_deleted		query		bool	false	"Also list soft-deleted users"
+//	@Param		If-Modified-Since	header		string	false	"Last-Modified from a previous response"
+//	@Param		tz					query		string	false	"IANA time zone for timestamps, e.g. America/New_York"	default(UTC)
+//	@Success	200					{object}	UserListResponse
+//	@Header		200					{string}	Last-Modified	"Time of the latest change to any user"
+//	@Success	304					"Not modified"
+//	@Failure	400					{object}	APIError
+//	@Router		/v1/users [get]
+func listUsersHandler(store UserStore, maxLimit func() int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sortBy := c.DefaultQuery("sort", "created_at")
+		if !slices.Contains(userSortFields, sortBy) {
+			RespondError(c, http.StatusBadRequest, CodeInvalidQuery, "Query parameter 'sort' must be one of "+strings.Join(userSortFields, ", "))
+			return
+		}
+
+		order := c.DefaultQuery("order", "asc")
+		if order != "asc" && order != "desc" {
+			RespondError(c, http.StatusBadRequest, CodeInvalidQuery, "Query parameter 'order' must be asc or desc")
+			return
+		}
+
+		pagination, err := parsePagination(c, maxLimit())
+		if err != nil {
+			RespondError(c, http.StatusBadRequest, CodeInvalidQuery, err.Error())
+			return
+		}
+
+		includeDeleted, ok := parseIncludeDeleted(c)
+		if !ok {
+			return
+		}
+
+		lastModified, err := store.LastModified(c.Request.Context())
+		if err != nil {
+			respondUserError(c, err)
+			return
+		}
+		if !lastModified.IsZero() {
+			c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+			if notModifiedSince(c, lastModified) {
+				c.Status(http.StatusNotModified)
+				return
+			}
+		}
+
+		users, total, err := store.List(c.Request.Context(), UserListOptions{
+			Name:           c.Query("name"),
+			SortBy:         sortBy,
+			Descending:     order == "desc",
+			Limit:          pagination.Limit,
+			Offset:         pagination.Offset,
+			IncludeDeleted: includeDeleted,
+		})
+		if err != nil {
+			respondUserError(c, err)
+			return
+		}
+		if users == nil {
+			users = []User{}
+		}
+		loc := LocationFromContext(c)
+		for i := range users {
+			users[i] = users[i].In(loc)
+		}
+
+		RespondList(c, http.StatusOK, UserListResponse{
+			Pagination: pagination,
+			Total:      total,
+			Users:      users,
+		}, users, ListMeta{Pagination: pagination, Total: total})
+	}
+}
+
+// batchGetUsersHandler fetches several users at once, returning a map of ID
+// to user with null for IDs that don't exist. At most maxBatch IDs are
+// accepted per request.
+//
+//	@Summary	Get several users by ID
+//	@Tags		users
+//	@Accept		json
+//	@Produce	json
+//	@Param		body	body		BatchUsersRequest	true	"IDs to fetch"
+//	@Param		tz		query		string				false	"IANA time zone for timestamps, e.g. America/New_York"	default(UTC)
+//	@Success	200		{object}	map[string]User
+//	@Failure	400		{object}	APIError
+//	@Failure	413		{object}	APIError
+//	@Router		/v1/users/batch [post]
+func batchGetUsersHandler(store UserStore, maxBatch int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req BatchUsersRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+		if len(req.IDs) > maxBatch {
+			RespondErrorWithDetails(c, http.StatusBadRequest, CodeInvalidRequest, "Too many ids in batch",
+				map[string]any{"ids": "must contain at most " + strconv.Itoa(maxBatch) + " ids"})
+			return
+		}
+
+		users, err := store.GetMany(c.Request.Context(), req.IDs)
+		if err != nil {
+			respondUserError(c, err)
+			return
+		}
+
+		result := make(map[string]*User, len(req.IDs))
+		for _, id := range req.IDs {
+			var found *User
+			if user, ok := users[id]; ok {
+				user = user.In(LocationFromContext(c))
+				found = &user
+			}
+			result[strconv.FormatInt(id, 10)] = found
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// updateUserHandler replaces an existing user's fields. Clients must send the
+// ETag they last saw in If-Match so concurrent edits aren't silently lost.
+//
+//	@Summary	Replace a user
+//	@Tags		users
+//	@Accept		json
+//	@Produce	json
+//	@Security	BearerAuth
+//	@Param		id			path		int			true	"User ID"
+//	@Param		If-Match	header		string		true	"ETag of the version being replaced, or *"
+//	@Param		body		body		UserRequest	true	"New user fields"
+//	@Param		tz			query		string		false	"IANA time zone for timestamps, e.g. America/New_York"	default(UTC)
+//	@Success	200			{object}	User
+//	@Header		200			{string}	ETag	"New user version"
+//	@Failure	400			{object}	APIError
+//	@Failure	401			{object}	APIError
+//	@Failure	404			{object}	APIError
+//	@Failure	412			{object}	APIError
+//	@Failure	413			{object}	APIError
+//	@Failure	428			{object}	APIError
+//	@Router		/v1/user/{id} [put]
+func updateUserHandler(store UserStore, webhooks *WebhookDispatcher, audit *AuditLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := parseIDParam(c, "id")
+		if !ok {
+			return
+		}
+
+		version, ok := parseIfMatch(c)
+		if !ok {
+			return
+		}
+
+		var req UserRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+
+		user, err := store.Update(c.Request.Context(), User{ID: id, Name: req.Name, Email: req.Email, Phone: req.Phone, Version: version})
+		if err != nil {
+			respondUserError(c, err)
+			return
+		}
+
+		audit.Record(c, AuditUpdate, "user", strconv.FormatInt(user.ID, 10))
+		webhooks.Dispatch("user.updated", user)
+
+		c.Header("ETag", versionETag(user.Version, LocationFromContext(c)))
+		RespondData(c, http.StatusOK, user.In(LocationFromContext(c)))
+	}
+}
+
+// welcomeEmailJob greets a newly created user. There's no mail provider yet,
+// so the job only logs what it would send.
+func welcomeEmailJob(user User) Job {
+	return Job{
+		Name: "welcome-email",
+		Run: func(ctx context.Context) error {
+			slog.Info("Sending welcome email", "user_id", user.ID, "email", user.Email)
+			return nil
+		},
+	}
+}
+
+// patchUserHandler updates only the fields present in the request body; a
+// null or empty phone clears it. If-Match is optional; without it the patch
+// applies to the version read.
+//
+//	@Summary	Partially update a user
+//	@Tags		users
+//	@Accept		json
+//	@Produce	json
+//	@Security	BearerAuth
+//	@Param		id			path		int					true	"User ID"
+//	@Param		If-Match	header		string				false	"ETag of the version being patched"
+//	@Param		body		body		UserPatchRequest	true	"Fields to change"
+//	@Param		tz			query		string				false	"IANA time zone for timestamps, e.g. America/New_York"	default(UTC)
+//	@Success	200			{object}	User
+//	@Header		200			{string}	ETag	"New user version"
+//	@Failure	400			{object}	APIError
+//	@Failure	401			{object}	APIError
+//	@Failure	404			{object}	APIError
+//	@Failure	412			{object}	APIError
+//	@Failure	413			{object}	APIError
+//	@Router		/v1/user/{id} [patch]
+func patchUserHandler(store UserStore, webhooks *WebhookDispatcher, audit *AuditLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := parseIDParam(c, "id")
+		if !ok {
+			return
+		}
+
+		var version int64
+		if c.GetHeader("If-Match") != "" {
+			if version, ok = parseIfMatch(c); !ok {
+				return
+			}
+		}
+
+		var req UserPatchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+
+		user, err := store.Get(c.Request.Context(), id)
+		if err != nil {
+			respondUserError(c, err)
+			return
+		}
+		if user.DeletedAt != nil {
+			respondUserNotFound(c)
+			return
+		}
+		if version != 0 && version != user.Version {
+			respondUserError(c, ErrVersionConflict)
+			return
+		}
+
+		if req.Name != nil {
+			user.Name = *req.Name
+		}
+		if req.Email != nil {
+			user.Email = *req.Email
+		}
+		if req.Phone.Set {
+			user.Phone = req.Phone.Value
+		}
+
+		// Update against the version read so a concurrent write isn't overwritten
+		user, err = store.Update(c.Request.Context(), user)
+		if err != nil {
+			respondUserError(c, err)
+			return
+		}
+
+		audit.Record(c, AuditUpdate, "user", strconv.FormatInt(user.ID, 10))
+		webhooks.Dispatch("user.updated", user)
+
+		c.Header("ETag", versionETag(user.Version, LocationFromContext(c)))
+		RespondData(c, http.StatusOK, user.In(LocationFromContext(c)))
+	}
+}
+
+// deleteUserHandler soft-deletes a user; restoreUserHandler undoes it
+//
+//	@Summary	Delete a user
+//	@Tags		users
+//	@Security	BearerAuth
+//	@Param		id	path	int	true	"User ID"
+//	@Success	204
+//	@Failure	400	{object}	APIError
+//	@Failure	401	{object}	APIError
+//	@Failure	403	{object}	APIError
+//	@Failure	404	{object}	APIError
+//	@Router		/v1/user/{id} [delete]
+func deleteUserHandler(store UserStore, audit *AuditLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := parseIDParam(c, "id")
+		if !ok {
+			return
+		}
+
+		if err := store.Delete(c.Request.Context(), id); err != nil {
+			respondUserError(c, err)
+			return
+		}
+		audit.Record(c, AuditDelete, "user", strconv.FormatInt(id, 10))
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// restoreUserHandler clears a user's soft delete. Restoring a user that
+// isn't deleted returns it unchanged.
+//
+//	@Summary	Restore a deleted user
+//	@Tags		users
+//	@Produce	json
+//	@Security	BearerAuth
+//	@Param		id	path		int		true	"User ID"
+//	@Param		tz	query		string	false	"IANA time zone for timestamps, e.g. America/New_York"	default(UTC)
+//	@Success	200	{object}	User
+//	@Header		200	{string}	ETag	"New user version"
+//	@Failure	400	{object}	APIError
+//	@Failure	401	{object}	APIError
+//	@Failure	403	{object}	APIError
+//	@Failure	404	{object}	APIError
+//	@Router		/v1/user/{id}/restore [post]
+func restoreUserHandler(store UserStore, audit *AuditLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := parseIDParam(c, "id")
+		if !ok {
+			return
+		}
+
+		user, err := store.Restore(c.Request.Context(), id)
+		if err != nil {
+			respondUserError(c, err)
+			return
+		}
+		audit.Record(c, AuditRestore, "user", strconv.FormatInt(id, 10))
+
+		c.Header("ETag", versionETag(user.Version, LocationFromContext(c)))
+		RespondData(c, http.StatusOK, user.In(LocationFromContext(c)))
+	}
+}
+
+// parseIDParam parses the named path parameter as a positive integer ID,
+// writing a 400 response and returning false when it isn't one
+func parseIDParam(c *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil || id < 1 {
+		RespondError(c, http.StatusBadRequest, CodeInvalidID, "invalid user id")
+		return 0, false
+	}
+	return id, true
+}
+
+// notModifiedSince reports whether the request's If-Modified-Since is at or
+// after lastModified. HTTP dates have whole-second precision, so
+// lastModified is truncated before comparing.
+func notModifiedSince(c *gin.Context, lastModified time.Time) bool {
+	since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// parseIncludeDeleted parses the optional include_deleted query parameter,
+// writing a 400 response and returning false when it isn't a boolean
+func parseIncludeDeleted(c *gin.Context) (bool, bool) {
+	raw := c.Query("include_deleted")
+	if raw == "" {
+		return false, true
+	}
+
+	include, err := strconv.ParseBool(raw)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, CodeInvalidQuery, "include_deleted must be true or false")
+		return false, false
+	}
+	return include, true
+}
+
+// parseIfMatch returns the version expected by the If-Match header, or 0 for
+// "*". It writes a 428 when the header is missing and a 412 when it can't
+// match any version, returning false in both cases.
+func parseIfMatch(c *gin.Context) (int64, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		RespondError(c, http.StatusPreconditionRequired, CodePreconditionRequired, "If-Match header is required")
+		return 0, false
+	}
+	if header == "*" {
+		return 0, true
+	}
+
+	version, err := parseVersionETag(header)
+	if err != nil {
+		RespondError(c, http.StatusPreconditionFailed, CodePreconditionFailed, "If-Match does not match the current version")
+		return 0, false
+	}
+	return version, true
+}
+
+// respondUserNotFound writes the 404 response for a missing user
+func respondUserNotFound(c *gin.Context) {
+	RespondError(c, http.StatusNotFound, CodeNotFound, "User not found")
+}
+
+// respondUserError maps a UserStore error to an HTTP response
+func respondUserError(c *gin.Context, err error) {
+	if errors.Is(err, ErrUserNotFound) {
+		respondUserNotFound(c)
+		return
+	}
+	if errors.Is(err, ErrVersionConflict) {
+		RespondError(c, http.StatusPreconditionFailed, CodePreconditionFailed, "If-Match does not match the current version")
+		return
+	}
+	if errors.Is(err, ErrUsernameTaken) {
+		RespondError(c, http.StatusConflict, CodeConflict, "username is already taken")
+		return
+	}
+
+	RespondError(c, http.StatusInternalServerError, CodeInternal, "User store unavailable")
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// patchUser sends body as a PATCH of user id on h
+func patchUser(h http.Handler, id, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, "/user/"+id, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestPatchUserPhone(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantCode  int
+		wantPhone string
+	}{
+		{"absent leaves it", `{"name":"Ann Lee"}`, http.StatusOK, "+14155550123"},
+		{"new number", `{"phone":"+442071838750"}`, http.StatusOK, "+442071838750"},
+		{"empty string clears it", `{"phone":""}`, http.StatusOK, ""},
+		{"null clears it", `{"phone":null}`, http.StatusOK, ""},
+		{"invalid number", `{"phone":"415-555-0123"}`, http.StatusBadRequest, "+14155550123"},
+		{"not a string", `{"phone":14155550123}`, http.StatusBadRequest, "+14155550123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryUserStore()
+			user, err := store.Create(context.Background(), User{Username: "ann", Name: "Ann", Email: "ann@example.com", Phone: "+14155550123"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := gin.New()
+			r.PATCH("/user/:id", patchUserHandler(store, nil, NewAuditLogger(&MemoryAuditStore{})))
+
+			w := patchUser(r, "1", tt.body)
+			if w.Code != tt.wantCode {
+				t.Fatalf("got %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			stored, _ := store.Get(context.Background(), user.ID)
+			if stored.Phone != tt.wantPhone {
+				t.Fatalf("phone is %q, want %q", stored.Phone, tt.wantPhone)
+			}
+			if tt.wantCode == http.StatusBadRequest && stored.Version != user.Version {
+				t.Fatal("rejected patch changed the user")
+			}
+		})
+	}
+}
+
+func TestPatchUserValidatesOtherFields(t *testing.T) {
+	store := NewMemoryUserStore()
+	seedUsers(t, store, "Ann")
+	r := gin.New()
+	r.PATCH("/user/:id", patchUserHandler(store, nil, NewAuditLogger(&MemoryAuditStore{})))
+
+	for _, body := range []string{`{"name":""}`, `{"email":"not-an-email"}`, `{"email":"Ann <ann@example.com>"}`} {
+		if w := patchUser(r, "1", body); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: got %d, want 400", body, w.Code)
+		}
+	}
+	if w := patchUser(r, "2", `{"name":"Bob"}`); w.Code != http.StatusNotFound {
+		t.Fatalf("missing user: got %d, want 404", w.Code)
+	}
+}
+
+func TestUserCRUDHandlers(t *testing.T) {
+	store := NewMemoryUserStore()
+	audit := NewAuditLogger(&MemoryAuditStore{})
+	r := createUserRouter(t, store)
+	r.GET("/user/:id", getUserHandler(store))
+	r.PUT("/user/:id", updateUserHandler(store, nil, audit))
+	r.DELETE("/user/:id", deleteUserHandler(store, audit))
+
+	created := post(r, "/user", `{"username":"ann","name":"Ann","email":"ann@example.com"}`)
+	if created.Code != http.StatusCreated {
+		t.Fatalf("create: got %d: %s", created.Code, created.Body)
+	}
+	var user User
+	if err := json.Unmarshal(created.Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 1 || user.CreatedAt.IsZero() {
+		t.Fatalf("created %+v", user)
+	}
+
+	if w := get(r, "/user/1"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"name":"Ann"`) {
+		t.Fatalf("get: got %d: %s", w.Code, w.Body)
+	}
+
+	w := conditionalRequest(r, http.MethodPut, "/user/1", "If-Match", created.Header().Get("ETag"),
+		`{"name":"Ann Lee","email":"ann@example.com"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"name":"Ann Lee"`) {
+		t.Fatalf("put: got %d: %s", w.Code, w.Body)
+	}
+
+	del := httptest.NewRecorder()
+	r.ServeHTTP(del, httptest.NewRequest(http.MethodDelete, "/user/1", nil))
+	if del.Code != http.StatusNoContent {
+		t.Fatalf("delete: got %d", del.Code)
+	}
+
+	for _, target := range []string{"/user/1", "/user/2"} {
+		w := get(r, target)
+		var body APIError
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusNotFound || body.Code != CodeNotFound {
+			t.Fatalf("%s: got %d %+v, want a JSON 404", target, w.Code, body)
+		}
+	}
+	if w := get(r, "/user/abc"); w.Code != http.StatusBadRequest {
+		t.Fatalf("non-numeric id: got %d, want 400", w.Code)
+	}
+}
+
+// putUser sends body as a PUT of user id on h with If-Match set to ifMatch,
+// or without the header when it's empty
+func putUser(h http.Handler, id, ifMatch, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, "/user/"+id, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestUpdateUserRequiresMatchingVersion(t *testing.T) {
+	store := NewMemoryUserStore()
+	seedUsers(t, store, "Ann")
+	r := gin.New()
+	r.PUT("/user/:id", updateUserHandler(store, nil, NewAuditLogger(&MemoryAuditStore{})))
+	const body = `{"name":"Ann Lee","email":"Ann@example.com"}`
+
+	if w := putUser(r, "1", "", body); w.Code != http.StatusPreconditionRequired {
+		t.Fatalf("no If-Match: got %d, want 428", w.Code)
+	}
+	for _, bad := range []string{`"2"`, `W/"1"`, `"abc"`} {
+		if w := putUser(r, "1", bad, body); w.Code != http.StatusPreconditionFailed {
+			t.Fatalf("If-Match %s: got %d, want 412", bad, w.Code)
+		}
+	}
+	if stored, _ := store.Get(context.Background(), 1); stored.Version != 1 || stored.Name != "Ann" {
+		t.Fatalf("rejected updates changed the user: %+v", stored)
+	}
+
+	w := putUser(r, "1", `"1"`, body)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("matching version: got %d, ETag %s", w.Code, w.Header().Get("ETag"))
+	}
+
+	// The first writer's version is now stale
+	if w := putUser(r, "1", `"1"`, `{"name":"Lost Update","email":"Ann@example.com"}`); w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale version: got %d, want 412", w.Code)
+	}
+	if w := putUser(r, "1", "*", body); w.Code != http.StatusOK || w.Header().Get("ETag") != `"3"` {
+		t.Fatalf("If-Match *: got %d, ETag %s", w.Code, w.Header().Get("ETag"))
+	}
+	if w := putUser(r, "9", "*", body); w.Code != http.StatusNotFound {
+		t.Fatalf("missing user: got %d, want 404", w.Code)
+	}
+}
+
+func TestMemoryUserStoreConcurrentUpdatesConflict(t *testing.T) {
+	store := NewMemoryUserStore()
+	user := seedUsers(t, store, "Ann")[0]
+
+	var wg sync.WaitGroup
+	var won atomic.Int32
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Update(context.Background(), User{ID: user.ID, Name: "Writer", Version: user.Version})
+			if err == nil {
+				won.Add(1)
+			} else if !errors.Is(err, ErrVersionConflict) {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if won.Load() != 1 {
+		t.Fatalf("%d writers updated version %d", won.Load(), user.Version)
+	}
+}
+
+func TestPatchUserOnlyChangesGivenFields(t *testing.T) {
+	store := NewMemoryUserStore()
+	seedUsers(t, store, "Ann", "Bob")
+	r := gin.New()
+	r.PATCH("/user/:id", patchUserHandler(store, nil, NewAuditLogger(&MemoryAuditStore{})))
+
+	w := patchUser(r, "1", `{"name":"Ann Lee"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	var patched User
+	if err := json.Unmarshal(w.Body.Bytes(), &patched); err != nil {
+		t.Fatal(err)
+	}
+	if patched.Name != "Ann Lee" || patched.Email != "Ann@example.com" || patched.Version != 2 {
+		t.Fatalf("response is %+v", patched)
+	}
+
+	if w := patchUser(r, "1", `{"email":"ann.lee@example.com"}`); w.Code != http.StatusOK {
+		t.Fatalf("email: got %d: %s", w.Code, w.Body)
+	}
+	stored, _ := store.Get(context.Background(), 1)
+	if stored.Name != "Ann Lee" || stored.Email != "ann.lee@example.com" {
+		t.Fatalf("stored %+v", stored)
+	}
+
+	// An empty patch changes nothing but the version
+	if w := patchUser(r, "1", `{}`); w.Code != http.StatusOK {
+		t.Fatalf("empty patch: got %d", w.Code)
+	}
+	if other, _ := store.Get(context.Background(), 2); other.Name != "Bob" || other.Version != 1 {
+		t.Fatalf("another user changed: %+v", other)
+	}
+}
+
+func TestPatchUserIfMatch(t *testing.T) {
+	store := NewMemoryUserStore()
+	seedUsers(t, store, "Ann")
+	r := gin.New()
+	r.PATCH("/user/:id", patchUserHandler(store, nil, NewAuditLogger(&MemoryAuditStore{})))
+
+	if w := conditionalRequest(r, http.MethodPatch, "/user/1", "If-Match", `"2"`, `{"name":"Ann Lee"}`); w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale If-Match: got %d, want 412", w.Code)
+	}
+	if w := conditionalRequest(r, http.MethodPatch, "/user/1", "If-Match", `"1"`, `{"name":"Ann Lee"}`); w.Code != http.StatusOK {
+		t.Fatalf("matching If-Match: got %d", w.Code)
+	}
+}
+
+func TestBatchGetUsers(t *testing.T) {
+	store := NewMemoryUserStore()
+	seedUsers(t, store, "Ann", "Bob", "Cy")
+	if err := store.Delete(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.POST("/users/batch", batchGetUsersHandler(store, 3))
+
+	w := post(r, "/users/batch", `{"ids":[3,1,99,2]}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("4 ids with a max of 3: status %d, want 400", w.Code)
+	}
+
+	w = post(r, "/users/batch", `{"ids":[3,99,2]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var got map[string]*User
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got["3"] == nil || got["3"].Name != "Cy" {
+		t.Fatalf("got %s, want user 3 and nulls for 99 and 2", w.Body)
+	}
+	for _, missing := range []string{"99", "2"} {
+		if user, ok := got[missing]; !ok || user != nil {
+			t.Errorf("id %s: got %v (present %t), want null for a missing or deleted user", missing, user, ok)
+		}
+	}
+
+	for _, body := range []string{`{"ids":[]}`, `{}`, `{"ids":[0]}`, `{"ids":"1,2"}`} {
+		if w := post(r, "/users/batch", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func TestSoftDeletedUsersHiddenUnlessRequested(t *testing.T) {
+	store := NewMemoryUserStore()
+	seedUsers(t, store, "Ann", "Bob")
+	audit := NewAuditLogger(&MemoryAuditStore{})
+	r := gin.New()
+	r.GET("/user/:id", getUserHandler(store))
+	r.GET("/users", listUsersHandler(store, func() int { return 100 }))
+	r.DELETE("/user/:id", deleteUserHandler(store, audit))
+	r.POST("/user/:id/restore", restoreUserHandler(store, audit))
+
+	listed := func(target string) []string {
+		t.Helper()
+		w := get(r, target)
+		var resp UserListResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: status %d: %v", target, w.Code, err)
+		}
+		return userNames(resp.Users)
+	}
+
+	del := httptest.NewRecorder()
+	r.ServeHTTP(del, httptest.NewRequest(http.MethodDelete, "/user/1", nil))
+	if del.Code != http.StatusNoContent {
+		t.Fatalf("delete: got %d", del.Code)
+	}
+
+	if w := get(r, "/user/1"); w.Code != http.StatusNotFound {
+		t.Fatalf("get deleted user: got %d, want 404", w.Code)
+	}
+	w := get(r, "/user/1?include_deleted=true")
+	var user User
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || user.DeletedAt == nil {
+		t.Fatalf("get with include_deleted: got %d %s, want the user with deleted_at", w.Code, w.Body)
+	}
+	if got := listed("/users"); len(got) != 1 || got[0] != "Bob" {
+		t.Fatalf("list: got %v, want only Bob", got)
+	}
+	if got := listed("/users?include_deleted=true"); len(got) != 2 {
+		t.Fatalf("list with include_deleted: got %v, want Ann and Bob", got)
+	}
+	if w := get(r, "/users?include_deleted=maybe"); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid include_deleted: got %d, want 400", w.Code)
+	}
+
+	if w := post(r, "/user/1/restore", ""); w.Code != http.StatusOK || strings.Contains(w.Body.String(), "deleted_at") {
+		t.Fatalf("restore: got %d %s", w.Code, w.Body)
+	}
+	if w := get(r, "/user/1"); w.Code != http.StatusOK {
+		t.Fatalf("get restored user: got %d", w.Code)
+	}
+	if w := post(r, "/user/9/restore", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("restore unknown user: got %d, want 404", w.Code)
+	}
+}
+
+func TestListUsersIfModifiedSince(t *testing.T) {
+	store := NewMemoryUserStore()
+	r := gin.New()
+	r.GET("/users", listUsersHandler(store, func() int { return 100 }))
+	r.PATCH("/user/:id", patchUserHandler(store, nil, NewAuditLogger(&MemoryAuditStore{})))
+
+	listSince := func(since string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		if since != "" {
+			req.Header.Set("If-Modified-Since", since)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := listSince(""); w.Code != http.StatusOK || w.Header().Get("Last-Modified") != "" {
+		t.Fatalf("empty store: got %d with Last-Modified %q", w.Code, w.Header().Get("Last-Modified"))
+	}
+
+	seedUsers(t, store, "Ann")
+	lastModified := listSince("").Header().Get("Last-Modified")
+	modifiedAt, err := http.ParseTime(lastModified)
+	if err != nil {
+		t.Fatalf("Last-Modified %q: %v", lastModified, err)
+	}
+
+	tests := []struct {
+		name  string
+		since string
+		want  int
+	}{
+		{"unchanged", lastModified, http.StatusNotModified},
+		{"later", modifiedAt.Add(time.Hour).Format(http.TimeFormat), http.StatusNotModified},
+		{"earlier", modifiedAt.Add(-time.Second).Format(http.TimeFormat), http.StatusOK},
+		{"malformed", "yesterday", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := listSince(tt.since); w.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+
+	// HTTP dates have whole seconds, so change the user in a later second
+	time.Sleep(time.Until(modifiedAt.Add(time.Second)))
+	if w := patchUser(r, "1", `{"name":"Ann Lee"}`); w.Code != http.StatusOK {
+		t.Fatalf("patch: got %d: %s", w.Code, w.Body)
+	}
+	w := listSince(lastModified)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Ann Lee") {
+		t.Fatalf("after an update: got %d %s, want 200 with the change", w.Code, w.Body)
+	}
+	if w.Header().Get("Last-Modified") == lastModified {
+		t.Fatal("Last-Modified didn't advance after an update")
+	}
+}
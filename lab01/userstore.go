@@ -0,0 +1,310 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUserNotFound is returned when no user exists with the requested ID
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrVersionConflict is returned when an update's expected version is stale
+var ErrVersionConflict = errors.New("user version conflict")
+
+// ErrUsernameTaken is returned when another live user already has the username
+var ErrUsernameTaken = errors.New("username taken")
+
+// User represents a user resource
+type User struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	// Phone is an E.164 number, or "" if none was given
+	Phone string `json:"phone,omitempty"`
+	// Role is granted to the user's tokens and sessions by /login; stores
+	// default it to "user"
+	Role string `json:"role" enums:"user,admin"`
+	// PasswordHash is the bcrypt hash of the user's password, or "" if they
+	// have none. It's never serialized.
+	PasswordHash string    `json:"-"`
+	Version      int64     `json:"version"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	// DeletedAt is set once the user is soft-deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// userSortFields are the fields users can be listed by
+var userSortFields = []string{"name", "created_at", "updated_at"}
+
+// UserListOptions filters, sorts and pages a user listing
+type UserListOptions struct {
+	// Name keeps users whose name contains it, case-insensitively
+	Name string
+	// SortBy is one of userSortFields; ties are broken by ID
+	SortBy     string
+	Descending bool
+	Limit      int
+	Offset     int
+	// IncludeDeleted also lists soft-deleted users
+	IncludeDeleted bool
+	// UpdatedAfter keeps users changed after it, when it's not zero
+	UpdatedAfter time.Time
+}
+
+// UserStore persists users. Deleted users are kept with DeletedAt set so
+// they can be audited and restored.
+type UserStore interface {
+	// Create stores a new user, assigning its ID and timestamps. A username
+	// another live user has is rejected with ErrUsernameTaken.
+	Create(ctx context.Context, user User) (User, error)
+	// Get returns the user with id, even if soft-deleted, or ErrUserNotFound
+	Get(ctx context.Context, id int64) (User, error)
+	// GetByUsername returns the live user with username, or ErrUserNotFound
+	GetByUsername(ctx context.Context, username string) (User, error)
+	// GetMany returns the users with the given ids, keyed by ID; missing and
+	// soft-deleted IDs are absent from the map
+	GetMany(ctx context.Context, ids []int64) (map[int64]User, error)
+	// List returns a page of users matching opts and the total number of
+	// matches across all pages
+	List(ctx context.Context, opts UserListOptions) ([]User, int, error)
+	// LastModified returns when any user was last created or changed, or
+	// the zero time if there are none
+	LastModified(ctx context.Context) (time.Time, error)
+	// Update replaces the name, email and phone of an existing user and
+	// increments its version. A non-zero user.Version must equal the stored
+	// version or ErrVersionConflict is returned. Soft-deleted users are not
+	// found.
+	Update(ctx context.Context, user User) (User, error)
+	// Delete soft-deletes the user with id or returns ErrUserNotFound if
+	// there's no such user or it's already deleted
+	Delete(ctx context.Context, id int64) error
+	// Restore clears a soft delete and returns the user, or ErrUserNotFound.
+	// It fails with ErrUsernameTaken if a live user took the username since.
+	Restore(ctx context.Context, id int64) (User, error)
+}
+
+// newUserStore returns a PostgresUserStore when databaseURL is set, otherwise
+// an in-memory store. The returned close function releases its resources.
+func newUserStore(ctx context.Context, databaseURL string) (UserStore, func(), error) {
+	if databaseURL == "" {
+		slog.Info("DATABASE_URL not set, using in-memory user store")
+		return NewMemoryUserStore(), func() {}, nil
+	}
+
+	store, err := NewPostgresUserStore(ctx, databaseURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	slog.Info("Using PostgreSQL user store")
+	return store, store.Close, nil
+}
+
+// MemoryUserStore is a UserStore backed by a mutex-guarded map
+type MemoryUserStore struct {
+	mu           sync.RWMutex
+	users        map[int64]User
+	nextID       int64
+	lastModified time.Time
+}
+
+// NewMemoryUserStore creates an empty in-memory user store
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{
+		users:  make(map[int64]User),
+		nextID: 1,
+	}
+}
+
+func (s *MemoryUserStore) Create(ctx context.Context, user User) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, taken := s.liveByUsername(user.Username); taken {
+		return User{}, ErrUsernameTaken
+	}
+
+	now := time.Now().UTC()
+	user.ID = s.nextID
+	if user.Role == "" {
+		user.Role = RoleUser
+	}
+	user.Version = 1
+	user.CreatedAt = now
+	user.UpdatedAt = now
+
+	s.users[user.ID] = user
+	s.nextID++
+	s.lastModified = now
+
+	return user, nil
+}
+
+func (s *MemoryUserStore) Get(ctx context.Context, id int64) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *MemoryUserStore) GetByUsername(ctx context.Context, username string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.liveByUsername(username)
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// liveByUsername returns the user with username that isn't soft-deleted. The
+// caller must hold s.mu.
+func (s *MemoryUserStore) liveByUsername(username string) (User, bool) {
+	for _, user := range s.users {
+		if user.Username == username && user.DeletedAt == nil {
+			return user, true
+		}
+	}
+	return User{}, false
+}
+
+func (s *MemoryUserStore) GetMany(ctx context.Context, ids []int64) (map[int64]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make(map[int64]User, len(ids))
+	for _, id := range ids {
+		if user, ok := s.users[id]; ok && user.DeletedAt == nil {
+			users[id] = user
+		}
+	}
+	return users, nil
+}
+
+func (s *MemoryUserStore) List(ctx context.Context, opts UserListOptions) ([]User, int, error) {
+	s.mu.RLock()
+	name := strings.ToLower(opts.Name)
+	var matches []User
+	for _, user := range s.users {
+		if user.DeletedAt != nil && !opts.IncludeDeleted {
+			continue
+		}
+		if !strings.Contains(strings.ToLower(user.Name), name) {
+			continue
+		}
+		if !opts.UpdatedAfter.IsZero() && !user.UpdatedAt.After(opts.UpdatedAfter) {
+			continue
+		}
+		matches = append(matches, user)
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(matches, func(a, b User) int {
+		var order int
+		switch opts.SortBy {
+		case "name":
+			order = strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		case "created_at":
+			order = a.CreatedAt.Compare(b.CreatedAt)
+		case "updated_at":
+			order = a.UpdatedAt.Compare(b.UpdatedAt)
+		}
+		if order == 0 {
+			order = cmp.Compare(a.ID, b.ID)
+		}
+		if opts.Descending {
+			order = -order
+		}
+		return order
+	})
+
+	total := len(matches)
+	start := min(max(opts.Offset, 0), total)
+	end := min(start+opts.Limit, total)
+	return matches[start:end], total, nil
+}
+
+func (s *MemoryUserStore) LastModified(ctx context.Context) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.lastModified, nil
+}
+
+func (s *MemoryUserStore) Update(ctx context.Context, user User) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.users[user.ID]
+	if !ok || existing.DeletedAt != nil {
+		return User{}, ErrUserNotFound
+	}
+	if user.Version != 0 && user.Version != existing.Version {
+		return User{}, ErrVersionConflict
+	}
+
+	existing.Name = user.Name
+	existing.Email = user.Email
+	existing.Phone = user.Phone
+	existing.Version++
+	existing.UpdatedAt = time.Now().UTC()
+	s.users[user.ID] = existing
+	s.lastModified = existing.UpdatedAt
+
+	return existing, nil
+}
+
+func (s *MemoryUserStore) Delete(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok || user.DeletedAt != nil {
+		return ErrUserNotFound
+	}
+
+	now := time.Now().UTC()
+	user.DeletedAt = &now
+	user.Version++
+	user.UpdatedAt = now
+	s.users[id] = user
+	s.lastModified = now
+
+	return nil
+}
+
+func (s *MemoryUserStore) Restore(ctx context.Context, id int64) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	if user.DeletedAt == nil {
+		return user, nil
+	}
+	if _, taken := s.liveByUsername(user.Username); taken {
+		return User{}, ErrUsernameTaken
+	}
+
+	user.DeletedAt = nil
+	user.Version++
+	user.UpdatedAt = time.Now().UTC()
+	s.users[id] = user
+	s.lastModified = user.UpdatedAt
+
+	return user, nil
+}
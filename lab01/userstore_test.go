@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// seedUsers creates a user with each name, in order, and returns them
+func seedUsers(t *testing.T, store UserStore, names ...string) []User {
+	t.Helper()
+	users := make([]User, len(names))
+	for i, name := range names {
+		user, err := store.Create(context.Background(), User{Username: "u" + name, Name: name, Email: name + "@example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		users[i] = user
+	}
+	return users
+}
+
+// userNames returns the names of users, in order
+func userNames(users []User) []string {
+	names := make([]string, len(users))
+	for i, user := range users {
+		names[i] = user.Name
+	}
+	return names
+}
+
+func TestMemoryUserStoreList(t *testing.T) {
+	store := NewMemoryUserStore()
+	seedUsers(t, store, "Carol", "alice", "Bob", "Dan", "Alan")
+
+	tests := []struct {
+		name string
+		opts UserListOptions
+		want []string
+	}{
+		{"created ascending", UserListOptions{SortBy: "created_at", Limit: 10}, []string{"Carol", "alice", "Bob", "Dan", "Alan"}},
+		{"created descending", UserListOptions{SortBy: "created_at", Descending: true, Limit: 10}, []string{"Alan", "Dan", "Bob", "alice", "Carol"}},
+		{"name ascending ignores case", UserListOptions{SortBy: "name", Limit: 10}, []string{"Alan", "alice", "Bob", "Carol", "Dan"}},
+		{"name descending", UserListOptions{SortBy: "name", Descending: true, Limit: 10}, []string{"Dan", "Carol", "Bob", "alice", "Alan"}},
+		{"name filter ignores case", UserListOptions{Name: "AL", SortBy: "name", Limit: 10}, []string{"Alan", "alice"}},
+		{"second page", UserListOptions{SortBy: "name", Limit: 2, Offset: 2}, []string{"Bob", "Carol"}},
+		{"past the end", UserListOptions{SortBy: "name", Limit: 2, Offset: 10}, []string{}},
+		{"negative offset", UserListOptions{SortBy: "name", Limit: 2, Offset: -3}, []string{"Alan", "alice"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, total, err := store.List(context.Background(), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := userNames(users)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+			wantTotal := 5
+			if tt.opts.Name != "" {
+				wantTotal = 2
+			}
+			if total != wantTotal {
+				t.Fatalf("total %d, want %d", total, wantTotal)
+			}
+		})
+	}
+}
+
+// usersRouter serves listUsersHandler over store at /users
+func usersRouter(store UserStore) *gin.Engine {
+	r := gin.New()
+	r.GET("/users", listUsersHandler(store, func() int { return 100 }))
+	return r
+}
+
+func TestListUsersHandler(t *testing.T) {
+	store := NewMemoryUserStore()
+	seedUsers(t, store, "Carol", "Anna", "Bob", "Hannah")
+
+	w := get(usersRouter(store), "/users?name=an&sort=name&order=desc&limit=1&page=2")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp UserListResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total != 2 || len(resp.Users) != 1 || resp.Users[0].Name != "Anna" {
+		t.Fatalf("got total %d, users %v; want 2, [Anna]", resp.Total, userNames(resp.Users))
+	}
+	if resp.Limit != 1 || resp.Page != 2 || resp.Offset != 1 {
+		t.Fatalf("unexpected pagination %+v", resp.Pagination)
+	}
+}
+
+func TestListUsersHandlerRejectsBadParameters(t *testing.T) {
+	store := NewMemoryUserStore()
+	for _, target := range []string{
+		"/users?sort=email",
+		"/users?sort=password_hash",
+		"/users?order=sideways",
+		"/users?limit=0",
+		"/users?page=abc",
+	} {
+		if w := get(usersRouter(store), target); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, w.Code)
+		}
+	}
+}
+
+func TestMemoryUserStoreConcurrentCreates(t *testing.T) {
+	const writers, perWriter = 8, 50
+	store := NewMemoryUserStore()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	ids := make(chan int64, writers*perWriter)
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWriter {
+				user, err := store.Create(ctx, User{Username: fmt.Sprintf("u%d-%d", w, i), Name: "User"})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				ids <- user.ID
+				// Read and update alongside the other writers
+				if _, err := store.Get(ctx, user.ID); err != nil {
+					t.Error(err)
+				}
+				if _, err := store.Update(ctx, User{ID: user.ID, Name: "Renamed", Version: user.Version}); err != nil {
+					t.Error(err)
+				}
+				store.List(ctx, UserListOptions{Limit: 10})
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[int64]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("ID %d was assigned twice", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != writers*perWriter {
+		t.Fatalf("created %d users, want %d", len(seen), writers*perWriter)
+	}
+	_, total, _ := store.List(ctx, UserListOptions{Limit: 1})
+	if total != writers*perWriter {
+		t.Fatalf("store lists %d users, want %d", total, writers*perWriter)
+	}
+}
+
+func TestMemoryUserStoreConcurrentUsernames(t *testing.T) {
+	store := NewMemoryUserStore()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var created atomic.Int32
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.Create(ctx, User{Username: "alice", Name: "Alice"}); err == nil {
+				created.Add(1)
+			} else if !errors.Is(err, ErrUsernameTaken) {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if created.Load() != 1 {
+		t.Fatalf("%d users got the same username", created.Load())
+	}
+}
+
+func TestMemoryUserStoreCRUD(t *testing.T) {
+	store := NewMemoryUserStore()
+	ctx := context.Background()
+
+	user, err := store.Create(ctx, User{Username: "ann", Name: "Ann", Email: "ann@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 1 || user.Version != 1 || user.CreatedAt.IsZero() || !user.CreatedAt.Equal(user.UpdatedAt) {
+		t.Fatalf("created %+v", user)
+	}
+
+	if _, err := store.Update(ctx, User{ID: user.ID, Name: "Ann Lee", Version: 99}); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("stale update: got %v", err)
+	}
+	updated, err := store.Update(ctx, User{ID: user.ID, Name: "Ann Lee", Email: "ann@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Ann Lee" || updated.Version != 2 || !updated.CreatedAt.Equal(user.CreatedAt) {
+		t.Fatalf("updated %+v", updated)
+	}
+
+	if err := store.Delete(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, user.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("second delete: got %v", err)
+	}
+	if _, err := store.Update(ctx, User{ID: user.ID, Name: "Ann"}); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("update of a deleted user: got %v", err)
+	}
+	deleted, err := store.Get(ctx, user.ID)
+	if err != nil || deleted.DeletedAt == nil {
+		t.Fatalf("deleted user is %+v, %v", deleted, err)
+	}
+	if _, err := store.Get(ctx, 42); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("missing user: got %v", err)
+	}
+}
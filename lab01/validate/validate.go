@@ -0,0 +1,47 @@
+// Package validate checks the format of contact details, for handlers and
+// binding tags alike.
+package validate
+
+import (
+	"errors"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrInvalidEmail is returned for anything but a bare address such as
+	// ann@example.com
+	ErrInvalidEmail = errors.New("must be a valid email address")
+	// ErrInvalidPhone is returned for numbers not in E.164 format
+	ErrInvalidPhone = errors.New("must be an E.164 phone number such as +14155550123")
+)
+
+// e164Pattern is a plus sign, a country code that doesn't start with 0 and
+// at most 15 digits in all
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+// ValidateEmail accepts a single bare address whose domain has at least two
+// labels. Forms net/mail allows but nobody types into a sign-up form, such as
+// a display name ("Ann <ann@example.com>") or a dotless domain, are rejected.
+func ValidateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return ErrInvalidEmail
+	}
+	at := strings.LastIndexByte(email, '@')
+	domain := email[at+1:]
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return ErrInvalidEmail
+	}
+	return nil
+}
+
+// ValidatePhone accepts a phone number in E.164 format, digits only after
+// the leading plus
+func ValidatePhone(phone string) error {
+	if !e164Pattern.MatchString(phone) {
+		return ErrInvalidPhone
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package validate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		valid bool
+	}{
+		{"ann@example.com", true},
+		{"ann.lee+news@mail.example.co.uk", true},
+		{"a@b.io", true},
+		{"", false},
+		{"ann", false},
+		{"ann@", false},
+		{"@example.com", false},
+		{"ann@localhost", false},
+		{"ann@.example.com", false},
+		{"ann@example.com.", false},
+		{"Ann <ann@example.com>", false},
+		{"<ann@example.com>", false},
+		{" ann@example.com", false},
+		{"ann@example.com, bob@example.com", false},
+		{"ann@exa mple.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			err := ValidateEmail(tt.email)
+			if tt.valid && err != nil {
+				t.Fatalf("got %v, want valid", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidEmail) {
+				t.Fatalf("got %v, want ErrInvalidEmail", err)
+			}
+		})
+	}
+}
+
+func TestValidatePhone(t *testing.T) {
+	tests := []struct {
+		phone string
+		valid bool
+	}{
+		{"+14155550123", true},
+		{"+442071838750", true},
+		{"+12", true},
+		{"+123456789012345", true},
+		{"", false},
+		{"+", false},
+		{"+1", false},
+		{"+1234567890123456", false},
+		{"14155550123", false},
+		{"+04155550123", false},
+		{"+1 415 555 0123", false},
+		{"+1-415-555-0123", false},
+		{"+1415555012a", false},
+		{"++14155550123", false},
+		{"+14155550123\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.phone, func(t *testing.T) {
+			err := ValidatePhone(tt.phone)
+			if tt.valid && err != nil {
+				t.Fatalf("got %v, want valid", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidPhone) {
+				t.Fatalf("got %v, want ErrInvalidPhone", err)
+			}
+		})
+	}
+}
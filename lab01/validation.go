@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+
+	"lab01/validate"
+)
+
+// usernamePattern allows 3-32 letters, digits and underscores, starting with a letter
+var usernamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{2,31}$`)
+
+// validationMessages describes each validation tag in field error details
+var validationMessages = map[string]string{
+	"required": "is required",
+	"email":    validate.ErrInvalidEmail.Error(),
+	"phone":    validate.ErrInvalidPhone.Error(),
+	"username": "must be 3-32 letters, digits or underscores, starting with a letter",
+	"min":      "is too short",
+	"max":      "is too long",
+	"oneof":    "must be one of the allowed values",
+}
+
+// registerValidators adds the custom binding tags and reports fields by
+// their JSON names in validation errors
+func registerValidators() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("unexpected binding validator engine")
+	}
+
+	// Binding tags on a NullableString apply to its value
+	v.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		return field.Interface().(NullableString).Value
+	}, NullableString{})
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	if err := v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return usernamePattern.MatchString(fl.Field().String())
+	}); err != nil {
+		return err
+	}
+	// Replaces the validator's own email check, so every "email" tag means
+	// the same as validate.ValidateEmail
+	if err := v.RegisterValidation("email", func(fl validator.FieldLevel) bool {
+		return validate.ValidateEmail(fl.Field().String()) == nil
+	}); err != nil {
+		return err
+	}
+	return v.RegisterValidation("phone", func(fl validator.FieldLevel) bool {
+		return validate.ValidatePhone(fl.Field().String()) == nil
+	})
+}
+
+// respondBindError writes the response for a failed ShouldBindJSON: 413 for
+// oversized bodies, otherwise 400 with a field -> message map in the details
+// when validation failed
+func respondBindError(c *gin.Context, err error) {
+	if isBodyTooLarge(err) {
+		respondBodyTooLarge(c)
+		return
+	}
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		RespondError(c, http.StatusBadRequest, CodeInvalidRequest, "Request body must be valid JSON")
+		return
+	}
+
+	details := make(map[string]any, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		message, ok := validationMessages[fieldErr.Tag()]
+		if !ok {
+			message = "is invalid"
+		}
+		details[fieldErr.Field()] = message
+	}
+	RespondErrorWithDetails(c, http.StatusBadRequest, CodeInvalidRequest, "Request body failed validation", details)
+}
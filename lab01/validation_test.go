@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestCreateUserValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantDetails map[string]any
+	}{
+		{
+			"missing fields",
+			`{}`,
+			map[string]any{"username": "is required", "name": "is required", "email": "is required"},
+		},
+		{
+			"invalid email",
+			`{"username":"ann","name":"Ann","email":"not-an-email"}`,
+			map[string]any{"email": validationMessages["email"]},
+		},
+		{
+			"username starting with a digit",
+			`{"username":"1ann","name":"Ann","email":"ann@example.com"}`,
+			map[string]any{"username": validationMessages["username"]},
+		},
+		{
+			"username too short",
+			`{"username":"an","name":"Ann","email":"ann@example.com"}`,
+			map[string]any{"username": validationMessages["username"]},
+		},
+		{
+			"username with punctuation",
+			`{"username":"ann.lee","name":"Ann","email":"ann@example.com"}`,
+			map[string]any{"username": validationMessages["username"]},
+		},
+		{
+			"several problems at once",
+			`{"username":"ann","email":"ann@","phone":"555-0123"}`,
+			map[string]any{"name": "is required", "email": validationMessages["email"], "phone": validationMessages["phone"]},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(createUserRouter(t, NewMemoryUserStore()), "/user", tt.body)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("got %d, want 400: %s", w.Code, w.Body)
+			}
+			var resp APIError
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Code != CodeInvalidRequest || len(resp.Details) != len(tt.wantDetails) {
+				t.Fatalf("response is %+v, want details %v", resp, tt.wantDetails)
+			}
+			for field, message := range tt.wantDetails {
+				if resp.Details[field] != message {
+					t.Errorf("%s: got %v, want %q", field, resp.Details[field], message)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateUserRejectsMalformedJSON(t *testing.T) {
+	w := post(createUserRouter(t, NewMemoryUserStore()), "/user", `{"username":`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got %d, want 400", w.Code)
+	}
+	var resp APIError
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Message != "Request body must be valid JSON" || resp.Details != nil {
+		t.Fatalf("response is %+v", resp)
+	}
+}
+
+func TestUsernamePattern(t *testing.T) {
+	for _, ok := range []string{"ann", "Ann_Lee", "a12", "abcdefghijklmnopqrstuvwxyz012345"} {
+		if !usernamePattern.MatchString(ok) {
+			t.Errorf("%q was rejected", ok)
+		}
+	}
+	for _, bad := range []string{"", "an", "_ann", "9ann", "ann lee", "ann-lee", "abcdefghijklmnopqrstuvwxyz0123456"} {
+		if usernamePattern.MatchString(bad) {
+			t.Errorf("%q was accepted", bad)
+		}
+	}
+}
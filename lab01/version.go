@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Build information, overridden at build time with:
+//
+//	go build -ldflags "-X main.version=1.2.3 -X main.commit=abc123 -X main.buildDate=2024-01-01T00:00:00Z"
+var (
+	version   = "dev"
+	commit    = "dev"
+	buildDate = "dev"
+)
+
+// BuildInfoResponse represents the response structure for the build info endpoint
+type BuildInfoResponse struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// buildInfoHandler reports exactly which build is running
+//
+//	@Summary	Build information
+//	@Tags		health
+//	@Produce	json
+//	@Success	200	{object}	BuildInfoResponse
+//	@Router		/buildinfo [get]
+func buildInfoHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, BuildInfoResponse{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+	})
+}
+
+// VersionResponse represents the response structure for the version endpoint
+type VersionResponse struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+}
+
+// versionCacheControl lets clients and proxies cache /version for a day; it
+// can't change while the process runs, and a deploy changes the ETag
+const versionCacheControl = "public, max-age=86400"
+
+// versionHandler reports the running version without touching any
+// dependency, so it's cheap to poll
+//
+//	@Summary	Version
+//	@Tags		health
+//	@Produce	json
+//	@Success	200	{object}	VersionResponse
+//	@Header		200	{string}	Cache-Control	"public, max-age=86400"
+//	@Header		200	{string}	ETag			"Changes with the version and commit"
+//	@Success	304
+//	@Router		/version [get]
+//	@Router		/version [head]
+func versionHandler(c *gin.Context) {
+	etag := `"` + version + "-" + commit + `"`
+	c.Header("Cache-Control", versionCacheControl)
+	c.Header("ETag", etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.JSON(http.StatusOK, VersionResponse{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+	})
+}
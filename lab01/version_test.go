@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// withBuildInfo sets the ldflags build variables until the test ends
+func withBuildInfo(t *testing.T, v, c, date string) {
+	t.Helper()
+	prevVersion, prevCommit, prevDate := version, commit, buildDate
+	version, commit, buildDate = v, c, date
+	t.Cleanup(func() { version, commit, buildDate = prevVersion, prevCommit, prevDate })
+}
+
+func TestVersionHandler(t *testing.T) {
+	withBuildInfo(t, "1.2.3", "abc123", "2024-01-01T00:00:00Z")
+	r := gin.New()
+	r.GET("/version", versionHandler)
+
+	w := get(r, "/version")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"version": "1.2.3", "commit": "abc123", "build_date": "2024-01-01T00:00:00Z"}
+	if len(body) != len(want) {
+		t.Fatalf("got %v, want exactly %v", body, want)
+	}
+	for key, value := range want {
+		if body[key] != value {
+			t.Errorf("%s is %q, want %q", key, body[key], value)
+		}
+	}
+	if got := w.Header().Get("Cache-Control"); got != versionCacheControl {
+		t.Errorf("Cache-Control is %q, want %q", got, versionCacheControl)
+	}
+
+	etag := w.Header().Get("ETag")
+	if etag != `"1.2.3-abc123"` {
+		t.Fatalf("ETag is %q", etag)
+	}
+	if w := conditionalRequest(r, http.MethodGet, "/version", "If-None-Match", etag, ""); w.Code != http.StatusNotModified {
+		t.Fatalf("If-None-Match with the current ETag: got %d, want 304", w.Code)
+	}
+	if w := conditionalRequest(r, http.MethodGet, "/version", "If-None-Match", `"1.2.2-old"`, ""); w.Code != http.StatusOK {
+		t.Fatalf("If-None-Match with an old ETag: got %d, want 200", w.Code)
+	}
+}
+
+func TestBuildInfoHandlerIncludesGoVersion(t *testing.T) {
+	withBuildInfo(t, "1.2.3", "abc123", "2024-01-01T00:00:00Z")
+	r := gin.New()
+	r.GET("/buildinfo", buildInfoHandler)
+
+	var info BuildInfoResponse
+	if err := json.Unmarshal(get(r, "/buildinfo").Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != "1.2.3" || info.Commit != "abc123" || info.GoVersion != runtime.Version() {
+		t.Fatalf("got %+v", info)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of a webhook body
+const SignatureHeader = "X-Signature"
+
+// webhookBackoff spaces out delivery attempts; receivers may be down for a
+// while, so it backs off further than request-time retries
+var webhookBackoff = Backoff{Base: 500 * time.Millisecond, Max: 30 * time.Second}
+
+// webhookQueueSize is how many events may wait for delivery before new ones
+// are dropped
+const webhookQueueSize = 256
+
+// WebhookConfig configures outbound webhook delivery
+type WebhookConfig struct {
+	URL         string
+	Secret      []byte
+	MaxAttempts int
+	Timeout     time.Duration
+}
+
+// WebhookEvent is the JSON body POSTed to the webhook URL
+type WebhookEvent struct {
+	Type      string    `json:"type" binding:"required"`
+	Timestamp time.Time `json:"timestamp"`
+	Data      any       `json:"data"`
+}
+
+// WebhookDispatcher delivers events asynchronously from a buffered queue,
+// retrying failed attempts with exponential backoff. A nil dispatcher
+// discards events, so callers needn't check whether webhooks are configured.
+type WebhookDispatcher struct {
+	cfg    WebhookConfig
+	client *http.Client
+	events chan WebhookEvent
+	done   chan struct{}
+
+	// mu guards closed, so Dispatch never sends on events once Close has
+	// closed it; handlers abandoned by a timed-out drain may still dispatch
+	mu     sync.RWMutex
+	closed bool
+
+	// ctx is canceled when Close gives up waiting, aborting pending retries
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewWebhookDispatcher starts a dispatcher whose worker runs until Close
+func NewWebhookDispatcher(cfg WebhookConfig) *WebhookDispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &WebhookDispatcher{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		events: make(chan WebhookEvent, webhookQueueSize),
+		done:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	go d.run()
+	return d
+}
+
+// Dispatch queues an event of eventType without blocking. Events are dropped
+// with a warning when the queue is full or the dispatcher has been closed.
+func (d *WebhookDispatcher) Dispatch(eventType string, data any) {
+	if d == nil {
+		return
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		slog.Warn("Webhook dispatcher closed, dropping event", "type", eventType)
+		return
+	}
+
+	event := WebhookEvent{Type: eventType, Timestamp: time.Now().UTC(), Data: data}
+	select {
+	case d.events <- event:
+	default:
+		slog.Warn("Webhook queue full, dropping event", "type", eventType)
+	}
+}
+
+// Close stops accepting events and waits for queued ones to be delivered,
+// abandoning them if ctx expires first. Calling it again just waits.
+func (d *WebhookDispatcher) Close(ctx context.Context) error {
+	if d == nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.events)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-d.done
+		return ctx.Err()
+	}
+}
+
+// run delivers queued events until the queue is closed and drained
+func (d *WebhookDispatcher) run() {
+	defer close(d.done)
+	defer d.cancel()
+
+	for event := range d.events {
+		if err := d.deliver(event); err != nil {
+			slog.Error("Webhook delivery failed", "type", event.Type, "error", err)
+		}
+	}
+}
+
+// deliver POSTs event, retrying until it succeeds, fails permanently or
+// runs out of attempts
+func (d *WebhookDispatcher) deliver(event WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	attempt := 0
+	err = webhookBackoff.Retry(d.ctx, d.cfg.MaxAttempts, func(ctx context.Context) error {
+		attempt++
+		err := d.post(ctx, event.Type, body)
+		if err != nil && attempt < d.cfg.MaxAttempts {
+			slog.Warn("Webhook attempt failed", "type", event.Type, "attempt", attempt, "error", err)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("attempt %d: %w", attempt, err)
+	}
+	return nil
+}
+
+// post makes a single delivery attempt. Network errors, 429 and 5xx
+// responses are worth retrying; other failures are Permanent.
+func (d *WebhookDispatcher) post(ctx context.Context, eventType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", eventType)
+	// Sign with a fresh timestamp and nonce per attempt so receivers using
+	// VerifySignature accept retries rather than treating them as replays
+	timestamp, nonce := strconv.FormatInt(time.Now().Unix(), 10), uuid.NewString()
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(NonceHeader, nonce)
+	req.Header.Set(SignatureHeader, signRequest(d.cfg.Secret, timestamp, nonce, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	default:
+		return Permanent(fmt.Errorf("webhook returned %s", resp.Status))
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var testWebhookSecret = []byte("test-webhook-secret")
+
+// flakyReceiver is a webhook receiver that verifies signatures and answers
+// failStatus to the first failures deliveries it gets
+type flakyReceiver struct {
+	failures   int32
+	failStatus int
+
+	hits   atomic.Int32
+	mu     sync.Mutex
+	events []WebhookEvent
+}
+
+func (f *flakyReceiver) router() *gin.Engine {
+	r := gin.New()
+	r.POST("/hook", VerifySignature(testWebhookSecret, time.Minute), func(c *gin.Context) {
+		if f.hits.Add(1) <= f.failures {
+			c.Status(f.failStatus)
+			return
+		}
+		var event WebhookEvent
+		if err := c.ShouldBindJSON(&event); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.events = append(f.events, event)
+		f.mu.Unlock()
+		c.Status(http.StatusNoContent)
+	})
+	return r
+}
+
+// delivered returns the events received so far
+func (f *flakyReceiver) delivered() []WebhookEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.events
+}
+
+// webhookFixture starts receiver and a dispatcher delivering to it with
+// maxAttempts and backoff shortened for the test
+func webhookFixture(t *testing.T, receiver *flakyReceiver, maxAttempts int) *WebhookDispatcher {
+	t.Helper()
+	prev := webhookBackoff
+	webhookBackoff = Backoff{Base: time.Millisecond, Max: 5 * time.Millisecond}
+	t.Cleanup(func() { webhookBackoff = prev })
+
+	server := httptest.NewServer(receiver.router())
+	t.Cleanup(server.Close)
+	return NewWebhookDispatcher(WebhookConfig{
+		URL:         server.URL + "/hook",
+		Secret:      testWebhookSecret,
+		MaxAttempts: maxAttempts,
+		Timeout:     time.Second,
+	})
+}
+
+// closeDispatcher drains d, failing the test if that takes too long
+func closeDispatcher(t *testing.T, d *WebhookDispatcher) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebhookRetriesUntilDelivered(t *testing.T) {
+	receiver := &flakyReceiver{failures: 2, failStatus: http.StatusServiceUnavailable}
+	d := webhookFixture(t, receiver, 5)
+
+	d.Dispatch("user.created", User{ID: 7, Name: "Ann"})
+	closeDispatcher(t, d)
+
+	if got := receiver.hits.Load(); got != 3 {
+		t.Fatalf("receiver got %d attempts, want 3", got)
+	}
+	events := receiver.delivered()
+	if len(events) != 1 || events[0].Type != "user.created" {
+		t.Fatalf("delivered %+v", events)
+	}
+	data, _ := json.Marshal(events[0].Data)
+	var user User
+	json.Unmarshal(data, &user)
+	if user.ID != 7 || user.Name != "Ann" {
+		t.Fatalf("event data is %s", data)
+	}
+}
+
+func TestWebhookGivesUpAfterMaxAttempts(t *testing.T) {
+	receiver := &flakyReceiver{failures: 100, failStatus: http.StatusTooManyRequests}
+	d := webhookFixture(t, receiver, 3)
+
+	d.Dispatch("user.updated", User{ID: 1})
+	closeDispatcher(t, d)
+
+	if got := receiver.hits.Load(); got != 3 {
+		t.Fatalf("receiver got %d attempts, want 3", got)
+	}
+}
+
+func TestWebhookDoesNotRetryClientErrors(t *testing.T) {
+	receiver := &flakyReceiver{failures: 100, failStatus: http.StatusGone}
+	d := webhookFixture(t, receiver, 5)
+
+	d.Dispatch("user.updated", User{ID: 1})
+	closeDispatcher(t, d)
+
+	if got := receiver.hits.Load(); got != 1 {
+		t.Fatalf("receiver got %d attempts, want 1", got)
+	}
+}
+
+func TestWebhookCloseDrainsQueue(t *testing.T) {
+	receiver := &flakyReceiver{}
+	d := webhookFixture(t, receiver, 1)
+
+	for range 10 {
+		d.Dispatch("user.created", User{})
+	}
+	closeDispatcher(t, d)
+
+	if got := len(receiver.delivered()); got != 10 {
+		t.Fatalf("delivered %d of 10 queued events", got)
+	}
+}
+
+func TestWebhookCloseGivesUpOnRetries(t *testing.T) {
+	receiver := &flakyReceiver{failures: 1000, failStatus: http.StatusBadGateway}
+	// Long enough backoff that the delivery would outlast Close's deadline
+	d := webhookFixture(t, receiver, 1000)
+	webhookBackoff = Backoff{Base: time.Second, Max: time.Second}
+
+	d.Dispatch("user.created", User{})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := d.Close(ctx); err == nil {
+		t.Fatal("Close reported the abandoned event as delivered")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Close took %s", elapsed)
+	}
+}
+
+func TestNilWebhookDispatcher(t *testing.T) {
+	var d *WebhookDispatcher
+	d.Dispatch("user.created", User{})
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebhookDispatchAfterCloseIsDropped(t *testing.T) {
+	receiver := &flakyReceiver{}
+	d := webhookFixture(t, receiver, 1)
+	d.Dispatch("user.created", User{ID: 1})
+	closeDispatcher(t, d)
+
+	// A handler abandoned by a timed-out drain may still dispatch; that must
+	// not send on the closed queue
+	d.Dispatch("user.updated", User{ID: 1})
+	closeDispatcher(t, d)
+	if events := receiver.delivered(); len(events) != 1 || events[0].Type != "user.created" {
+		t.Fatalf("delivered %+v, want only the event queued before Close", events)
+	}
+}
+
+func TestWebhookDispatchRacesClose(t *testing.T) {
+	d := webhookFixture(t, &flakyReceiver{}, 1)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				d.Dispatch("user.updated", User{ID: 1})
+			}
+		}()
+	}
+	closeDispatcher(t, d)
+	wg.Wait()
+}
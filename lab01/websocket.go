@@ -0,0 +1,146 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsMaxMessageSize is the largest message accepted from a client
+	wsMaxMessageSize = 4096
+	// wsPongWait is how long a connection may go without a pong
+	wsPongWait = 60 * time.Second
+	// wsPingPeriod must be shorter than wsPongWait so pings keep it alive
+	wsPingPeriod = wsPongWait * 9 / 10
+	// wsWriteWait bounds each write to the client
+	wsWriteWait = 10 * time.Second
+)
+
+// wsHub tracks open WebSocket connections so they can be closed on shutdown
+type wsHub struct {
+	mu    sync.Mutex
+	conns map[*websocket.Conn]struct{}
+}
+
+func newWSHub() *wsHub {
+	return &wsHub{conns: make(map[*websocket.Conn]struct{})}
+}
+
+func (h *wsHub) add(conn *websocket.Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.conns[conn] = struct{}{}
+}
+
+func (h *wsHub) remove(conn *websocket.Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.conns, conn)
+}
+
+// CloseAll sends a going-away close frame to every client and closes the
+// connections; http.Server.Shutdown doesn't track hijacked connections
+func (h *wsHub) CloseAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	for conn := range h.conns {
+		conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(wsWriteWait))
+		conn.Close()
+	}
+}
+
+// newUpgrader accepts same-origin connections and those from allowedOrigins
+func newUpgrader(allowedOrigins []string) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" || slices.Contains(allowedOrigins, "*") || slices.Contains(allowedOrigins, origin) {
+				return true
+			}
+			u, err := url.Parse(origin)
+			return err == nil && u.Host == r.Host
+		},
+	}
+}
+
+// wsEchoHandler upgrades the connection and echoes each text message back
+// prefixed with the time it was received
+//
+//	@Summary	WebSocket echo
+//	@Tags		realtime
+//	@Success	101
+//	@Router		/ws/echo [get]
+func wsEchoHandler(hub *wsHub, upgrader *websocket.Upgrader) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			// Upgrade has already written an error response
+			return
+		}
+		defer conn.Close()
+
+		// Deferred so the gauge is right however the connection ends
+		activeConnections.WithLabelValues("ws").Inc()
+		defer activeConnections.WithLabelValues("ws").Dec()
+
+		hub.add(conn)
+		defer hub.remove(conn)
+
+		// Drop clients that send oversized messages or stop answering pings
+		conn.SetReadLimit(wsMaxMessageSize)
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
+
+		done := make(chan struct{})
+		defer close(done)
+		go wsPing(conn, done)
+
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					slog.Warn("WebSocket read error", "error", err)
+				}
+				return
+			}
+			if messageType != websocket.TextMessage {
+				continue
+			}
+
+			reply := time.Now().UTC().Format(time.RFC3339) + " " + string(message)
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(reply)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// wsPing sends periodic pings until done is closed
+func wsPing(conn *websocket.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// WriteControl is safe to call concurrently with other writes
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// echoServer serves /ws/echo on a real listener so the connection can be
+// hijacked, accepting same-origin connections only
+func echoServer(t *testing.T) (*httptest.Server, *wsHub) {
+	t.Helper()
+	hub := newWSHub()
+	r := gin.New()
+	r.GET("/ws/echo", wsEchoHandler(hub, newUpgrader(nil)))
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server, hub
+}
+
+// dialEcho opens a WebSocket to the server's echo endpoint with header
+func dialEcho(t *testing.T, server *httptest.Server, header http.Header) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/echo"
+	conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+	if err == nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, resp, err
+}
+
+// hubSize returns the number of connections hub tracks
+func hubSize(hub *wsHub) int {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	return len(hub.conns)
+}
+
+// waitForHubSize polls until hub tracks n connections
+func waitForHubSize(t *testing.T, hub *wsHub, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for hubSize(hub) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("hub has %d connections, want %d", hubSize(hub), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWSEchoPrefixesTimestamp(t *testing.T) {
+	server, hub := echoServer(t)
+	conn, _, err := dialEcho(t, server, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	for _, message := range []string{"hello", "second message"} {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+			t.Fatal(err)
+		}
+		messageType, reply, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stamp, echoed, ok := strings.Cut(string(reply), " ")
+		if messageType != websocket.TextMessage || !ok || echoed != message {
+			t.Fatalf("reply is %q", reply)
+		}
+		if _, err := time.Parse(time.RFC3339, stamp); err != nil {
+			t.Fatalf("prefix %q isn't a timestamp: %v", stamp, err)
+		}
+	}
+
+	// A clean close removes the connection from the hub
+	waitForHubSize(t, hub, 1)
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	waitForHubSize(t, hub, 0)
+}
+
+func TestWSEchoRejectsOversizedMessages(t *testing.T) {
+	server, _ := echoServer(t)
+	conn, _, err := dialEcho(t, server, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(strings.Repeat("a", wsMaxMessageSize+1))); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Fatalf("got %v, want a message-too-big close", err)
+	}
+}
+
+func TestWSHubCloseAll(t *testing.T) {
+	server, hub := echoServer(t)
+	conn, _, err := dialEcho(t, server, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForHubSize(t, hub, 1)
+
+	hub.CloseAll()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("got %v, want a going-away close", err)
+	}
+	waitForHubSize(t, hub, 0)
+}
+
+func TestWSEchoChecksOrigin(t *testing.T) {
+	server, _ := echoServer(t)
+
+	_, resp, err := dialEcho(t, server, http.Header{"Origin": {"http://evil.example"}})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("cross-origin dial: got %v, %v", resp, err)
+	}
+	if _, _, err := dialEcho(t, server, http.Header{"Origin": {server.URL}}); err != nil {
+		t.Fatalf("same-origin dial: %v", err)
+	}
+}
+
+func TestWSActiveConnectionsGauge(t *testing.T) {
+	server, hub := echoServer(t)
+	first, _, err := dialEcho(t, server, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := dialEcho(t, server, nil); err != nil {
+		t.Fatal(err)
+	}
+	waitForActiveConnections(t, "ws", 2)
+
+	// A client disconnecting and a shutdown closing the rest both count down
+	first.Close()
+	waitForActiveConnections(t, "ws", 1)
+	hub.CloseAll()
+	waitForActiveConnections(t, "ws", 0)
+}